@@ -0,0 +1,54 @@
+// Package bus defines the interface shared by the Dynamixel protocol handlers, so that higher level code (servo
+// drivers, scanners, tools etc.) can be written once and used with devices on either Protocol 1.0 or 2.0.
+package bus
+
+// BroadcastID is the ID used to send an instruction to all the devices on the bus at once.
+const BroadcastID byte = 0xFE
+
+// Bus is implemented by the handlers of each version of the Dynamixel protocol. Instructions that are not part of the
+// protocol used by an implementation return an *UnsupportedInstructionError.
+type Bus interface {
+	// Ping checks if the device with the given ID is alive and returns its model number and firmware version.
+	Ping(id byte) (PingResponse, error)
+	// Read reads a given length of data from the device's control table starting at the given address.
+	Read(id byte, addr, length uint16) ([]byte, error)
+	// Write writes the given data to the given address of the device's control table.
+	Write(id byte, addr uint16, data ...byte) error
+	// SyncRead reads the same length of data from the same address of the control tables of multiple devices.
+	SyncRead(ids []byte, addr, length uint16) ([]ReadResult, error)
+	// SyncWrite writes data of the given length to the same address of the control tables of multiple devices. The
+	// `data` is made of the ID of each device followed by the `length` bytes to write to it.
+	SyncWrite(addr, length uint16, data ...byte) error
+	// BulkRead reads data of different lengths from different addresses of the control tables of multiple devices.
+	BulkRead(data []BulkReadDescriptor) ([]ReadResult, error)
+	// BulkWrite writes data of different lengths to different addresses of the control tables of multiple devices.
+	BulkWrite(data []BulkWriteDescriptor) error
+}
+
+// PingResponse encapsulates the information returned by a ping instruction.
+type PingResponse struct {
+	ID       byte
+	Model    uint16
+	Firmware byte
+}
+
+// ReadResult holds the outcome of reading from a single device as part of a multi-device read instruction.
+type ReadResult struct {
+	ID   byte   //The ID of the device read from.
+	Data []byte //The data read from the device. Nil if the read failed.
+	Err  error  //The reason the read failed, if it did.
+}
+
+// BulkReadDescriptor describes the information required to bulk-read data.
+type BulkReadDescriptor struct {
+	ID     byte   //The ID of the device to read from.
+	Addr   uint16 //The starting address to read from.
+	Length uint16 //The number of bytes to read.
+}
+
+// BulkWriteDescriptor describes the information required to bulk-write data.
+type BulkWriteDescriptor struct {
+	ID   byte   //The ID of the device to write to.
+	Addr uint16 //The starting address to write to.
+	Data []byte //The data to write.
+}
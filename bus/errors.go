@@ -0,0 +1,25 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedInstruction is matched (using errors.Is) by every *UnsupportedInstructionError.
+var ErrUnsupportedInstruction = errors.New("unsupported instruction")
+
+// UnsupportedInstructionError is returned when an instruction is not part of the protocol version used to
+// communicate with the devices.
+type UnsupportedInstructionError struct {
+	Instruction string //The name of the instruction, e.g. "sync read".
+	Protocol    string //The protocol version, e.g. "1.0".
+}
+
+func (e *UnsupportedInstructionError) Error() string {
+	return fmt.Sprintf("%s instruction is not supported by Protocol %s", e.Instruction, e.Protocol)
+}
+
+// Is reports whether target is ErrUnsupportedInstruction.
+func (e *UnsupportedInstructionError) Is(target error) bool {
+	return target == ErrUnsupportedInstruction
+}
@@ -0,0 +1,22 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestUnsupportedInstructionError(t *testing.T) {
+	err := fmt.Errorf("failed to read: %w", &UnsupportedInstructionError{Instruction: "sync read", Protocol: "1.0"})
+
+	if !errors.Is(err, ErrUnsupportedInstruction) {
+		t.Errorf("Expected %q to match %q", err, ErrUnsupportedInstruction)
+	}
+	var target *UnsupportedInstructionError
+	if !errors.As(err, &target) {
+		t.Fatalf("Expected %q to be an *UnsupportedInstructionError", err)
+	}
+	if exp := "sync read instruction is not supported by Protocol 1.0"; target.Error() != exp {
+		t.Errorf("Expected error string %q, got %q", exp, target.Error())
+	}
+}
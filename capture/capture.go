@@ -0,0 +1,256 @@
+// Package capture records the bytes exchanged with Dynamixel devices to a compact binary file, and replays them.
+// A Writer wraps the io.ReadWriter passed to a protocol handler (e.g. a serial port) and records every chunk of bytes
+// written to (TX) and read from (RX) it, with the time it was transferred. A Replay feeds a recording back to a
+// handler in place of the devices, checking that the instructions it writes match the recording, so that a recorded
+// bus session can be used as a regression test.
+//
+// A capture file starts with the magic bytes "DXLCAP", a version byte and the start time of the capture in
+// nanoseconds since the Unix epoch (8 bytes, little-endian). Each chunk follows as its direction byte (0 for TX, 1
+// for RX), its time in microseconds since the previous chunk (or since the start time for the first one), and its
+// length in bytes, both as unsigned varints, and then the bytes themselves.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidCapture = errors.New("invalid capture file")
+	ErrMismatch       = errors.New("written bytes do not match the capture")
+	ErrIncomplete     = errors.New("capture not fully replayed")
+)
+
+const (
+	magic   = "DXLCAP"
+	version = 1
+)
+
+// Direction is the direction of a chunk of bytes.
+type Direction byte
+
+const (
+	TX Direction = iota // Bytes written to the devices.
+	RX                  // Bytes read from the devices.
+)
+
+func (d Direction) String() string {
+	switch d {
+	case TX:
+		return "TX"
+	case RX:
+		return "RX"
+	}
+	return fmt.Sprintf("Direction(%d)", byte(d))
+}
+
+// Chunk is a run of bytes transferred in one direction.
+type Chunk struct {
+	Time      time.Time //The time the first of the bytes was transferred.
+	Direction Direction
+	Data      []byte
+}
+
+// Writer records the bytes written to and read from the io.ReadWriter it wraps, and can be passed to a protocol
+// handler in its place. Consecutive reads (or writes) are recorded as a single chunk, which is written to the capture
+// once bytes are transferred in the other direction, or when the Writer is flushed or closed.
+// The capture is written either in this package's format (see NewWriter) or as pcapng (see NewPcapngWriter).
+// Failing to write the capture doesn't affect the reads and writes, the error is returned by Flush and Close instead.
+// A Writer is safe for concurrent use by multiple goroutines.
+type Writer struct {
+	rw      io.ReadWriter
+	mu      sync.Mutex
+	w       *bufio.Writer
+	encode  func(c Chunk) []byte // Encodes a chunk in the capture's format.
+	pending Chunk                // The chunk being recorded.
+	err     error                // The first error writing the capture.
+}
+
+// NewWriter returns a Writer that wraps rw and writes its capture to dest, starting with the file header.
+func NewWriter(rw io.ReadWriter, dest io.Writer) (*Writer, error) {
+	last := time.Now() // The time of the last chunk written to the capture.
+	header := make([]byte, len(magic)+9)
+	copy(header, magic)
+	header[len(magic)] = version
+	binary.LittleEndian.PutUint64(header[len(magic)+1:], uint64(last.UnixNano()))
+	return newWriter(rw, dest, header, func(c Chunk) []byte {
+		buf := make([]byte, 1, 1+2*binary.MaxVarintLen64+len(c.Data))
+		buf[0] = byte(c.Direction)
+		delta := c.Time.Sub(last)
+		if delta < 0 {
+			delta = 0
+		}
+		// The chunk's time is rounded down to the microsecond, so that rounding errors don't add up.
+		delta = delta.Truncate(time.Microsecond)
+		last = last.Add(delta)
+		buf = appendUvarint(buf, uint64(delta/time.Microsecond))
+		buf = appendUvarint(buf, uint64(len(c.Data)))
+		return append(buf, c.Data...)
+	})
+}
+
+// newWriter returns a Writer that wraps rw and writes the given header, followed by the chunks encoded with encode,
+// to dest.
+func newWriter(rw io.ReadWriter, dest io.Writer, header []byte, encode func(c Chunk) []byte) (*Writer, error) {
+	w := &Writer{
+		rw:     rw,
+		w:      bufio.NewWriter(dest),
+		encode: encode,
+	}
+	if _, err := w.w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+	return w, nil
+}
+
+func (w *Writer) Read(p []byte) (n int, err error) {
+	n, err = w.rw.Read(p)
+	if n > 0 && n <= len(p) {
+		w.record(RX, p[:n])
+	}
+	return n, err
+}
+
+func (w *Writer) Write(p []byte) (n int, err error) {
+	n, err = w.rw.Write(p)
+	if n > 0 && n <= len(p) {
+		w.record(TX, p[:n])
+	}
+	return n, err
+}
+
+// record adds the given bytes to the pending chunk, writing the pending chunk out first if it's in the other
+// direction.
+func (w *Writer) record(d Direction, b []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending.Data) > 0 && w.pending.Direction != d {
+		w.writeChunk()
+	}
+	if len(w.pending.Data) == 0 {
+		w.pending.Time = time.Now()
+		w.pending.Direction = d
+	}
+	w.pending.Data = append(w.pending.Data, b...)
+}
+
+// writeChunk writes the pending chunk to the capture. The lock must be held.
+func (w *Writer) writeChunk() {
+	c := w.pending
+	w.pending = Chunk{}
+	if w.err != nil || len(c.Data) == 0 {
+		return
+	}
+	if _, err := w.w.Write(w.encode(c)); err != nil {
+		w.err = fmt.Errorf("failed to write capture: %w", err)
+	}
+}
+
+// Flush writes the pending chunk and any buffered data to the capture, and returns the first error writing the
+// capture, if any.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeChunk()
+	if w.err == nil {
+		if err := w.w.Flush(); err != nil {
+			w.err = fmt.Errorf("failed to write capture: %w", err)
+		}
+	}
+	return w.err
+}
+
+// Close flushes the capture (see Flush). It doesn't close the wrapped io.ReadWriter or the capture's destination.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
+
+// Reader reads the chunks of a capture.
+type Reader struct {
+	r     *bufio.Reader
+	start time.Time
+	last  time.Time
+}
+
+// NewReader returns a Reader that reads a capture from r, after checking its header.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+9)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", ErrInvalidCapture)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("unknown file type: %w", ErrInvalidCapture)
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported version %d: %w", header[len(magic)], ErrInvalidCapture)
+	}
+	start := time.Unix(0, int64(binary.LittleEndian.Uint64(header[len(magic)+1:])))
+	return &Reader{r: br, start: start, last: start}, nil
+}
+
+// Start returns the time the capture was started.
+func (r *Reader) Start() time.Time {
+	return r.start
+}
+
+// Next returns the next chunk of the capture, or io.EOF if there are no more chunks.
+func (r *Reader) Next() (Chunk, error) {
+	d, err := r.r.ReadByte()
+	if err == io.EOF {
+		return Chunk{}, io.EOF
+	}
+	if err != nil {
+		return Chunk{}, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if Direction(d) != TX && Direction(d) != RX {
+		return Chunk{}, fmt.Errorf("invalid chunk direction %d: %w", d, ErrInvalidCapture)
+	}
+	delta, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("failed to read chunk time: %w", ErrInvalidCapture)
+	}
+	length, err := binary.ReadUvarint(r.r)
+	if err != nil || length > maxChunkLen {
+		return Chunk{}, fmt.Errorf("failed to read chunk length: %w", ErrInvalidCapture)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Chunk{}, fmt.Errorf("failed to read chunk data: %w", ErrInvalidCapture)
+	}
+	r.last = r.last.Add(time.Duration(delta) * time.Microsecond)
+	return Chunk{Time: r.last, Direction: Direction(d), Data: data}, nil
+}
+
+// maxChunkLen is the largest chunk length accepted by Reader, so that a corrupt length doesn't exhaust the memory.
+const maxChunkLen = 1 << 24
+
+// ReadAll reads all the chunks of the capture read from r.
+func ReadAll(r io.Reader) ([]Chunk, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []Chunk
+	for {
+		c, err := cr.Next()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+}
+
+// appendUvarint appends the unsigned varint encoding of v to b.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
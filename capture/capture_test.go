@@ -0,0 +1,102 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/internal/simtest"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+// session runs a few transactions with the devices behind h.
+func session(h *protocol2.Handler) error {
+	if _, err := h.Ping(1); err != nil {
+		return err
+	}
+	if err := h.Write(2, 65, 1); err != nil {
+		return err
+	}
+	if _, err := h.Read(2, 65, 1); err != nil {
+		return err
+	}
+	_, err := h.SyncRead([]byte{1, 2}, 132, 4)
+	return err
+}
+
+// record records a session with a simulated bus, and returns the capture.
+func record(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(simtest.NewBus(t, 1020, 1020), &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := session(protocol2.NewHandler(w, 0)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	before := time.Now()
+	capture := record(t)
+
+	r, err := NewReader(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.Start().Before(before.Truncate(time.Microsecond)) || r.Start().After(time.Now()) {
+		t.Errorf("Unexpected start time %v", r.Start())
+	}
+	chunks, err := ReadAll(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Each of the 4 instructions is followed by its status(es), read one byte at a time but recorded as one chunk.
+	if len(chunks) != 8 {
+		t.Fatalf("Expected 8 chunks but got %d", len(chunks))
+	}
+	ping := []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x03, 0x00, 0x01, 0x19, 0x4E}
+	if chunks[0].Direction != TX || !bytes.Equal(chunks[0].Data, ping) {
+		t.Errorf("Expected a TX chunk of % X but got %v % X", ping, chunks[0].Direction, chunks[0].Data)
+	}
+	for i, c := range chunks {
+		if expect := Direction(i % 2); c.Direction != expect {
+			t.Errorf("Expected chunk %d to be %v but got %v", i, expect, c.Direction)
+		}
+		if i > 0 && c.Time.Before(chunks[i-1].Time) {
+			t.Errorf("Expected chunk %d to be no earlier than the chunk before it", i)
+		}
+	}
+	// The status of the ping (with 3 params) and the two statuses of the sync read (with 4 params each).
+	if len(chunks[1].Data) != 14 || len(chunks[7].Data) != 30 {
+		t.Errorf("Expected RX chunks of 14 and 30 bytes but got %d and %d", len(chunks[1].Data), len(chunks[7].Data))
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	capture := record(t)
+	var testCases = []struct {
+		name    string
+		capture []byte
+	}{
+		{"Empty", nil},
+		{"Unknown file type", append([]byte("PCAP"), capture[4:]...)},
+		{"Unsupported version", append(append([]byte("DXLCAP"), 2), capture[7:]...)},
+		{"Invalid direction", append(append([]byte{}, capture[:15]...), 2, 0, 1, 0)},
+		{"Truncated chunk", capture[:len(capture)-1]},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadAll(bytes.NewReader(tc.capture))
+			if !errors.Is(err, ErrInvalidCapture) {
+				t.Errorf("Expected error of %q but got %q", ErrInvalidCapture, err)
+			}
+		})
+	}
+}
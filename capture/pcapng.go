@@ -0,0 +1,151 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// LinkType is the link-layer header type of the interface in the pcapng captures written by this package,
+// LINKTYPE_USER0 (DLT 147), which is reserved for private use. Wireshark can be told to dissect it as Dynamixel
+// packets with a dissector of its own (Edit > Preferences > Protocols > DLT_USER).
+const LinkType = 147
+
+// pcapng block types and option codes.
+// See https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html for the details of the format.
+const (
+	sectionHeaderBlock  uint32 = 0x0A0D0D0A
+	interfaceDescBlock  uint32 = 0x00000001
+	enhancedPacketBlock uint32 = 0x00000006
+	byteOrderMagic      uint32 = 0x1A2B3C4D
+	optEndOfOpt         uint16 = 0
+	optIfName           uint16 = 2
+	optIfTSResol        uint16 = 9
+	optEPBFlags         uint16 = 2
+	epbFlagInbound      uint32 = 1
+	epbFlagOutbound     uint32 = 2
+)
+
+const (
+	ifName              = "dxl"   // The name of the interface in the pcapng captures.
+	timestampsPerSecond = 1000000 // The resolution of the timestamps in the pcapng captures.
+)
+
+// The length of the packet headers, up to and including the length field.
+const (
+	protocol1HeaderLength = 4
+	protocol2HeaderLength = 7
+)
+
+// NewPcapngWriter returns a Writer that wraps rw and writes its capture to dest in the pcapng format, which can be
+// opened with Wireshark. Each Protocol 1.0 or 2.0 packet is written as a packet of its own, with its direction
+// (inbound for RX, outbound for TX) and the time of the chunk it was in. Bytes that aren't part of a packet are
+// written as packets of their own.
+func NewPcapngWriter(rw io.ReadWriter, dest io.Writer) (*Writer, error) {
+	return newWriter(rw, dest, pcapngHeader(), appendPcapngPackets)
+}
+
+// WritePcapng writes the given chunks (e.g. read from a capture file with ReadAll) to w in the pcapng format (see
+// NewPcapngWriter).
+func WritePcapng(w io.Writer, chunks []Chunk) error {
+	buf := pcapngHeader()
+	for _, c := range chunks {
+		buf = append(buf, appendPcapngPackets(c)...)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("failed to write pcapng: %w", err)
+	}
+	return nil
+}
+
+// pcapngHeader returns a section header block followed by the interface description block of the bus.
+func pcapngHeader() []byte {
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)          // Major version.
+	binary.LittleEndian.PutUint16(shb[6:], 0)          // Minor version.
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0)) // Section length, unspecified.
+	buf := appendBlock(nil, sectionHeaderBlock, shb)
+
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:], LinkType)
+	binary.LittleEndian.PutUint32(idb[4:], 0) // Snap length, unlimited.
+	idb = appendOption(idb, optIfName, []byte(ifName))
+	idb = appendOption(idb, optIfTSResol, []byte{6}) // 10^-6 seconds.
+	idb = appendOption(idb, optEndOfOpt, nil)
+	return appendBlock(buf, interfaceDescBlock, idb)
+}
+
+// appendPcapngPackets returns an enhanced packet block for each packet in the given chunk.
+func appendPcapngPackets(c Chunk) []byte {
+	var buf []byte
+	ts := uint64(c.Time.UnixNano() / (1e9 / timestampsPerSecond))
+	flags := make([]byte, 4)
+	if c.Direction == RX {
+		binary.LittleEndian.PutUint32(flags, epbFlagInbound)
+	} else {
+		binary.LittleEndian.PutUint32(flags, epbFlagOutbound)
+	}
+	for data := c.Data; len(data) > 0; {
+		var packet []byte
+		packet, data = nextPacket(data)
+		epb := make([]byte, 20, 20+len(packet)+3+16)
+		binary.LittleEndian.PutUint32(epb[0:], 0) // Interface ID.
+		binary.LittleEndian.PutUint32(epb[4:], uint32(ts>>32))
+		binary.LittleEndian.PutUint32(epb[8:], uint32(ts))
+		binary.LittleEndian.PutUint32(epb[12:], uint32(len(packet))) // Captured length.
+		binary.LittleEndian.PutUint32(epb[16:], uint32(len(packet))) // Original length.
+		epb = append(epb, pad(packet)...)
+		epb = appendOption(epb, optEPBFlags, flags)
+		epb = appendOption(epb, optEndOfOpt, nil)
+		buf = appendBlock(buf, enhancedPacketBlock, epb)
+	}
+	return buf
+}
+
+// nextPacket splits the first Protocol 1.0 or 2.0 packet off the start of b. If b doesn't start with a complete
+// packet, the bytes up to the next packet header (or the end of b) are returned as the packet.
+func nextPacket(b []byte) (packet, rest []byte) {
+	var n int
+	switch {
+	case len(b) >= protocol2HeaderLength && b[0] == 0xFF && b[1] == 0xFF && b[2] == 0xFD && b[3] == 0x00:
+		n = protocol2HeaderLength + int(b[5]) + int(b[6])<<8
+	case len(b) >= protocol1HeaderLength && b[0] == 0xFF && b[1] == 0xFF:
+		n = protocol1HeaderLength + int(b[3])
+	}
+	if n == 0 || n > len(b) {
+		n = 1
+		for n < len(b) && !(b[n] == 0xFF && n+1 < len(b) && b[n+1] == 0xFF) {
+			n++
+		}
+	}
+	return b[:n], b[n:]
+}
+
+// appendBlock appends a block of the given type and body, which must be a multiple of 4 bytes long, to buf.
+func appendBlock(buf []byte, blockType uint32, body []byte) []byte {
+	length := uint32(12 + len(body))
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], blockType)
+	buf = append(buf, b[:]...)
+	binary.LittleEndian.PutUint32(b[:], length)
+	buf = append(buf, b[:]...)
+	buf = append(buf, body...)
+	return append(buf, b[:]...)
+}
+
+// appendOption appends an option with the given code and value, padded to a multiple of 4 bytes, to buf.
+func appendOption(buf []byte, code uint16, value []byte) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint16(b[0:], code)
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(value)))
+	return append(append(buf, b[:]...), pad(value)...)
+}
+
+// pad returns b padded with zeros to a multiple of 4 bytes.
+func pad(b []byte) []byte {
+	if len(b)%4 == 0 {
+		return b
+	}
+	return append(append([]byte{}, b...), make([]byte, 4-len(b)%4)...)
+}
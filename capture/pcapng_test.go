@@ -0,0 +1,163 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/internal/simtest"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+// block is a pcapng block.
+type block struct {
+	blockType uint32
+	body      []byte
+}
+
+// parseBlocks splits a pcapng file into its blocks, checking the length and alignment of each.
+func parseBlocks(t *testing.T, b []byte) []block {
+	t.Helper()
+	var blocks []block
+	for len(b) > 0 {
+		if len(b) < 12 {
+			t.Fatalf("Expected a block of at least 12 bytes but got %d bytes", len(b))
+		}
+		length := binary.LittleEndian.Uint32(b[4:])
+		if length%4 != 0 || int(length) > len(b) {
+			t.Fatalf("Invalid block length %d (%d bytes left)", length, len(b))
+		}
+		if trailer := binary.LittleEndian.Uint32(b[length-4:]); trailer != length {
+			t.Fatalf("Expected trailing block length of %d but got %d", length, trailer)
+		}
+		blocks = append(blocks, block{binary.LittleEndian.Uint32(b), b[8 : length-4]})
+		b = b[length:]
+	}
+	return blocks
+}
+
+// parseOptions returns the options at the start of b by code, checking that they end with opt_endofopt.
+func parseOptions(t *testing.T, b []byte) map[uint16][]byte {
+	t.Helper()
+	options := map[uint16][]byte{}
+	for len(b) >= 4 {
+		code, length := binary.LittleEndian.Uint16(b), int(binary.LittleEndian.Uint16(b[2:]))
+		if code == optEndOfOpt {
+			return options
+		}
+		padded := (length + 3) &^ 3
+		if 4+padded > len(b) {
+			t.Fatalf("Option %d of length %d overruns its block", code, length)
+		}
+		options[code] = b[4 : 4+length]
+		b = b[4+padded:]
+	}
+	t.Fatal("Expected options to end with opt_endofopt")
+	return nil
+}
+
+func TestPcapngWriter(t *testing.T) {
+	var buf bytes.Buffer
+	before := time.Now()
+	w, err := NewPcapngWriter(simtest.NewBus(t, 1020, 1020), &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := session(protocol2.NewHandler(w, 0)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	blocks := parseBlocks(t, buf.Bytes())
+	if len(blocks) < 2 || blocks[0].blockType != sectionHeaderBlock || blocks[1].blockType != interfaceDescBlock {
+		t.Fatalf("Expected a section header block followed by an interface description block")
+	}
+	shb := blocks[0].body
+	if binary.LittleEndian.Uint32(shb) != byteOrderMagic || binary.LittleEndian.Uint16(shb[4:]) != 1 {
+		t.Errorf("Unexpected section header block % X", shb)
+	}
+	idb := blocks[1].body
+	if lt := binary.LittleEndian.Uint16(idb); lt != LinkType {
+		t.Errorf("Expected link type %d but got %d", LinkType, lt)
+	}
+	opts := parseOptions(t, idb[8:])
+	if string(opts[optIfName]) != ifName || !bytes.Equal(opts[optIfTSResol], []byte{6}) {
+		t.Errorf("Unexpected interface options %v", opts)
+	}
+
+	// The ping, write, read and sync read instructions, each followed by its status, with two for the sync read.
+	expect := []struct {
+		flags  uint32
+		length int
+	}{
+		{epbFlagOutbound, 10}, {epbFlagInbound, 14},
+		{epbFlagOutbound, 13}, {epbFlagInbound, 11},
+		{epbFlagOutbound, 14}, {epbFlagInbound, 12},
+		{epbFlagOutbound, 16}, {epbFlagInbound, 15}, {epbFlagInbound, 15},
+	}
+	epbs := blocks[2:]
+	if len(epbs) != len(expect) {
+		t.Fatalf("Expected %d packets but got %d", len(expect), len(epbs))
+	}
+	var last uint64
+	for i, e := range expect {
+		if epbs[i].blockType != enhancedPacketBlock {
+			t.Fatalf("Expected block %d to be an enhanced packet block but got type %#x", i+2, epbs[i].blockType)
+		}
+		body := epbs[i].body
+		ts := uint64(binary.LittleEndian.Uint32(body[4:]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:]))
+		if ts < uint64(before.UnixNano()/1000) || ts > uint64(time.Now().UnixNano()/1000) || ts < last {
+			t.Errorf("Unexpected timestamp %d of packet %d", ts, i)
+		}
+		last = ts
+		captured, original := binary.LittleEndian.Uint32(body[12:]), binary.LittleEndian.Uint32(body[16:])
+		if int(captured) != e.length || original != captured {
+			t.Errorf("Expected packet %d to be %d bytes but got %d (original %d)", i, e.length, captured, original)
+		}
+		data := body[20 : 20+captured]
+		if !bytes.HasPrefix(data, []byte{0xFF, 0xFF, 0xFD, 0x00}) {
+			t.Errorf("Expected packet %d to start with a Protocol 2.0 header but got % X", i, data)
+		}
+		opts := parseOptions(t, body[20+(captured+3)&^3:])
+		if flags := binary.LittleEndian.Uint32(opts[optEPBFlags]); flags != e.flags {
+			t.Errorf("Expected packet %d to have flags %d but got %d", i, e.flags, flags)
+		}
+	}
+}
+
+func TestWritePcapng(t *testing.T) {
+	chunks := []Chunk{
+		{Time: time.Unix(1, 2000), Direction: TX, Data: []byte{0xFF, 0xFF, 0x01, 0x02, 0x01, 0xFB}},
+		// Noise, followed by a Protocol 1.0 status and a truncated one.
+		{Time: time.Unix(1, 5000), Direction: RX,
+			Data: []byte{0x00, 0xFF, 0xFF, 0x01, 0x02, 0x00, 0xFC, 0xFF, 0xFF, 0x01}},
+	}
+	var buf bytes.Buffer
+	if err := WritePcapng(&buf, chunks); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	blocks := parseBlocks(t, buf.Bytes())
+	expect := []struct {
+		ts   uint64
+		data []byte
+	}{
+		{1000002, []byte{0xFF, 0xFF, 0x01, 0x02, 0x01, 0xFB}},
+		{1000005, []byte{0x00}},
+		{1000005, []byte{0xFF, 0xFF, 0x01, 0x02, 0x00, 0xFC}},
+		{1000005, []byte{0xFF, 0xFF, 0x01}},
+	}
+	if len(blocks) != 2+len(expect) {
+		t.Fatalf("Expected %d blocks but got %d", 2+len(expect), len(blocks))
+	}
+	for i, e := range expect {
+		body := blocks[2+i].body
+		ts := uint64(binary.LittleEndian.Uint32(body[4:]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:]))
+		data := body[20 : 20+binary.LittleEndian.Uint32(body[12:])]
+		if ts != e.ts || !bytes.Equal(data, e.data) {
+			t.Errorf("Expected packet %d of % X at %d but got % X at %d", i, e.data, e.ts, data, ts)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Replay plays back a capture in place of the devices it was recorded from, and can be passed to a protocol handler
+// in place of a serial port. The bytes written to it must match the recorded TX chunks, in order, and the recorded RX
+// chunks become available to Read once the TX chunks before them have been written, as fast as they are read. A read
+// returns io.EOF when there is nothing to read, as a serial port does.
+// A Replay is safe for concurrent use by multiple goroutines.
+type Replay struct {
+	mu     sync.Mutex
+	chunks []Chunk
+	i      int    // The index of the next chunk to replay.
+	offset int    // The number of bytes of the next chunk already written, if it's a TX chunk.
+	rx     []byte // The bytes available to Read.
+	err    error  // The first mismatch, after which all writes fail.
+}
+
+// NewReplay returns a Replay of the capture read from r.
+func NewReplay(r io.Reader) (*Replay, error) {
+	chunks, err := ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewChunkReplay(chunks), nil
+}
+
+// NewChunkReplay returns a Replay of the given chunks.
+func NewChunkReplay(chunks []Chunk) *Replay {
+	r := &Replay{chunks: chunks}
+	r.release()
+	return r
+}
+
+// release makes the RX chunks up to the next TX chunk available to Read. The lock must be held, except on creation.
+func (r *Replay) release() {
+	for r.i < len(r.chunks) && r.chunks[r.i].Direction == RX {
+		r.rx = append(r.rx, r.chunks[r.i].Data...)
+		r.i++
+	}
+}
+
+// Read reads the recorded RX bytes made available by the writes so far. It returns io.EOF if there is nothing to read.
+func (r *Replay) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.rx) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.rx)
+	r.rx = r.rx[n:]
+	return n, nil
+}
+
+// Write checks the given bytes against the recorded TX bytes. It returns an error matching ErrMismatch (using
+// errors.Is) if they differ, or if there is nothing left to write in the capture, and fails from then on.
+func (r *Replay) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	var n int
+	for n < len(p) {
+		if r.i >= len(r.chunks) {
+			r.err = fmt.Errorf("unexpected write of % X after the end of the capture: %w", p[n:], ErrMismatch)
+			return n, r.err
+		}
+		expect := r.chunks[r.i].Data[r.offset:]
+		got := p[n:]
+		if len(got) > len(expect) {
+			got = got[:len(expect)]
+		}
+		if !bytes.Equal(got, expect[:len(got)]) {
+			r.err = fmt.Errorf("chunk %d: expected % X but got % X: %w", r.i, expect[:len(got)], got, ErrMismatch)
+			return n, r.err
+		}
+		n += len(got)
+		r.offset += len(got)
+		if r.offset == len(r.chunks[r.i].Data) {
+			r.i++
+			r.offset = 0
+			r.release()
+		}
+	}
+	return n, nil
+}
+
+// Done returns nil if the whole capture has been replayed, i.e. all the TX bytes have been written and all the RX
+// bytes have been read. Otherwise it returns the mismatch that stopped the replay, if any, or an error matching
+// ErrIncomplete.
+func (r *Replay) Done() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if r.i < len(r.chunks) || len(r.rx) > 0 {
+		return fmt.Errorf("%d of %d chunks replayed, %d bytes left to read: %w", r.i, len(r.chunks), len(r.rx),
+			ErrIncomplete)
+	}
+	return nil
+}
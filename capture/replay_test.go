@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+func TestReplay(t *testing.T) {
+	r, err := NewReplay(bytes.NewReader(record(t)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	h := protocol2.NewHandler(r, 0)
+	if err := session(h); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Done(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := h.Action(1); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected error of %q but got %q", ErrMismatch, err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	r, err := NewReplay(bytes.NewReader(record(t)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	h := protocol2.NewHandler(r, 0)
+	if _, err := h.Ping(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := h.Write(2, 65, 0); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected error of %q but got %q", ErrMismatch, err)
+	}
+	if _, err := h.Ping(1); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected error of %q but got %q", ErrMismatch, err)
+	}
+	if err := r.Done(); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected error of %q but got %q", ErrMismatch, err)
+	}
+}
+
+func TestReplayIncomplete(t *testing.T) {
+	r := NewChunkReplay([]Chunk{
+		{Direction: RX, Data: []byte{0x00}},
+		{Direction: TX, Data: []byte{0x01, 0x02}},
+		{Direction: RX, Data: []byte{0x03, 0x04}},
+	})
+	b := make([]byte, 2)
+	if n, err := r.Read(b); n != 1 || err != nil || b[0] != 0x00 {
+		t.Errorf("Expected to read 1 byte (0x00) but got %d (% X, error: %v)", n, b[:n], err)
+	}
+	if _, err := r.Read(b); err != io.EOF {
+		t.Errorf("Expected error of %q but got %q", io.EOF, err)
+	}
+	if _, err := r.Write([]byte{0x01}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := r.Read(b); err != io.EOF {
+		t.Errorf("Expected error of %q but got %q", io.EOF, err)
+	}
+	if err := r.Done(); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected error of %q but got %q", ErrIncomplete, err)
+	}
+	if _, err := r.Write([]byte{0x02}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Done(); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected error of %q but got %q", ErrIncomplete, err)
+	}
+	if n, err := r.Read(b); n != 2 || err != nil || !bytes.Equal(b, []byte{0x03, 0x04}) {
+		t.Errorf("Expected to read 2 bytes (03 04) but got %d (% X, error: %v)", n, b[:n], err)
+	}
+	if err := r.Done(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
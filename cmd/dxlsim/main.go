@@ -0,0 +1,89 @@
+// Command dxlsim runs a bus of simulated Dynamixel servos (see package sim) as a standalone process, reachable through
+// a pseudo-terminal (Linux only), which can be opened as a serial port, and/or a TCP socket.
+//
+// Usage:
+//
+//	dxlsim [-pty] [-tcp address] devices.json
+//
+// The device list is a JSON array of devices as read by sim.ReadDevices, e.g.
+//
+//	[{"model": 1020, "id": 1}, {"model": 1020, "id": 2}]
+//
+// Go programs can connect to the TCP socket with sim.Dial, and pass the connection to the Protocol 2.0 handler in place
+// of a serial port. The servos move in real time, and run until the process is interrupted.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/haguro/go-dxl/sim"
+)
+
+func main() {
+	usePTY := flag.Bool("pty", false, "serve the bus on a pseudo-terminal")
+	addr := flag.String("tcp", "", "serve the bus on a TCP socket listening on `address` (e.g. localhost:9000)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-pty] [-tcp address] devices.json\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (!*usePTY && *addr == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *usePTY, *addr); err != nil {
+		fmt.Fprintf(os.Stderr, "dxlsim: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string, usePTY bool, addr string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	servos, err := sim.ReadDevices(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	b := sim.NewBus(servos...)
+	b.SetRealTime(true)
+
+	errs := make(chan error, 2)
+	if usePTY {
+		pty, err := sim.OpenPTY()
+		if err != nil {
+			return err
+		}
+		defer pty.Close()
+		fmt.Printf("serving %d device(s) on %s\n", len(servos), pty.Name())
+		go func() {
+			errs <- b.ServeConn(pty)
+		}()
+	}
+	if addr != "" {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		fmt.Printf("serving %d device(s) on %s\n", len(servos), l.Addr())
+		go func() {
+			errs <- b.Serve(l)
+		}()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	select {
+	case <-sig:
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
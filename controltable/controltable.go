@@ -0,0 +1,136 @@
+// Package controltable describes the control tables of Dynamixel devices, so that registers can be referred to by name
+// instead of by the raw addresses and sizes found in the ROBOTIS e-Manual.
+// See https://emanual.robotis.com/docs/en/dxl/ for the control table of each model.
+package controltable
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrUnknownModel    = errors.New("unknown model number")
+	ErrUnknownRegister = errors.New("unknown register")
+	ErrOutOfRange      = errors.New("value out of register range")
+	ErrReadOnly        = errors.New("register is read-only")
+	ErrInvalidDataSize = errors.New("data size does not match register size")
+)
+
+const (
+	maxInt32  = 1<<31 - 1
+	minInt32  = -1 << 31
+	maxUint32 = 1<<32 - 1
+)
+
+// Access describes whether a register can be written to or only read from.
+type Access byte
+
+const (
+	ReadOnly  Access = iota + 1 // R
+	ReadWrite                   // RW
+)
+
+func (a Access) String() string {
+	switch a {
+	case ReadOnly:
+		return "R"
+	case ReadWrite:
+		return "RW"
+	}
+	return fmt.Sprintf("Access(%d)", byte(a))
+}
+
+// Area describes the memory area a register is stored in. Registers in the EEPROM area keep their value when the
+// device is powered off, but can only be written to while torque is disabled.
+type Area byte
+
+const (
+	EEPROM Area = iota + 1
+	RAM
+)
+
+func (a Area) String() string {
+	switch a {
+	case EEPROM:
+		return "EEPROM"
+	case RAM:
+		return "RAM"
+	}
+	return fmt.Sprintf("Area(%d)", byte(a))
+}
+
+// Register describes a single entry in the control table of a device.
+type Register struct {
+	Name   string //The name of the register as it appears in the e-Manual, e.g. "Goal Position".
+	Addr   uint16 //The address of the register in the control table.
+	Size   uint16 //The size of the register in bytes.
+	Signed bool   //Whether the value of the register is a (two's complement) signed integer.
+	Access Access //Whether the register is read-only or read-write.
+	Area   Area   //Whether the register is stored in EEPROM or RAM.
+	Min    int64  //The minimum raw value of the register.
+	Max    int64  //The maximum raw value of the register.
+	Unit   string //The unit of one raw value, e.g. "0.088 deg". Empty if the value has no unit.
+}
+
+// withRange returns a copy of the register with the given range.
+func (r Register) withRange(min, max int64) Register {
+	r.Min, r.Max = min, max
+	return r
+}
+
+// withUnit returns a copy of the register with the given unit.
+func (r Register) withUnit(unit string) Register {
+	r.Unit = unit
+	return r
+}
+
+// Model describes a device model and its control table.
+type Model struct {
+	Number    uint16        //The model number, as returned by a ping instruction.
+	Name      string        //The model name, e.g. "XM430-W350".
+	Series    string        //The series the model belongs to, e.g. "X".
+	Registers []Register    //The registers of the model's control table, ordered by address.
+	BaudRates map[int]int64 //The baud rates the model supports, mapped to the value of its Baud Rate register.
+}
+
+// Register returns the register with the given name from the model's control table.
+func (m *Model) Register(name string) (Register, error) {
+	for _, r := range m.Registers {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return Register{}, fmt.Errorf("%q in model %s: %w", name, m.Name, ErrUnknownRegister)
+}
+
+// models holds every known model, keyed by model number.
+var models = map[uint16]*Model{}
+
+func register(ms ...*Model) {
+	for _, m := range ms {
+		if _, ok := models[m.Number]; ok {
+			panic(fmt.Sprintf("controltable: model number %d registered twice", m.Number))
+		}
+		models[m.Number] = m
+	}
+}
+
+// Lookup returns the model with the given model number (e.g. `PingResponse.Model`).
+func Lookup(number uint16) (*Model, error) {
+	m, ok := models[number]
+	if !ok {
+		return nil, fmt.Errorf("model number %d: %w", number, ErrUnknownModel)
+	}
+	return m, nil
+}
+
+// Models returns all the known models, ordered by model number.
+func Models() []*Model {
+	ms := make([]*Model, 0, len(models))
+	for _, m := range models {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Number < ms[j].Number })
+	return ms
+}
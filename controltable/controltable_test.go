@@ -0,0 +1,187 @@
+package controltable
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		number    uint16
+		expName   string
+		expSeries string
+		expErr    error
+	}{
+		{
+			name:      "X series",
+			number:    1020,
+			expName:   "XM430-W350",
+			expSeries: "X",
+		},
+		{
+			name:      "XH430-W350",
+			number:    1000,
+			expName:   "XH430-W350",
+			expSeries: "X",
+		},
+		{
+			name:      "XH430-W210",
+			number:    1010,
+			expName:   "XH430-W210",
+			expSeries: "X",
+		},
+		{
+			name:      "MX series",
+			number:    311,
+			expName:   "MX-64(2.0)",
+			expSeries: "MX",
+		},
+		{
+			name:      "PRO series",
+			number:    54024,
+			expName:   "H54-200-S500-R",
+			expSeries: "PRO",
+		},
+		{
+			name:   "Unknown model",
+			number: 12,
+			expErr: ErrUnknownModel,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Lookup(tc.number)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got.Name != tc.expName || got.Series != tc.expSeries {
+				t.Errorf("Expected model %s of the %s series, got %s of the %s series", tc.expName, tc.expSeries,
+					got.Name, got.Series)
+			}
+		})
+	}
+}
+
+func TestModelRegister(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		model    uint16
+		register string
+		expAddr  uint16
+		expSize  uint16
+		expErr   error
+	}{
+		{
+			name:     "X series Goal Position",
+			model:    1020,
+			register: "Goal Position",
+			expAddr:  116,
+			expSize:  4,
+		},
+		{
+			name:     "PRO series Goal Position",
+			model:    54024,
+			register: "Goal Position",
+			expAddr:  596,
+			expSize:  4,
+		},
+		{
+			name:     "Present Load on model without current control",
+			model:    1060,
+			register: "Present Load",
+			expAddr:  126,
+			expSize:  2,
+		},
+		{
+			name:     "Goal Current on model without current control",
+			model:    30,
+			register: "Goal Current",
+			expErr:   ErrUnknownRegister,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := Lookup(tc.model)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got, err := m.Register(tc.register)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got.Addr != tc.expAddr || got.Size != tc.expSize {
+				t.Errorf("Expected register at address %d of size %d, got address %d of size %d", tc.expAddr,
+					tc.expSize, got.Addr, got.Size)
+			}
+		})
+	}
+}
+
+// TestControlTables checks that the control tables of all models are consistent.
+func TestControlTables(t *testing.T) {
+	for _, m := range Models() {
+		t.Run(m.Name, func(t *testing.T) {
+			names := map[string]bool{}
+			var end uint16
+			for i, r := range m.Registers {
+				if names[r.Name] {
+					t.Errorf("Register %q appears more than once", r.Name)
+				}
+				names[r.Name] = true
+				if i > 0 && r.Addr < end {
+					t.Errorf("Register %q at address %d overlaps the previous register or is out of order", r.Name,
+						r.Addr)
+				}
+				end = r.Addr + r.Size
+				if r.Size != 1 && r.Size != 2 && r.Size != 4 {
+					t.Errorf("Register %q has invalid size %d", r.Name, r.Size)
+				}
+				if r.Min > r.Max {
+					t.Errorf("Register %q has min %d greater than max %d", r.Name, r.Min, r.Max)
+				}
+				bits := 8 * uint(r.Size)
+				if r.Min < -(1<<(bits-1)) || r.Max > 1<<bits-1 {
+					t.Errorf("Register %q range %d to %d does not fit in %d bytes", r.Name, r.Min, r.Max, r.Size)
+				}
+				if r.Signed && r.Max > 1<<(bits-1)-1 || !r.Signed && r.Min < 0 {
+					t.Errorf("Register %q range %d to %d does not match its signedness", r.Name, r.Min, r.Max)
+				}
+				if r.Access != ReadOnly && r.Access != ReadWrite {
+					t.Errorf("Register %q has invalid access %s", r.Name, r.Access)
+				}
+				if r.Area != EEPROM && r.Area != RAM {
+					t.Errorf("Register %q has invalid area %s", r.Name, r.Area)
+				}
+			}
+			baudRate, err := m.Register("Baud Rate")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(m.BaudRates) == 0 {
+				t.Errorf("Model has no baud rates")
+			}
+			for rate, v := range m.BaudRates {
+				if v < baudRate.Min || v > baudRate.Max {
+					t.Errorf("Baud rate %d has value %d outside the Baud Rate register range", rate, v)
+				}
+			}
+			r, err := m.Register("Model Number")
+			if err != nil || r.Addr != 0 || r.Size != 2 {
+				t.Errorf("Expected Model Number register at address 0 of size 2, got %+v (%v)", r, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package controltable
+
+// The PRO series (the original, not PRO+) uses a different control table layout to the X series, with the RAM area
+// starting at address 562. The position, velocity and current resolutions differ between models.
+// See https://emanual.robotis.com/docs/en/dxl/pro/h54-200-s500-r/#control-table for an example.
+func init() {
+	register(
+		proModel(54024, "H54-200-S500-R", proLimits{position: 250961, velocity: 17000, torque: 620,
+			positionUnit: "0.000717 deg", velocityUnit: "0.00199234 rpm", currentUnit: "16.11328 mA"}),
+		proModel(53768, "H54-100-S500-R", proLimits{position: 250961, velocity: 17000, torque: 310,
+			positionUnit: "0.000717 deg", velocityUnit: "0.00199234 rpm", currentUnit: "16.11328 mA"}),
+		proModel(51200, "H42-20-S300-R", proLimits{position: 151875, velocity: 10300, torque: 465,
+			positionUnit: "0.001185 deg", velocityUnit: "0.00329218 rpm", currentUnit: "4.02832 mA"}),
+	)
+}
+
+// proBaudRates maps the baud rates supported by the PRO series to the value of their Baud Rate register.
+var proBaudRates = map[int]int64{
+	2400:     0,
+	57600:    1,
+	115200:   2,
+	1000000:  3,
+	2000000:  4,
+	3000000:  5,
+	4000000:  6,
+	4500000:  7,
+	10500000: 8,
+}
+
+// proLimits holds the parts of the PRO series control table that differ between models.
+type proLimits struct {
+	position     int64 //The maximum absolute value of the position limits.
+	velocity     int64 //The maximum value of the velocity limit.
+	torque       int64 //The maximum value of the torque limit.
+	positionUnit string
+	velocityUnit string
+	currentUnit  string
+}
+
+func proModel(number uint16, name string, l proLimits) *Model {
+	rs := []Register{
+		{"Model Number", 0, 2, false, ReadOnly, EEPROM, 0, 0xFFFF, ""},
+		{"Model Information", 2, 4, false, ReadOnly, EEPROM, 0, maxUint32, ""},
+		{"Firmware Version", 6, 1, false, ReadOnly, EEPROM, 0, 0xFF, ""},
+		{"ID", 7, 1, false, ReadWrite, EEPROM, 0, 252, ""},
+		{"Baud Rate", 8, 1, false, ReadWrite, EEPROM, 0, 8, ""},
+		{"Return Delay Time", 9, 1, false, ReadWrite, EEPROM, 0, 254, "2 us"},
+		{"Operating Mode", 11, 1, false, ReadWrite, EEPROM, 0, 4, ""},
+		{"Homing Offset", 13, 4, true, ReadWrite, EEPROM, minInt32, maxInt32, l.positionUnit},
+		{"Moving Threshold", 17, 4, false, ReadWrite, EEPROM, 0, l.velocity, l.velocityUnit},
+		{"Temperature Limit", 21, 1, false, ReadWrite, EEPROM, 0, 100, "1 degC"},
+		{"Max Voltage Limit", 22, 2, false, ReadWrite, EEPROM, 150, 400, "0.1 V"},
+		{"Min Voltage Limit", 24, 2, false, ReadWrite, EEPROM, 150, 400, "0.1 V"},
+		{"Acceleration Limit", 26, 4, false, ReadWrite, EEPROM, 0, maxInt32, "58000 rev/min^2"},
+		{"Torque Limit", 30, 2, false, ReadWrite, EEPROM, 0, l.torque, l.currentUnit},
+		{"Velocity Limit", 32, 4, false, ReadWrite, EEPROM, 0, l.velocity, l.velocityUnit},
+		{"Max Position Limit", 36, 4, true, ReadWrite, EEPROM, -l.position, l.position, l.positionUnit},
+		{"Min Position Limit", 40, 4, true, ReadWrite, EEPROM, -l.position, l.position, l.positionUnit},
+		{"External Port Mode 1", 44, 1, false, ReadWrite, EEPROM, 0, 3, ""},
+		{"External Port Mode 2", 45, 1, false, ReadWrite, EEPROM, 0, 3, ""},
+		{"External Port Mode 3", 46, 1, false, ReadWrite, EEPROM, 0, 3, ""},
+		{"External Port Mode 4", 47, 1, false, ReadWrite, EEPROM, 0, 3, ""},
+		{"Shutdown", 48, 1, false, ReadWrite, EEPROM, 0, 63, ""},
+		{"Torque Enable", 562, 1, false, ReadWrite, RAM, 0, 1, ""},
+		{"LED Red", 563, 1, false, ReadWrite, RAM, 0, 0xFF, ""},
+		{"LED Green", 564, 1, false, ReadWrite, RAM, 0, 0xFF, ""},
+		{"LED Blue", 565, 1, false, ReadWrite, RAM, 0, 0xFF, ""},
+		{"Velocity I Gain", 586, 2, false, ReadWrite, RAM, 0, 32767, ""},
+		{"Velocity P Gain", 588, 2, false, ReadWrite, RAM, 0, 32767, ""},
+		{"Position P Gain", 594, 2, false, ReadWrite, RAM, 0, 32767, ""},
+		{"Goal Position", 596, 4, true, ReadWrite, RAM, -l.position, l.position, l.positionUnit},
+		{"Goal Velocity", 600, 4, true, ReadWrite, RAM, -l.velocity, l.velocity, l.velocityUnit},
+		{"Goal Torque", 604, 2, true, ReadWrite, RAM, -l.torque, l.torque, l.currentUnit},
+		{"Goal Acceleration", 606, 4, false, ReadWrite, RAM, 0, maxInt32, "58000 rev/min^2"},
+		{"Moving", 610, 1, false, ReadOnly, RAM, 0, 1, ""},
+		{"Present Position", 611, 4, true, ReadOnly, RAM, minInt32, maxInt32, l.positionUnit},
+		{"Present Velocity", 615, 4, true, ReadOnly, RAM, minInt32, maxInt32, l.velocityUnit},
+		{"Present Current", 621, 2, true, ReadOnly, RAM, -32768, 32767, l.currentUnit},
+		{"Present Input Voltage", 623, 2, false, ReadOnly, RAM, 0, 0xFFFF, "0.1 V"},
+		{"Present Temperature", 625, 1, false, ReadOnly, RAM, 0, 0xFF, "1 degC"},
+		{"External Port Data 1", 626, 2, false, ReadWrite, RAM, 0, 0xFFFF, ""},
+		{"External Port Data 2", 628, 2, false, ReadWrite, RAM, 0, 0xFFFF, ""},
+		{"External Port Data 3", 630, 2, false, ReadWrite, RAM, 0, 0xFFFF, ""},
+		{"External Port Data 4", 632, 2, false, ReadWrite, RAM, 0, 0xFFFF, ""},
+		{"Registered Instruction", 890, 1, false, ReadOnly, RAM, 0, 1, ""},
+		{"Status Return Level", 891, 1, false, ReadWrite, RAM, 0, 2, ""},
+		{"Hardware Error Status", 892, 1, false, ReadOnly, RAM, 0, 0xFF, ""},
+	}
+	return &Model{Number: number, Name: name, Series: "PRO", Registers: rs, BaudRates: proBaudRates}
+}
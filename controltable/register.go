@@ -0,0 +1,62 @@
+package controltable
+
+import (
+	"fmt"
+
+	"github.com/haguro/go-dxl/bus"
+)
+
+// Decode returns the value of the register from its little-endian encoded data, as read from a device. The value of
+// signed registers is sign extended. Values are int64 so that the whole range of 4 byte unsigned registers fits.
+func (r Register) Decode(data []byte) (int64, error) {
+	if len(data) != int(r.Size) {
+		return 0, fmt.Errorf("%d bytes for %s: %w", len(data), r.Name, ErrInvalidDataSize)
+	}
+	var v uint32
+	for i := len(data) - 1; i >= 0; i-- {
+		v = v<<8 | uint32(data[i])
+	}
+	if r.Signed {
+		shift := 32 - 8*uint(r.Size)
+		return int64(int32(v<<shift) >> shift), nil
+	}
+	return int64(v), nil
+}
+
+// Encode returns the little-endian encoded data of the given register value, to be written to a device. It returns
+// ErrOutOfRange if the value is outside the register's range.
+func (r Register) Encode(v int64) ([]byte, error) {
+	if v < r.Min || v > r.Max {
+		return nil, fmt.Errorf("%d for %s (%d to %d): %w", v, r.Name, r.Min, r.Max, ErrOutOfRange)
+	}
+	data := make([]byte, r.Size)
+	for i := range data {
+		data[i] = byte(uint64(v) >> (8 * uint(i)))
+	}
+	return data, nil
+}
+
+// ReadRegister reads the value of the given register from the device with the given ID.
+func ReadRegister(b bus.Bus, id byte, r Register) (int64, error) {
+	data, err := b.Read(id, r.Addr, r.Size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", r.Name, err)
+	}
+	return r.Decode(data)
+}
+
+// WriteRegister writes the given value to the given register of the device with the given ID. The value is checked
+// against the register's range before it is written.
+func WriteRegister(b bus.Bus, id byte, r Register, v int64) error {
+	if r.Access != ReadWrite {
+		return fmt.Errorf("failed to write %s: %w", r.Name, ErrReadOnly)
+	}
+	data, err := r.Encode(v)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", r.Name, err)
+	}
+	if err := b.Write(id, r.Addr, data...); err != nil {
+		return fmt.Errorf("failed to write %s: %w", r.Name, err)
+	}
+	return nil
+}
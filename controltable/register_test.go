@@ -0,0 +1,218 @@
+package controltable
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/haguro/go-dxl/bus"
+)
+
+var errMockBus = errors.New("mock bus error")
+
+// mockBus is a bus.Bus holding the control table of a single device in memory.
+type mockBus struct {
+	id    byte
+	table [1024]byte
+	err   error
+}
+
+func (b *mockBus) Ping(id byte) (bus.PingResponse, error) {
+	return bus.PingResponse{}, errMockBus
+}
+
+func (b *mockBus) Read(id byte, addr, length uint16) ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if id != b.id {
+		return nil, errMockBus
+	}
+	return append([]byte{}, b.table[addr:addr+length]...), nil
+}
+
+func (b *mockBus) Write(id byte, addr uint16, data ...byte) error {
+	if b.err != nil {
+		return b.err
+	}
+	if id != b.id {
+		return errMockBus
+	}
+	copy(b.table[addr:], data)
+	return nil
+}
+
+func (b *mockBus) SyncRead(ids []byte, addr, length uint16) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+
+func (b *mockBus) SyncWrite(addr, length uint16, data ...byte) error {
+	return errMockBus
+}
+
+func (b *mockBus) BulkRead(data []bus.BulkReadDescriptor) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+
+func (b *mockBus) BulkWrite(data []bus.BulkWriteDescriptor) error {
+	return errMockBus
+}
+
+func TestRegisterEncodeDecode(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		register  Register
+		value     int64
+		expData   []byte
+		expErr    error
+		expDecode int64
+	}{
+		{
+			name:     "1 byte unsigned",
+			register: TemperatureLimit,
+			value:    80,
+			expData:  []byte{0x50},
+		},
+		{
+			name:     "2 byte signed, negative",
+			register: GoalPWM,
+			value:    -300,
+			expData:  []byte{0xD4, 0xFE},
+		},
+		{
+			name:     "2 byte unsigned",
+			register: PresentInputVoltage,
+			value:    0xFED4,
+			expData:  []byte{0xD4, 0xFE},
+		},
+		{
+			name:     "4 byte signed, negative",
+			register: GoalPosition,
+			value:    -4096,
+			expData:  []byte{0x00, 0xF0, 0xFF, 0xFF},
+		},
+		{
+			name:     "4 byte signed, positive",
+			register: GoalVelocity,
+			value:    1023,
+			expData:  []byte{0xFF, 0x03, 0x00, 0x00},
+		},
+		{
+			name:     "4 byte unsigned, above max int32",
+			register: ModelInformation,
+			value:    0xFFFFFFFE,
+			expData:  []byte{0xFE, 0xFF, 0xFF, 0xFF},
+		},
+		{
+			name:     "4 byte unsigned, above range",
+			register: ModelInformation,
+			value:    1 << 32,
+			expErr:   ErrOutOfRange,
+		},
+		{
+			name:     "Above range",
+			register: GoalPWM,
+			value:    886,
+			expErr:   ErrOutOfRange,
+		},
+		{
+			name:     "Below range",
+			register: ProfileVelocity,
+			value:    -1,
+			expErr:   ErrOutOfRange,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.register.Encode(tc.value)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.expData) {
+				t.Errorf("Expected %v, got %v", tc.expData, got)
+			}
+			v, err := tc.register.Decode(got)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if v != tc.value {
+				t.Errorf("Expected decoded value %d, got %d", tc.value, v)
+			}
+		})
+	}
+}
+
+func TestRegisterDecodeInvalidSize(t *testing.T) {
+	_, err := PresentPosition.Decode([]byte{0x01, 0x02})
+	if !errors.Is(err, ErrInvalidDataSize) {
+		t.Errorf("Expected error of %q but got %q", ErrInvalidDataSize, err)
+	}
+}
+
+func TestReadWriteRegister(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		register Register
+		value    int64
+		busErr   error
+		expErr   error
+	}{
+		{
+			name:     "Signed register",
+			register: GoalPosition,
+			value:    -2048,
+		},
+		{
+			name:     "Unsigned register",
+			register: ProfileAcceleration,
+			value:    100,
+		},
+		{
+			name:     "Read-only register",
+			register: PresentPosition,
+			value:    0,
+			expErr:   ErrReadOnly,
+		},
+		{
+			name:     "Out of range",
+			register: TorqueEnable,
+			value:    2,
+			expErr:   ErrOutOfRange,
+		},
+		{
+			name:     "Bus error",
+			register: GoalPosition,
+			value:    0,
+			busErr:   errMockBus,
+			expErr:   errMockBus,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &mockBus{id: 0x01, err: tc.busErr}
+			err := WriteRegister(b, 0x01, tc.register, tc.value)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got, err := ReadRegister(b, 0x01, tc.register)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.value {
+				t.Errorf("Expected %d, got %d", tc.value, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package controltable
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/haguro/go-dxl/bus"
+)
+
+var ErrNoUnit = errors.New("register has no unit")
+
+// siUnit describes how to convert the raw value of a register to an SI (or SI derived) unit.
+type siUnit struct {
+	name  string  //The name of the SI unit.
+	scale float64 //The value in the SI unit of one raw value.
+}
+
+// siUnits maps each of the units used in the control tables to its SI unit. The scales are exact where the e-Manual
+// rounds them, e.g. 0.088 deg is one 4096th of a revolution.
+var siUnits = map[string]siUnit{
+	"0.088 deg":         {"rad", 2 * math.Pi / 4096},
+	"0.000717 deg":      {"rad", 2 * math.Pi / 501923},
+	"0.001185 deg":      {"rad", 2 * math.Pi / 303750},
+	"0.229 rpm":         {"rad/s", 0.229 * 2 * math.Pi / 60},
+	"0.00199234 rpm":    {"rad/s", 0.00199234 * 2 * math.Pi / 60},
+	"0.00329218 rpm":    {"rad/s", 0.00329218 * 2 * math.Pi / 60},
+	"214.577 rev/min^2": {"rad/s^2", 214.577 * 2 * math.Pi / 3600},
+	"58000 rev/min^2":   {"rad/s^2", 58000 * 2 * math.Pi / 3600},
+	"1 mA":              {"A", 0.001},
+	"2.69 mA":           {"A", 0.00269},
+	"3.36 mA":           {"A", 0.00336},
+	"16.11328 mA":       {"A", 33.0 / 2048},
+	"4.02832 mA":        {"A", 8.25 / 2048},
+	"0.1 V":             {"V", 0.1},
+	"1 degC":            {"degC", 1},
+	"0.113 %":           {"%", 100.0 / 885},
+	"0.1 %":             {"%", 0.1},
+	"1 ms":              {"s", 0.001},
+	"20 ms":             {"s", 0.02},
+	"2 us":              {"s", 0.000002},
+}
+
+func (r Register) siUnit() (siUnit, error) {
+	u, ok := siUnits[r.Unit]
+	if !ok {
+		return siUnit{}, fmt.Errorf("%s: %w", r.Name, ErrNoUnit)
+	}
+	return u, nil
+}
+
+// SIUnit returns the name of the unit the register's value is converted to by ToSI: "rad" for positions, "rad/s" for
+// velocities, "rad/s^2" for accelerations, "A" for currents, "V" for voltages, "degC" for temperatures, "%" for PWM
+// and load, and "s" for durations.
+func (r Register) SIUnit() (string, error) {
+	u, err := r.siUnit()
+	if err != nil {
+		return "", err
+	}
+	return u.name, nil
+}
+
+// ToSI converts the given raw value of the register to its SI unit (see SIUnit). Positions are converted as is, so
+// a raw position of 0 is 0 rad regardless of where the center of the device's range is.
+// As some units are model specific (e.g. current), the register should be the one of the device's model, as returned
+// by Model.Register.
+func (r Register) ToSI(raw int64) (float64, error) {
+	u, err := r.siUnit()
+	if err != nil {
+		return 0, err
+	}
+	return float64(raw) * u.scale, nil
+}
+
+// FromSI converts the given value in the register's SI unit (see SIUnit) to the nearest raw value. It returns
+// ErrOutOfRange if the raw value is outside the register's range.
+func (r Register) FromSI(v float64) (int64, error) {
+	u, err := r.siUnit()
+	if err != nil {
+		return 0, err
+	}
+	raw := math.Round(v / u.scale)
+	if raw < float64(r.Min) || raw > float64(r.Max) {
+		return 0, fmt.Errorf("%g %s for %s: %w", v, u.name, r.Name, ErrOutOfRange)
+	}
+	return int64(raw), nil
+}
+
+// ReadRegisterSI reads the value of the given register from the device with the given ID and converts it to its SI
+// unit (see SIUnit).
+func ReadRegisterSI(b bus.Bus, id byte, r Register) (float64, error) {
+	if _, err := r.siUnit(); err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", r.Name, err)
+	}
+	raw, err := ReadRegister(b, id, r)
+	if err != nil {
+		return 0, err
+	}
+	return r.ToSI(raw)
+}
+
+// WriteRegisterSI converts the given value in the register's SI unit (see SIUnit) to the nearest raw value and writes
+// it to the given register of the device with the given ID.
+func WriteRegisterSI(b bus.Bus, id byte, r Register, v float64) error {
+	raw, err := r.FromSI(v)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", r.Name, err)
+	}
+	return WriteRegister(b, id, r, raw)
+}
@@ -0,0 +1,170 @@
+package controltable
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestRegisterSIConversion(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		model    uint16
+		register string
+		raw      int64
+		expSI    float64
+		expUnit  string
+		expErr   error
+	}{
+		{
+			name:     "Position, half turn",
+			model:    1020,
+			register: "Present Position",
+			raw:      2048,
+			expSI:    math.Pi,
+			expUnit:  "rad",
+		},
+		{
+			name:     "Position, PRO model",
+			model:    54024,
+			register: "Goal Position",
+			raw:      -250961,
+			expSI:    -250961 * 2 * math.Pi / 501923,
+			expUnit:  "rad",
+		},
+		{
+			name:     "Velocity",
+			model:    1060,
+			register: "Goal Velocity",
+			raw:      -100,
+			expSI:    -100 * 0.229 * 2 * math.Pi / 60,
+			expUnit:  "rad/s",
+		},
+		{
+			name:     "Current, XM430",
+			model:    1020,
+			register: "Goal Current",
+			raw:      1000,
+			expSI:    2.69,
+			expUnit:  "A",
+		},
+		{
+			name:     "Current, XL330",
+			model:    1200,
+			register: "Goal Current",
+			raw:      1000,
+			expSI:    1,
+			expUnit:  "A",
+		},
+		{
+			name:     "Voltage",
+			model:    1120,
+			register: "Present Input Voltage",
+			raw:      120,
+			expSI:    12,
+			expUnit:  "V",
+		},
+		{
+			name:     "Temperature",
+			model:    311,
+			register: "Present Temperature",
+			raw:      45,
+			expSI:    45,
+			expUnit:  "degC",
+		},
+		{
+			name:     "PWM",
+			model:    1010,
+			register: "Goal PWM",
+			raw:      885,
+			expSI:    100,
+			expUnit:  "%",
+		},
+		{
+			name:     "No unit",
+			model:    1020,
+			register: "Operating Mode",
+			expErr:   ErrNoUnit,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := Lookup(tc.model)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			r, err := m.Register(tc.register)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got, err := r.ToSI(tc.raw)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if math.Abs(got-tc.expSI) > 1e-9 {
+				t.Errorf("Expected %v, got %v", tc.expSI, got)
+			}
+			unit, _ := r.SIUnit()
+			if unit != tc.expUnit {
+				t.Errorf("Expected unit %q, got %q", tc.expUnit, unit)
+			}
+			raw, err := r.FromSI(got)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if raw != tc.raw {
+				t.Errorf("Expected raw value %d, got %d", tc.raw, raw)
+			}
+		})
+	}
+}
+
+// TestControlTableUnits checks that every unit used in the control tables can be converted to an SI unit.
+func TestControlTableUnits(t *testing.T) {
+	for _, m := range Models() {
+		for _, r := range m.Registers {
+			if r.Unit == "" {
+				continue
+			}
+			if _, err := r.SIUnit(); err != nil {
+				t.Errorf("%s: %v", m.Name, err)
+			}
+		}
+	}
+}
+
+func TestReadWriteRegisterSI(t *testing.T) {
+	b := &mockBus{id: 0x01}
+	if err := WriteRegisterSI(b, 0x01, GoalPosition, math.Pi/2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	raw, err := ReadRegister(b, 0x01, GoalPosition)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if raw != 1024 {
+		t.Errorf("Expected raw value 1024, got %d", raw)
+	}
+	got, err := ReadRegisterSI(b, 0x01, GoalPosition)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(got-math.Pi/2) > 1e-9 {
+		t.Errorf("Expected %v, got %v", math.Pi/2, got)
+	}
+
+	err = WriteRegisterSI(b, 0x01, GoalPWM, 101)
+	if !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Expected error of %q but got %q", ErrOutOfRange, err)
+	}
+	_, err = ReadRegisterSI(b, 0x01, OperatingMode)
+	if !errors.Is(err, ErrNoUnit) {
+		t.Errorf("Expected error of %q but got %q", ErrNoUnit, err)
+	}
+}
@@ -0,0 +1,180 @@
+package controltable
+
+// The registers of the X series and MX series (Protocol 2.0) control table, which share the same layout. Only the
+// ranges of some registers, and whether the model supports current control, differ between models. The ranges given
+// here are the widest of all the models, and the current registers have no unit as it is model specific. Use
+// Model.Register to get the register of a specific model.
+// See https://emanual.robotis.com/docs/en/dxl/x/xm430-w350/#control-table for an example.
+var (
+	ModelNumber          = Register{"Model Number", 0, 2, false, ReadOnly, EEPROM, 0, 0xFFFF, ""}
+	ModelInformation     = Register{"Model Information", 2, 4, false, ReadOnly, EEPROM, 0, maxUint32, ""}
+	FirmwareVersion      = Register{"Firmware Version", 6, 1, false, ReadOnly, EEPROM, 0, 0xFF, ""}
+	ID                   = Register{"ID", 7, 1, false, ReadWrite, EEPROM, 0, 252, ""}
+	BaudRate             = Register{"Baud Rate", 8, 1, false, ReadWrite, EEPROM, 0, 7, ""}
+	ReturnDelayTime      = Register{"Return Delay Time", 9, 1, false, ReadWrite, EEPROM, 0, 254, "2 us"}
+	DriveMode            = Register{"Drive Mode", 10, 1, false, ReadWrite, EEPROM, 0, 13, ""}
+	OperatingMode        = Register{"Operating Mode", 11, 1, false, ReadWrite, EEPROM, 0, 16, ""}
+	SecondaryID          = Register{"Secondary ID", 12, 1, false, ReadWrite, EEPROM, 0, 0xFF, ""}
+	ProtocolType         = Register{"Protocol Type", 13, 1, false, ReadWrite, EEPROM, 1, 2, ""}
+	HomingOffset         = Register{"Homing Offset", 20, 4, true, ReadWrite, EEPROM, -1044479, 1044479, "0.088 deg"}
+	MovingThreshold      = Register{"Moving Threshold", 24, 4, false, ReadWrite, EEPROM, 0, 1023, "0.229 rpm"}
+	TemperatureLimit     = Register{"Temperature Limit", 31, 1, false, ReadWrite, EEPROM, 0, 100, "1 degC"}
+	MaxVoltageLimit      = Register{"Max Voltage Limit", 32, 2, false, ReadWrite, EEPROM, 31, 160, "0.1 V"}
+	MinVoltageLimit      = Register{"Min Voltage Limit", 34, 2, false, ReadWrite, EEPROM, 31, 160, "0.1 V"}
+	PWMLimit             = Register{"PWM Limit", 36, 2, false, ReadWrite, EEPROM, 0, 885, "0.113 %"}
+	CurrentLimit         = Register{"Current Limit", 38, 2, false, ReadWrite, EEPROM, 0, 2047, ""}
+	VelocityLimit        = Register{"Velocity Limit", 44, 4, false, ReadWrite, EEPROM, 0, 2047, "0.229 rpm"}
+	MaxPositionLimit     = Register{"Max Position Limit", 48, 4, false, ReadWrite, EEPROM, 0, 4095, "0.088 deg"}
+	MinPositionLimit     = Register{"Min Position Limit", 52, 4, false, ReadWrite, EEPROM, 0, 4095, "0.088 deg"}
+	StartupConfiguration = Register{"Startup Configuration", 60, 1, false, ReadWrite, EEPROM, 0, 3, ""}
+	Shutdown             = Register{"Shutdown", 63, 1, false, ReadWrite, EEPROM, 0, 63, ""}
+	TorqueEnable         = Register{"Torque Enable", 64, 1, false, ReadWrite, RAM, 0, 1, ""}
+	LED                  = Register{"LED", 65, 1, false, ReadWrite, RAM, 0, 1, ""}
+	StatusReturnLevel    = Register{"Status Return Level", 68, 1, false, ReadWrite, RAM, 0, 2, ""}
+	RegisteredInst       = Register{"Registered Instruction", 69, 1, false, ReadOnly, RAM, 0, 1, ""}
+	HardwareErrorStatus  = Register{"Hardware Error Status", 70, 1, false, ReadOnly, RAM, 0, 0xFF, ""}
+	VelocityIGain        = Register{"Velocity I Gain", 76, 2, false, ReadWrite, RAM, 0, 16383, ""}
+	VelocityPGain        = Register{"Velocity P Gain", 78, 2, false, ReadWrite, RAM, 0, 16383, ""}
+	PositionDGain        = Register{"Position D Gain", 80, 2, false, ReadWrite, RAM, 0, 16383, ""}
+	PositionIGain        = Register{"Position I Gain", 82, 2, false, ReadWrite, RAM, 0, 16383, ""}
+	PositionPGain        = Register{"Position P Gain", 84, 2, false, ReadWrite, RAM, 0, 16383, ""}
+	Feedforward2ndGain   = Register{"Feedforward 2nd Gain", 88, 2, false, ReadWrite, RAM, 0, 16383, ""}
+	Feedforward1stGain   = Register{"Feedforward 1st Gain", 90, 2, false, ReadWrite, RAM, 0, 16383, ""}
+	BusWatchdog          = Register{"Bus Watchdog", 98, 1, false, ReadWrite, RAM, 0, 127, "20 ms"}
+	GoalPWM              = Register{"Goal PWM", 100, 2, true, ReadWrite, RAM, -885, 885, "0.113 %"}
+	GoalCurrent          = Register{"Goal Current", 102, 2, true, ReadWrite, RAM, -2047, 2047, ""}
+	GoalVelocity         = Register{"Goal Velocity", 104, 4, true, ReadWrite, RAM, -2047, 2047, "0.229 rpm"}
+	ProfileAcceleration  = Register{"Profile Acceleration", 108, 4, false, ReadWrite, RAM, 0, 32767, "214.577 rev/min^2"}
+	ProfileVelocity      = Register{"Profile Velocity", 112, 4, false, ReadWrite, RAM, 0, 32767, "0.229 rpm"}
+	GoalPosition         = Register{"Goal Position", 116, 4, true, ReadWrite, RAM, -1048575, 1048575, "0.088 deg"}
+	RealtimeTick         = Register{"Realtime Tick", 120, 2, false, ReadOnly, RAM, 0, 32767, "1 ms"}
+	Moving               = Register{"Moving", 122, 1, false, ReadOnly, RAM, 0, 1, ""}
+	MovingStatus         = Register{"Moving Status", 123, 1, false, ReadOnly, RAM, 0, 0xFF, ""}
+	PresentPWM           = Register{"Present PWM", 124, 2, true, ReadOnly, RAM, -885, 885, "0.113 %"}
+	PresentCurrent       = Register{"Present Current", 126, 2, true, ReadOnly, RAM, -2047, 2047, ""}
+	PresentLoad          = Register{"Present Load", 126, 2, true, ReadOnly, RAM, -1000, 1000, "0.1 %"}
+	PresentVelocity      = Register{"Present Velocity", 128, 4, true, ReadOnly, RAM, minInt32, maxInt32, "0.229 rpm"}
+	PresentPosition      = Register{"Present Position", 132, 4, true, ReadOnly, RAM, minInt32, maxInt32, "0.088 deg"}
+	VelocityTrajectory   = Register{"Velocity Trajectory", 136, 4, true, ReadOnly, RAM, minInt32, maxInt32, "0.229 rpm"}
+	PositionTrajectory   = Register{"Position Trajectory", 140, 4, true, ReadOnly, RAM, minInt32, maxInt32, "0.088 deg"}
+	PresentInputVoltage  = Register{"Present Input Voltage", 144, 2, false, ReadOnly, RAM, 0, 0xFFFF, "0.1 V"}
+	PresentTemperature   = Register{"Present Temperature", 146, 1, false, ReadOnly, RAM, 0, 0xFF, "1 degC"}
+	BackupReady          = Register{"Backup Ready", 147, 1, false, ReadOnly, RAM, 0, 1, ""}
+)
+
+func init() {
+	register(
+		xModel(1190, "XL330-M077", "X", xLimits{voltage: [2]int64{31, 70}, velocity: 2047, current: 1750, currentUnit: "1 mA", x: true}),
+		xModel(1200, "XL330-M288", "X", xLimits{voltage: [2]int64{31, 70}, velocity: 2047, current: 1750, currentUnit: "1 mA", x: true}),
+		xModel(1060, "XL430-W250", "X", xLimits{voltage: [2]int64{60, 140}, velocity: 1023, x: true}),
+		xModel(1030, "XM430-W210", "X", xLimits{voltage: [2]int64{60, 160}, velocity: 1023, current: 1193, currentUnit: "2.69 mA", x: true}),
+		xModel(1020, "XM430-W350", "X", xLimits{voltage: [2]int64{60, 160}, velocity: 1023, current: 1193, currentUnit: "2.69 mA", x: true}),
+		xModel(1130, "XM540-W150", "X", xLimits{voltage: [2]int64{60, 160}, velocity: 1023, current: 2047, currentUnit: "2.69 mA", x: true}),
+		xModel(1120, "XM540-W270", "X", xLimits{voltage: [2]int64{60, 160}, velocity: 1023, current: 2047, currentUnit: "2.69 mA", x: true}),
+		xModel(1010, "XH430-W210", "X", xLimits{voltage: [2]int64{60, 160}, velocity: 1023, current: 648, currentUnit: "2.69 mA", x: true}),
+		xModel(1000, "XH430-W350", "X", xLimits{voltage: [2]int64{60, 160}, velocity: 1023, current: 648, currentUnit: "2.69 mA", x: true}),
+		xModel(30, "MX-28(2.0)", "MX", xLimits{voltage: [2]int64{95, 160}, velocity: 1023}),
+		xModel(311, "MX-64(2.0)", "MX", xLimits{voltage: [2]int64{95, 160}, velocity: 1023, current: 1941, currentUnit: "3.36 mA"}),
+		xModel(321, "MX-106(2.0)", "MX", xLimits{voltage: [2]int64{95, 160}, velocity: 1023, current: 2047, currentUnit: "3.36 mA"}),
+	)
+}
+
+// xBaudRates maps the baud rates supported by the X and MX series to the value of their Baud Rate register.
+var xBaudRates = map[int]int64{
+	9600:    0,
+	57600:   1,
+	115200:  2,
+	1000000: 3,
+	2000000: 4,
+	3000000: 5,
+	4000000: 6,
+	4500000: 7,
+}
+
+// xLimits holds the parts of the X and MX series control tables that differ between models.
+type xLimits struct {
+	voltage     [2]int64 //The range of the voltage limits.
+	velocity    int64    //The maximum value of the velocity limit.
+	current     int64    //The maximum value of the current limit. Zero if the model has no current control.
+	currentUnit string
+	x           bool //Whether the model has the registers only found in the X series.
+}
+
+func xModel(number uint16, name, series string, l xLimits) *Model {
+	rs := []Register{
+		ModelNumber,
+		ModelInformation,
+		FirmwareVersion,
+		ID,
+		BaudRate,
+		ReturnDelayTime,
+		DriveMode,
+		OperatingMode,
+		SecondaryID,
+		ProtocolType,
+		HomingOffset,
+		MovingThreshold,
+		TemperatureLimit,
+		MaxVoltageLimit.withRange(l.voltage[0], l.voltage[1]),
+		MinVoltageLimit.withRange(l.voltage[0], l.voltage[1]),
+		PWMLimit,
+	}
+	if l.current > 0 {
+		rs = append(rs, CurrentLimit.withRange(0, l.current).withUnit(l.currentUnit))
+	}
+	rs = append(rs,
+		VelocityLimit.withRange(0, l.velocity),
+		MaxPositionLimit,
+		MinPositionLimit,
+	)
+	if l.x {
+		rs = append(rs, StartupConfiguration)
+	}
+	rs = append(rs,
+		Shutdown,
+		TorqueEnable,
+		LED,
+		StatusReturnLevel,
+		RegisteredInst,
+		HardwareErrorStatus,
+		VelocityIGain,
+		VelocityPGain,
+		PositionDGain,
+		PositionIGain,
+		PositionPGain,
+		Feedforward2ndGain,
+		Feedforward1stGain,
+		BusWatchdog,
+		GoalPWM,
+	)
+	if l.current > 0 {
+		rs = append(rs, GoalCurrent.withRange(-l.current, l.current).withUnit(l.currentUnit))
+	}
+	rs = append(rs,
+		GoalVelocity.withRange(-l.velocity, l.velocity),
+		ProfileAcceleration,
+		ProfileVelocity,
+		GoalPosition,
+		RealtimeTick,
+		Moving,
+		MovingStatus,
+		PresentPWM,
+	)
+	if l.current > 0 {
+		rs = append(rs, PresentCurrent.withRange(-l.current, l.current).withUnit(l.currentUnit))
+	} else {
+		rs = append(rs, PresentLoad)
+	}
+	rs = append(rs,
+		PresentVelocity,
+		PresentPosition,
+		VelocityTrajectory,
+		PositionTrajectory,
+		PresentInputVoltage,
+		PresentTemperature,
+	)
+	if l.x {
+		rs = append(rs, BackupReady)
+	}
+	return &Model{Number: number, Name: name, Series: series, Registers: rs, BaudRates: xBaudRates}
+}
@@ -0,0 +1,60 @@
+package dxl2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestByteStuffing(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       []byte
+		expStuffed []byte
+	}{
+		{
+			name:       "No header pattern",
+			data:       []byte{0x01, 0xFF, 0xFD, 0xFF},
+			expStuffed: []byte{0x01, 0xFF, 0xFD, 0xFF},
+		},
+		{
+			name:       "Single header pattern",
+			data:       []byte{0x74, 0x00, 0xFF, 0xFF, 0xFD, 0x00},
+			expStuffed: []byte{0x74, 0x00, 0xFF, 0xFF, 0xFD, 0xFD, 0x00},
+		},
+		{
+			name:       "Header pattern at the end",
+			data:       []byte{0x00, 0xFF, 0xFF, 0xFD},
+			expStuffed: []byte{0x00, 0xFF, 0xFF, 0xFD, 0xFD},
+		},
+		{
+			name:       "Header pattern followed by 0xFD",
+			data:       []byte{0xFF, 0xFF, 0xFD, 0xFD, 0x01},
+			expStuffed: []byte{0xFF, 0xFF, 0xFD, 0xFD, 0xFD, 0x01},
+		},
+		{
+			name:       "Consecutive header patterns",
+			data:       []byte{0xFF, 0xFF, 0xFD, 0xFF, 0xFF, 0xFD},
+			expStuffed: []byte{0xFF, 0xFF, 0xFD, 0xFD, 0xFF, 0xFF, 0xFD, 0xFD},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stuffed := Stuff(tc.data)
+			if !reflect.DeepEqual(stuffed, tc.expStuffed) {
+				t.Errorf("Expected stuffed bytes %v, got %v", tc.expStuffed, stuffed)
+			}
+			destuffed := Destuff(stuffed)
+			if !reflect.DeepEqual(destuffed, tc.data) {
+				t.Errorf("Expected destuffed bytes %v, got %v", tc.data, destuffed)
+			}
+		})
+	}
+}
+
+func TestCRC(t *testing.T) {
+	// The ping instruction packet example from the Protocol 2.0 e-manual.
+	packet := []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x03, 0x00, 0x01}
+	if crc := CRC(packet); crc != 0x4E19 {
+		t.Errorf("Expected CRC 0x4E19, got %#04X", crc)
+	}
+}
@@ -0,0 +1,4 @@
+// Package pty opens pseudo-terminal pairs, which stand in for serial adapters: the slave device can be opened as a
+// serial port while the master side plays the part of the devices on the bus. It is shared by the sim package and the
+// tests of the serial package.
+package pty
@@ -0,0 +1,46 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64 || loong64)
+
+package pty
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Pseudo-terminal ioctls from the asm-generic ioctls header, which the syscall package doesn't define for all
+// architectures. Some architectures (e.g. ppc64 and mips) use different values, hence the build constraint.
+const (
+	tiocgptn   = 0x80045430
+	tiocsptlck = 0x40045431
+)
+
+// Open opens a new pseudo-terminal pair and returns its master side and the name of its slave device (e.g.
+// /dev/pts/3).
+func Open() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	var unlock int32
+	if err := ioctl(master.Fd(), tiocsptlck, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, "", fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+	var n uint32
+	if err := ioctl(master.Fd(), tiocgptn, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, "", fmt.Errorf("failed to get pseudo-terminal number: %w", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+// Package simtest provides test fixtures built on the sim package, shared by the tests of other packages.
+package simtest
+
+import (
+	"testing"
+
+	"github.com/haguro/go-dxl/sim"
+)
+
+// NewBus returns a simulated bus of servos of the given model numbers, with IDs starting at 1. It fails the test if a
+// model number is unknown.
+func NewBus(tb testing.TB, models ...uint16) *sim.Bus {
+	tb.Helper()
+	b := sim.NewBus()
+	for i, m := range models {
+		s, err := sim.NewServo(m, byte(i+1))
+		if err != nil {
+			tb.Fatalf("Unexpected error: %v", err)
+		}
+		b.Attach(s)
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+)
+
+// The errors reported by the device in the error byte of status packets. As more than one error can be reported at
+// once, the returned error is a DeviceError that matches (using errors.Is) each of the errors it reports.
+// See https://emanual.robotis.com/docs/en/dxl/protocol1/#status-packetreturn-packet for more details.
+var (
+	ErrInputVoltage = errors.New("device error - input voltage error")
+	ErrAngleLimit   = errors.New("device error - angle limit error")
+	ErrOverheating  = errors.New("device error - overheating error")
+	ErrRange        = errors.New("device error - range error")
+	ErrChecksum     = errors.New("device error - checksum error")
+	ErrOverload     = errors.New("device error - overload error")
+	ErrInstruction  = errors.New("device error - instruction error")
+)
+
+var (
+	ErrInvalidID             = errors.New("invalid device ID")
+	ErrInstructionTooLong    = errors.New("instruction packet too long")
+	ErrReadTimeout           = errors.New("read wait timeout")
+	ErrTruncatedStatus       = errors.New("status packet truncated")
+	ErrMalformedStatus       = errors.New("malformed status packet")
+	ErrInvalidStatusLength   = errors.New("invalid status packet length value")
+	ErrStatusChecksumInvalid = errors.New("status packet checksum check failed")
+	ErrUnexpectedStatusID    = errors.New("unexpected device ID in status packet")
+)
+
+var (
+	ErrUnexpectedParamCount = errors.New("unexpected parameter count")
+	ErrNoStatusOnBroadcast  = errors.New("instruction does not respond to Broadcast ID")
+	ErrMinOneIDRequired     = errors.New("at least one ID is required")
+	ErrAddressOutOfRange    = errors.New("address or length out of range for Protocol 1.0")
+)
+
+// deviceErrors maps each bit of the status error byte to the error it reports.
+var deviceErrors = [...]error{
+	ErrInputVoltage,
+	ErrAngleLimit,
+	ErrOverheating,
+	ErrRange,
+	ErrChecksum,
+	ErrOverload,
+	ErrInstruction,
+}
+
+// DeviceError is the error byte returned by a device in a status packet. Each bit set reports a different error.
+type DeviceError byte
+
+func (e DeviceError) Error() string {
+	var msgs []string
+	for i, err := range deviceErrors {
+		if e&(1<<i) != 0 {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		return "device error - unknown error"
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// Is reports whether the given target is one of the errors reported by e.
+func (e DeviceError) Is(target error) bool {
+	for i, err := range deviceErrors {
+		if e&(1<<i) != 0 && target == err {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,530 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/haguro/go-dxl/bus"
+)
+
+// Handler provides a high level API for interacting with Dynamixel devices using Protocol 1.0 (e.g. the AX, RX, EX and
+// MX series) over a communication interface. It handles constructing protocol packets, sending instructions, and
+// parsing status responses.
+// A Handler is safe for concurrent use by multiple goroutines. Transactions (an instruction and its status, if any)
+// are serialized so that they don't interleave on the bus.
+type Handler struct {
+	rw          io.ReadWriter
+	readTimeout time.Duration
+	busy        chan struct{}
+}
+
+// PingResponse encapsulates the information returned by a ping instruction.
+type PingResponse = bus.PingResponse
+
+// ReadResult holds the outcome of reading from a single device as part of a multi-device read instruction.
+type ReadResult = bus.ReadResult
+
+// BulkReadDescriptor describes the information required to bulk-read data. Both the address and length must be less
+// than 256.
+type BulkReadDescriptor = bus.BulkReadDescriptor
+
+// BulkWriteDescriptor describes the information required to bulk-write data. It is only defined so that Handler
+// implements bus.Bus, as Protocol 1.0 has no bulk write instruction.
+type BulkWriteDescriptor = bus.BulkWriteDescriptor
+
+// Handler implements the protocol-independent bus.Bus interface.
+var _ bus.Bus = (*Handler)(nil)
+
+// NewHandler creates a new handler for communicating with Dynamixel devices with Protocol 1.0 support.
+func NewHandler(rw io.ReadWriter, readTimeout time.Duration) *Handler {
+	if readTimeout == 0 {
+		readTimeout = 20 * time.Millisecond
+	}
+	return &Handler{
+		rw:          rw,
+		readTimeout: readTimeout,
+		busy:        make(chan struct{}, 1),
+	}
+}
+
+// lock waits until no other transaction is in progress and marks the bus as busy, or returns the context's error if
+// the context is done first.
+func (h *Handler) lock(ctx context.Context) error {
+	select {
+	case h.busy <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlock marks the bus as no longer busy, allowing the next transaction to start.
+func (h *Handler) unlock() {
+	<-h.busy
+}
+
+func (h *Handler) writeInstruction(ctx context.Context, id, command byte, params ...byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	inst := &instruction{id, command, params}
+	packet, err := inst.packetBytes()
+	if err != nil {
+		return fmt.Errorf("failed to create instruction packet: %w", err)
+	}
+	_, err = h.rw.Write(packet)
+	if err != nil {
+		return fmt.Errorf("failed to write instruction packet bytes: %w", err)
+	}
+	return nil
+}
+
+func (h *Handler) readWithTimeout(ctx context.Context, b []byte) (int, error) {
+	var N int
+	timer := time.NewTimer(h.readTimeout)
+	defer timer.Stop()
+	p := make([]byte, 1)
+	for i := 0; i < len(b); i++ {
+		for {
+			n, err := h.rw.Read(p)
+			N += n
+			if err != nil {
+				if err == io.EOF {
+					select {
+					case <-ctx.Done():
+						return N, ctx.Err()
+					case <-timer.C:
+						return N, ErrReadTimeout
+					default:
+						// Nothing to read yet. Let other goroutines (possibly the one feeding rw) run before retrying.
+						runtime.Gosched()
+						continue
+					}
+				}
+				return N, err
+			}
+			break
+		}
+		b[i] = p[0]
+	}
+	return N, nil
+}
+
+func (h *Handler) readStatus(ctx context.Context) (status, error) {
+	packet, err := h.readStatusPacket(ctx)
+	if err != nil {
+		return status{}, err
+	}
+	return parseStatusPacket(packet)
+}
+
+func (h *Handler) readStatusPacket(ctx context.Context) ([]byte, error) {
+	//Find the header pattern in the stream of bytes. As 0xFF is not a valid ID, the first byte following two or more
+	//header bytes is the ID.
+	b := make([]byte, 1)
+	headerBytes := 0
+	for {
+		_, err := h.readWithTimeout(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read status packet header: %w", err)
+		}
+		if b[0] == header1 {
+			headerBytes++
+			continue
+		}
+		if headerBytes >= 2 {
+			// Header found, b holds the ID
+			break
+		}
+		headerBytes = 0
+	}
+	id := b[0]
+
+	_, err := h.readWithTimeout(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status packet length: %w", err)
+	}
+	length := b[0]
+	// It should be impossible for the length value to be less than 2 bytes (error and checksum).
+	// We have to check this again when parsing the packet but we need to stop early if it where to somehow happen.
+	if length < minStatusLengthVal {
+		return nil, ErrInvalidStatusLength
+	}
+
+	packet := make([]byte, 4+int(length))
+	packet[0], packet[1], packet[2], packet[3] = header1, header2, id, length
+	_, err = h.readWithTimeout(ctx, packet[4:]) // error, params and checksum bytes
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status packet error, params and checksum: %w", err)
+	}
+
+	return packet, nil
+}
+
+// readStatuses reads the status packets returned by the devices with the given IDs in response to a multi-device
+// read instruction, and returns one result for each ID. One status packet is read for each ID, and a status packet that
+// could not be read or parsed is attributed to the first device that hasn't responded yet, since devices respond in
+// the order they were given. Only errors that leave the communication interface in an unknown state are returned.
+func (h *Handler) readStatuses(ctx context.Context, ids []byte, lengths []uint16) ([]ReadResult, error) {
+	results := make([]ReadResult, len(ids))
+	done := make([]bool, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+	}
+	// pending returns the index of the first device that hasn't responded yet and has the given ID, or any ID if
+	// anyID is true. It returns -1 if there is no such device.
+	pending := func(id byte, anyID bool) int {
+		for i := range results {
+			if !done[i] && (anyID || results[i].ID == id) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for range ids {
+		r, err := h.readStatus(ctx)
+		if err != nil {
+			if !isStatusErr(err) {
+				return nil, err
+			}
+			i := pending(0, true)
+			results[i].Err, done[i] = err, true
+			continue
+		}
+
+		i := pending(r.id, false)
+		if i < 0 {
+			i = pending(0, true)
+			results[i].Err, done[i] = fmt.Errorf("got status from device ID %d: %w", r.id, ErrUnexpectedStatusID), true
+			continue
+		}
+		done[i] = true
+		if r.err != nil {
+			results[i].Err = r.err
+			continue
+		}
+		if len(r.params) != int(lengths[i]) {
+			results[i].Err = ErrUnexpectedParamCount
+			continue
+		}
+		results[i].Data = r.params
+	}
+
+	return results, nil
+}
+
+// isStatusErr reports whether err was caused by a status packet that failed to arrive in time or was corrupted, as
+// opposed to a failure of the communication interface.
+func isStatusErr(err error) bool {
+	return errors.Is(err, ErrReadTimeout) ||
+		errors.Is(err, ErrTruncatedStatus) ||
+		errors.Is(err, ErrMalformedStatus) ||
+		errors.Is(err, ErrInvalidStatusLength) ||
+		errors.Is(err, ErrStatusChecksumInvalid)
+}
+
+// Ping sends a `ping` instruction to the device with the given ID to check if it is alive and returns the device's
+// model number and firmware version.
+// As Protocol 1.0 devices don't include these in their ping status, they are read from the device's control table
+// as part of the same transaction.
+func (h *Handler) Ping(id byte) (PingResponse, error) {
+	return h.PingContext(context.Background(), id)
+}
+
+// PingContext is like Ping but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) PingContext(ctx context.Context, id byte) (PingResponse, error) {
+	if id == BroadcastID {
+		return PingResponse{}, ErrNoStatusOnBroadcast
+	}
+	if err := h.lock(ctx); err != nil {
+		return PingResponse{}, fmt.Errorf("failed to send ping instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(ctx, id, ping); err != nil {
+		return PingResponse{}, fmt.Errorf("failed to send ping instruction: %w", err)
+	}
+
+	r, err := h.readStatus(ctx)
+	if err != nil {
+		return PingResponse{}, fmt.Errorf("failed to parse ping status: %w", err)
+	}
+
+	if r.err != nil {
+		return PingResponse{}, r.err
+	}
+
+	if len(r.params) != 0 {
+		return PingResponse{}, ErrUnexpectedParamCount
+	}
+
+	// The model number (2 bytes) and firmware version (1 byte) are at the start of the control table of all
+	// Protocol 1.0 devices.
+	if err := h.writeInstruction(ctx, id, read, 0, 3); err != nil {
+		return PingResponse{}, fmt.Errorf("failed to send model number read instruction: %w", err)
+	}
+
+	r, err = h.readStatus(ctx)
+	if err != nil {
+		return PingResponse{}, fmt.Errorf("failed to read/parse model number read status: %w", err)
+	}
+
+	if r.err != nil {
+		return PingResponse{}, r.err
+	}
+
+	if len(r.params) != 3 {
+		return PingResponse{}, ErrUnexpectedParamCount
+	}
+
+	return PingResponse{
+		ID:       r.id,
+		Model:    uint16(r.params[0]) + uint16(r.params[1])<<8,
+		Firmware: r.params[2],
+	}, nil
+}
+
+// Read sends a `read` instruction to the device with the given ID to read a given length of data from the device's
+// control table starting at the given address.
+func (h *Handler) Read(id byte, addr, length uint16) (data []byte, err error) {
+	return h.ReadContext(context.Background(), id, addr, length)
+}
+
+// ReadContext is like Read but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) ReadContext(ctx context.Context, id byte, addr, length uint16) (data []byte, err error) {
+	if id == BroadcastID {
+		return nil, ErrNoStatusOnBroadcast
+	}
+	if addr > 0xFF || length > 0xFF {
+		return nil, ErrAddressOutOfRange
+	}
+	if err := h.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to send read instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(ctx, id, read, byte(addr), byte(length)); err != nil {
+		return nil, fmt.Errorf("failed to send read instruction: %w", err)
+	}
+
+	r, err := h.readStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read/parse read status: %w", err)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if len(r.params) != int(length) {
+		return nil, ErrUnexpectedParamCount
+	}
+
+	return r.params, nil
+}
+
+// Write sends a `write` instruction to the device with the given ID to write the given data to the given address of
+// the device's control table.
+func (h *Handler) Write(id byte, addr uint16, data ...byte) error {
+	return h.WriteContext(context.Background(), id, addr, data...)
+}
+
+// WriteContext is like Write but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) WriteContext(ctx context.Context, id byte, addr uint16, data ...byte) error {
+	if addr > 0xFF {
+		return ErrAddressOutOfRange
+	}
+	params := []byte{byte(addr)}
+	params = append(params, data...)
+
+	if err := h.lock(ctx); err != nil {
+		return fmt.Errorf("failed to send write instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(ctx, id, write, params...); err != nil {
+		return fmt.Errorf("failed to send write instruction: %w", err)
+	}
+	if id != BroadcastID {
+		r, err := h.readStatus(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read/parse write status: %w", err)
+		}
+		if r.err != nil {
+			return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+		}
+	}
+	return nil
+}
+
+// RegWrite sends a `register write` instruction to the device with the given ID to register writing the given data to the
+// given address the next time the 'action' instruction is sent to the device.
+func (h *Handler) RegWrite(id byte, addr uint16, data ...byte) error {
+	if addr > 0xFF {
+		return ErrAddressOutOfRange
+	}
+	params := []byte{byte(addr)}
+	params = append(params, data...)
+
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send reg write instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, regWrite, params...); err != nil {
+		return fmt.Errorf("failed to send reg write instruction: %w", err)
+	}
+
+	if id != BroadcastID {
+		r, err := h.readStatus(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to read/parse reg write status: %w", err)
+		}
+		if r.err != nil {
+			return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+		}
+	}
+	return nil
+}
+
+// Action sends an `action` instruction to the device with the given ID to write the data in the previously registered instruction
+// (with the `regWrite` instruction) to the device's control table.
+func (h *Handler) Action(id byte) error {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send action instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, action); err != nil {
+		return fmt.Errorf("failed to send action instruction: %w", err)
+	}
+
+	if id != BroadcastID {
+		r, err := h.readStatus(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to read/parse action status: %w", err)
+		}
+		if r.err != nil {
+			return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+		}
+	}
+
+	return nil
+}
+
+// FactoryReset sends a `reset` instruction to the device with the given ID to reset the device's control table
+// to its factory default values. Unlike Protocol 2.0, this always resets all values, including the device ID and baud
+// rate.
+func (h *Handler) FactoryReset(id byte) error {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send reset instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, reset); err != nil {
+		return fmt.Errorf("failed to send reset instruction: %w", err)
+	}
+
+	if id != BroadcastID {
+		r, err := h.readStatus(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to read/parse reset status: %w", err)
+		}
+		if r.err != nil {
+			return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+		}
+	}
+
+	return nil
+}
+
+// SyncWrite sends a `sync write` instruction to write data of the given length to the given address in the control
+// tables of multiple devices. The `data` is made of the ID of each device followed by the `length` bytes to write to it.
+func (h *Handler) SyncWrite(addr, length uint16, data ...byte) error {
+	if addr > 0xFF || length > 0xFF {
+		return ErrAddressOutOfRange
+	}
+	params := []byte{byte(addr), byte(length)}
+	params = append(params, data...)
+
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send sync write instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), BroadcastID, syncWrite, params...); err != nil {
+		return fmt.Errorf("failed to send sync write instruction: %w", err)
+	}
+
+	return nil
+}
+
+// SyncRead is not supported by Protocol 1.0 and always returns an *bus.UnsupportedInstructionError. It is only defined
+// so that Handler implements bus.Bus. Use BulkRead (on MX series devices) or Read instead.
+func (h *Handler) SyncRead(ids []byte, addr, length uint16) ([]ReadResult, error) {
+	return nil, &bus.UnsupportedInstructionError{Instruction: "sync read", Protocol: "1.0"}
+}
+
+// BulkRead sends a `bulk read` instruction to one or more devices. This can read data of different lengths from different
+// addresses from different devices. Note that only MX series devices support this instruction.
+// Returns a slice of results, one for each descriptor in `data` and in the same order, holding the data read from the
+// device or the error that prevented it. A failure to read from one device does not prevent the data of the remaining
+// devices from being read, and it is left to the caller to decide whether such failure is fatal.
+// Note that each device ID in the `data` can only be used once.
+func (h *Handler) BulkRead(data []BulkReadDescriptor) ([]ReadResult, error) {
+	return h.BulkReadContext(context.Background(), data)
+}
+
+// BulkReadContext is like BulkRead but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) BulkReadContext(ctx context.Context, data []BulkReadDescriptor) ([]ReadResult, error) {
+	if len(data) < 1 {
+		return nil, ErrMinOneIDRequired
+	}
+	params := []byte{0x00}
+	for _, dd := range data {
+		if dd.Addr > 0xFF || dd.Length > 0xFF {
+			return nil, ErrAddressOutOfRange
+		}
+		params = append(params, byte(dd.Length), dd.ID, byte(dd.Addr))
+	}
+
+	if err := h.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to send bulk read instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(ctx, BroadcastID, bulkRead, params...); err != nil {
+		return nil, fmt.Errorf("failed to send bulk read instruction: %w", err)
+	}
+
+	ids := make([]byte, len(data))
+	lengths := make([]uint16, len(data))
+	for i, dd := range data {
+		ids[i], lengths[i] = dd.ID, dd.Length
+	}
+	results, err := h.readStatuses(ctx, ids, lengths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bulk read status: %w", err)
+	}
+
+	return results, nil
+}
+
+// BulkWrite is not supported by Protocol 1.0 and always returns an *bus.UnsupportedInstructionError. It is only defined
+// so that Handler implements bus.Bus. Use SyncWrite or Write instead.
+func (h *Handler) BulkWrite(data []BulkWriteDescriptor) error {
+	return &bus.UnsupportedInstructionError{Instruction: "bulk write", Protocol: "1.0"}
+}
@@ -0,0 +1,664 @@
+package protocol_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/bus"
+	"github.com/haguro/go-dxl/protocol/v1"
+)
+
+func TestPing(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		deviceID        byte
+		packetDelay     time.Duration
+		delayPosition   int
+		statusError     int
+		errOnRead       bool
+		errOnWrite      bool
+		wrongParamCount bool
+		expectErr       error
+	}{
+		{
+			name:     "No errors, whole packet",
+			deviceID: 0x19,
+		},
+		{
+			name:          "No errors, delayed packet start",
+			deviceID:      0x19,
+			packetDelay:   5 * time.Millisecond,
+			delayPosition: 0,
+		},
+		{
+			name:          "No errors, mid-packet delay",
+			deviceID:      0x19,
+			packetDelay:   5 * time.Millisecond,
+			delayPosition: 3,
+		},
+		{
+			name:      "Ping with Broadcast ID",
+			deviceID:  protocol.BroadcastID,
+			expectErr: protocol.ErrNoStatusOnBroadcast,
+		},
+		{
+			name:        "Device Error",
+			deviceID:    0x19,
+			statusError: 0x20,
+			expectErr:   protocol.ErrOverload,
+		},
+		{
+			name:      "Read Error",
+			deviceID:  0x19,
+			errOnRead: true,
+			expectErr: protocol.ErrMockReadError,
+		},
+		{
+			name:       "Write Error",
+			deviceID:   0x19,
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+		{
+			name:            "Wrong Status Param Count",
+			deviceID:        0x19,
+			wrongParamCount: true,
+			expectErr:       protocol.ErrUnexpectedParamCount,
+		},
+		{
+			name:          "Read Timeout Error, long initial delay",
+			deviceID:      0x19,
+			packetDelay:   15 * time.Millisecond,
+			delayPosition: 0,
+			expectErr:     protocol.ErrReadTimeout,
+		},
+		{
+			name:          "Read Timeout Error, long mid-packet delay",
+			deviceID:      0x19,
+			packetDelay:   15 * time.Millisecond,
+			delayPosition: 3,
+			expectErr:     protocol.ErrReadTimeout,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+				ID:                 int(tc.deviceID),
+				MidPacketDelay:     tc.packetDelay,
+				DelayPosition:      tc.delayPosition,
+				StatusError:        tc.statusError,
+				ErrorOnRead:        tc.errOnRead,
+				ErrorOnWrite:       tc.errOnWrite,
+				SimWrongParamCount: tc.wrongParamCount,
+			})
+			h := protocol.NewHandler(d, 10*time.Millisecond)
+
+			got, err := h.Ping(tc.deviceID)
+			if err != nil {
+				if tc.expectErr == nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got type %q", tc.expectErr, err)
+				}
+				return
+			}
+
+			if tc.expectErr != nil {
+				t.Errorf("Expected error but got none")
+			}
+
+			if got.ID != tc.deviceID {
+				t.Errorf("Expected response from device ID %d, got %d", tc.deviceID, got.ID)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		deviceID        byte
+		addr            uint16
+		statusError     int
+		errOnRead       bool
+		errOnWrite      bool
+		wrongParamCount bool
+		packetDelay     time.Duration
+		delayPosition   int
+		expectErr       error
+	}{
+		{
+			name:     "No errors",
+			deviceID: 0xEA,
+			addr:     3,
+		},
+		{
+			name:          "No errors, delayed packet start",
+			deviceID:      0xEA,
+			addr:          3,
+			packetDelay:   10 * time.Millisecond,
+			delayPosition: 0,
+		},
+		{
+			name:          "No errors, mid-packet delay",
+			deviceID:      0xEA,
+			addr:          3,
+			packetDelay:   10 * time.Millisecond,
+			delayPosition: 6,
+		},
+		{
+			name:      "ReadStatus error with Broadcast ID",
+			deviceID:  protocol.BroadcastID,
+			addr:      3,
+			expectErr: protocol.ErrNoStatusOnBroadcast,
+		},
+		{
+			name:      "Address out of range",
+			deviceID:  0xEA,
+			addr:      0x100,
+			expectErr: protocol.ErrAddressOutOfRange,
+		},
+		{
+			name:        "Device Error",
+			deviceID:    0xEA,
+			addr:        3,
+			statusError: 0x08,
+			expectErr:   protocol.ErrRange,
+		},
+		{
+			name:      "Read Error",
+			deviceID:  0xEA,
+			addr:      3,
+			errOnRead: true,
+			expectErr: protocol.ErrMockReadError,
+		},
+		{
+			name:       "Write Error",
+			deviceID:   0xEA,
+			addr:       3,
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+		{
+			name:            "Wrong Status Param Count",
+			deviceID:        0xEA,
+			addr:            3,
+			wrongParamCount: true,
+			expectErr:       protocol.ErrUnexpectedParamCount,
+		},
+		{
+			name:          "Read Timeout Error, long initial delay",
+			deviceID:      0xEA,
+			addr:          3,
+			packetDelay:   40 * time.Millisecond,
+			delayPosition: 0,
+			expectErr:     protocol.ErrReadTimeout,
+		},
+		{
+			name:          "Read Timeout Error, long mid-packet delay",
+			deviceID:      0xEA,
+			addr:          3,
+			packetDelay:   50 * time.Millisecond,
+			delayPosition: 6,
+			expectErr:     protocol.ErrReadTimeout,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+				ID:                 int(tc.deviceID),
+				MidPacketDelay:     tc.packetDelay,
+				DelayPosition:      tc.delayPosition,
+				StatusError:        tc.statusError,
+				ErrorOnRead:        tc.errOnRead,
+				ErrorOnWrite:       tc.errOnWrite,
+				SimWrongParamCount: tc.wrongParamCount,
+			})
+			h := protocol.NewHandler(d, 0)
+			length := 8
+
+			got, err := h.Read(tc.deviceID, tc.addr, uint16(length))
+			if err != nil {
+				if tc.expectErr == nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got type %q", tc.expectErr, err)
+				}
+				return
+			}
+
+			if tc.expectErr != nil {
+				t.Errorf("Expected error but got none")
+			}
+
+			if len(got) != length {
+				t.Errorf("Expected %d bytes, got %d", length, len(got))
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		deviceID      byte
+		statusError   int
+		errOnRead     bool
+		errOnWrite    bool
+		packetDelay   time.Duration
+		delayPosition int
+		expectErr     error
+	}{
+		{
+			name:     "No errors",
+			deviceID: 0x7A,
+		},
+		{
+			name:     "No errors, Broadcast ID",
+			deviceID: protocol.BroadcastID,
+		},
+		{
+			name:          "No errors, mid-packet delay",
+			deviceID:      0x7A,
+			packetDelay:   50 * time.Millisecond,
+			delayPosition: 4,
+		},
+		{
+			name:        "Device Error",
+			deviceID:    0x7A,
+			statusError: 0x01,
+			expectErr:   protocol.ErrInputVoltage,
+		},
+		{
+			name:      "Read Error",
+			deviceID:  0x7A,
+			errOnRead: true,
+			expectErr: protocol.ErrMockReadError,
+		},
+		{
+			name:       "Write Error",
+			deviceID:   0x7A,
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+		{
+			name:          "Read Timeout Error, long mid-packet delay",
+			deviceID:      0x7A,
+			packetDelay:   110 * time.Millisecond,
+			delayPosition: 4,
+			expectErr:     protocol.ErrReadTimeout,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+				ID:             0x7A,
+				MidPacketDelay: tc.packetDelay,
+				DelayPosition:  tc.delayPosition,
+				StatusError:    tc.statusError,
+				ErrorOnRead:    tc.errOnRead,
+				ErrorOnWrite:   tc.errOnWrite,
+			})
+			h := protocol.NewHandler(d, 100*time.Millisecond)
+			addr := 2
+			data := []byte{0xF1, 0xF2}
+			err := h.Write(tc.deviceID, uint16(addr), data...)
+			if err != nil {
+				if tc.expectErr == nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got type %q", tc.expectErr, err)
+				}
+				return
+			}
+
+			if tc.expectErr != nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestRegWriteAndAction(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		statusError int
+		errOnRead   bool
+		errOnWrite  bool
+		expectErr   error
+	}{
+		{
+			name: "No errors",
+		},
+		{
+			name:        "Device Error",
+			statusError: 0x02,
+			expectErr:   protocol.ErrAngleLimit,
+		},
+		{
+			name:      "Read Error",
+			errOnRead: true,
+			expectErr: protocol.ErrMockReadError,
+		},
+		{
+			name:       "Write Error",
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+	}
+	deviceID := 0x7A
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+				ID:           deviceID,
+				StatusError:  tc.statusError,
+				ErrorOnRead:  tc.errOnRead,
+				ErrorOnWrite: tc.errOnWrite,
+			})
+			h := protocol.NewHandler(d, 0)
+			addr := 2
+			data := []byte{0xF1, 0xF2}
+			for _, op := range []func() error{
+				func() error { return h.RegWrite(byte(deviceID), uint16(addr), data...) },
+				func() error { return h.Action(byte(deviceID)) },
+			} {
+				err := op()
+				if err != nil {
+					if tc.expectErr == nil {
+						t.Errorf("Unexpected error: %v", err)
+						return
+					}
+					if !errors.Is(err, tc.expectErr) {
+						t.Errorf("Expected error of %q but got type %q", tc.expectErr, err)
+					}
+					continue
+				}
+
+				if tc.expectErr != nil {
+					t.Errorf("Expected error but got none")
+				}
+			}
+		})
+	}
+}
+
+func TestFactoryReset(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		statusError int
+		errOnRead   bool
+		errOnWrite  bool
+		expectErr   error
+	}{
+		{
+			name: "No errors",
+		},
+		{
+			name:        "Device Error",
+			statusError: 0x40,
+			expectErr:   protocol.ErrInstruction,
+		},
+		{
+			name:      "Read Error",
+			errOnRead: true,
+			expectErr: protocol.ErrMockReadError,
+		},
+		{
+			name:       "Write Error",
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+	}
+	deviceID := 0x7A
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+				ID:           deviceID,
+				StatusError:  tc.statusError,
+				ErrorOnRead:  tc.errOnRead,
+				ErrorOnWrite: tc.errOnWrite,
+			})
+			h := protocol.NewHandler(d, 0)
+			err := h.FactoryReset(byte(deviceID))
+			if err != nil {
+				if tc.expectErr == nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got type %q", tc.expectErr, err)
+				}
+				return
+			}
+
+			if tc.expectErr != nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestSyncWrite(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		addr       uint16
+		errOnWrite bool
+		expectErr  error
+	}{
+		{
+			name: "No errors",
+			addr: 0x1E,
+		},
+		{
+			name:      "Address out of range",
+			addr:      0x1FE,
+			expectErr: protocol.ErrAddressOutOfRange,
+		},
+		{
+			name:       "Write Error",
+			addr:       0x1E,
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d1 := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x01})
+			d2 := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x02, ErrorOnWrite: tc.errOnWrite})
+			h := protocol.NewHandler(protocol.NewDeviceChain(d1, d2), 0)
+			err := h.SyncWrite(tc.addr, 2, 0x01, 0x10, 0x00, 0x02, 0x20, 0x02)
+			if err != nil {
+				if tc.expectErr == nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got type %q", tc.expectErr, err)
+				}
+				return
+			}
+
+			if tc.expectErr != nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestBulkRead(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		statusError     int
+		errOnRead       bool
+		errOnWrite      bool
+		wrongParamCount bool
+		midNoResponse   bool
+		expectErr       error
+		expectResultErr [3]error
+	}{
+		{
+			name: "No errors",
+		},
+		{
+			name:            "Device Error",
+			statusError:     0x04,
+			expectResultErr: [3]error{nil, nil, protocol.ErrOverheating},
+		},
+		{
+			name:      "Read Error",
+			errOnRead: true,
+			expectErr: protocol.ErrMockReadError,
+		},
+		{
+			name:       "Write Error",
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+		{
+			name:            "Wrong Status Param Count",
+			wrongParamCount: true,
+			expectResultErr: [3]error{nil, nil, protocol.ErrUnexpectedParamCount},
+		},
+		{
+			name:            "No Response mid-chain",
+			midNoResponse:   true,
+			expectResultErr: [3]error{nil, protocol.ErrReadTimeout, nil},
+		},
+		{
+			name:            "No Response mid-chain and Device Error",
+			statusError:     0x04,
+			midNoResponse:   true,
+			expectResultErr: [3]error{nil, protocol.ErrReadTimeout, protocol.ErrOverheating},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config1 := protocol.MockDeviceConfig{
+				ID: 0x5A,
+			}
+			config2 := protocol.MockDeviceConfig{
+				ID: 0x5B,
+			}
+			config3 := protocol.MockDeviceConfig{
+				ID:                 0x5C,
+				StatusError:        tc.statusError,
+				ErrorOnRead:        tc.errOnRead,
+				ErrorOnWrite:       tc.errOnWrite,
+				SimWrongParamCount: tc.wrongParamCount,
+			}
+			d1 := protocol.NewMockDevice(config1)
+			d2 := protocol.NewMockDevice(config2)
+			d3 := protocol.NewMockDevice(config3)
+			c := protocol.NewDeviceChain(d1, d2, d3)
+			if tc.midNoResponse {
+				c = protocol.NewDeviceChain(d1, d3)
+			}
+			h := protocol.NewHandler(c, 0)
+
+			brDesc := []protocol.BulkReadDescriptor{
+				{
+					ID:     byte(config1.ID),
+					Addr:   1,
+					Length: 4,
+				},
+				{
+					ID:     byte(config2.ID),
+					Addr:   4,
+					Length: 10,
+				},
+				{
+					ID:     byte(config3.ID),
+					Addr:   2,
+					Length: 22,
+				},
+			}
+			ids := []byte{byte(config1.ID), byte(config2.ID), byte(config3.ID)}
+			got, err := h.BulkRead(brDesc)
+
+			if err != nil {
+				if tc.expectErr == nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got type %q", tc.expectErr, err)
+				}
+				return
+			}
+			if tc.expectErr != nil {
+				t.Errorf("Expected error but got none")
+			}
+			if len(got) != len(ids) {
+				t.Fatalf("Expected %d results, got %d", len(ids), len(got))
+			}
+			for i, r := range got {
+				if r.ID != ids[i] {
+					t.Errorf("Expected result %d to be of device ID %d, got %d", i+1, ids[i], r.ID)
+				}
+				if tc.expectResultErr[i] != nil {
+					if !errors.Is(r.Err, tc.expectResultErr[i]) {
+						t.Errorf("Expected error of %q from result %d but got %q", tc.expectResultErr[i], i+1, r.Err)
+					}
+					continue
+				}
+				if r.Err != nil {
+					t.Errorf("Unexpected error from result %d: %v", i+1, r.Err)
+				}
+				if len(r.Data) != int(brDesc[i].Length) {
+					t.Errorf("Expected %d bytes from result %d, got %d", int(brDesc[i].Length), i+1, len(r.Data))
+				}
+			}
+		})
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	deviceID := 0x31
+	d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+		ID:             deviceID,
+		MidPacketDelay: 200 * time.Millisecond,
+		DelayPosition:  3,
+	})
+	h := protocol.NewHandler(d, 500*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := h.ReadContext(ctx, byte(deviceID), 3, 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error of %q but got %q", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = h.WriteContext(ctx, byte(deviceID), 3, 0x01)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error of %q but got %q", context.Canceled, err)
+	}
+}
+
+func TestUnsupportedInstructions(t *testing.T) {
+	d := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x01})
+	var b bus.Bus = protocol.NewHandler(d, 0)
+
+	_, err := b.SyncRead([]byte{0x01}, 36, 2)
+	var unsupported *bus.UnsupportedInstructionError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("Expected an unsupported instruction error but got %v", err)
+	}
+	if unsupported.Instruction != "sync read" || unsupported.Protocol != "1.0" {
+		t.Errorf("Unexpected unsupported instruction error: %v", unsupported)
+	}
+
+	err = b.BulkWrite([]bus.BulkWriteDescriptor{{ID: 0x01, Addr: 30, Data: []byte{0x00, 0x02}}})
+	if !errors.Is(err, bus.ErrUnsupportedInstruction) {
+		t.Errorf("Expected error of %q but got %q", bus.ErrUnsupportedInstruction, err)
+	}
+}
@@ -0,0 +1,208 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrMockWriteError = errors.New("mock write error")
+var ErrMockReadError = errors.New("mock read error")
+
+// Buffer is a thread-safe version of the Readwriter implementation of bytes.Buffer
+type Buffer struct {
+	b bytes.Buffer
+	m sync.Mutex
+}
+
+func (b *Buffer) Read(p []byte) (n int, err error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.b.Read(p)
+}
+
+func (b *Buffer) Write(p []byte) (n int, err error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.b.Write(p)
+}
+
+type DeviceChain struct {
+	devices []*MockDevice
+	buf     *Buffer
+}
+
+func NewDeviceChain(devices ...*MockDevice) *DeviceChain {
+	return &DeviceChain{
+		devices: devices,
+		buf:     &Buffer{},
+	}
+}
+
+func (c *DeviceChain) Read(p []byte) (int, error) {
+	for _, d := range c.devices {
+		_, err := io.Copy(c.buf, d)
+		if err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return 0, fmt.Errorf("failed to read from device chain: %w", err)
+		}
+	}
+	return c.buf.Read(p)
+}
+
+func (c *DeviceChain) Write(p []byte) (int, error) {
+	for _, d := range c.devices {
+		_, err := d.Write(p)
+		if err != nil {
+			return 0, fmt.Errorf("failed to write to device chain: %w", err)
+		}
+	}
+	return len(p), nil
+}
+
+type MockDeviceConfig struct {
+	ID                 int
+	MidPacketDelay     time.Duration //Simulate delay occuring while writing status packet
+	DelayPosition      int
+	StatusError        int //The error bits to set in the status packets
+	ErrorOnRead        bool
+	ErrorOnWrite       bool
+	SimWrongParamCount bool
+}
+
+type MockDevice struct {
+	buf             *Buffer
+	id              byte
+	writeDelay      time.Duration
+	delayPos        int
+	errorByte       byte
+	writeErr        error
+	readErr         error
+	wrongParamCount bool
+	padWithGarbage  bool
+}
+
+func NewMockDevice(config MockDeviceConfig) *MockDevice {
+	b := Buffer{}
+	d := MockDevice{
+		buf:             &b,
+		id:              byte(config.ID),
+		writeDelay:      config.MidPacketDelay,
+		delayPos:        config.DelayPosition,
+		errorByte:       byte(config.StatusError),
+		wrongParamCount: config.SimWrongParamCount,
+		padWithGarbage:  true, //Always pad status with garbage to simulate potential leftover bytes or noise in channel
+	}
+	if config.ErrorOnRead {
+		d.readErr = ErrMockReadError
+	}
+	if config.ErrorOnWrite {
+		d.writeErr = ErrMockWriteError
+	}
+	return &d
+}
+
+func (d *MockDevice) Read(p []byte) (int, error) {
+	if d.readErr != nil {
+		return 0, d.readErr
+	}
+	return d.buf.Read(p)
+}
+
+func (d *MockDevice) Write(p []byte) (int, error) {
+	if d.writeErr != nil {
+		return 0, d.writeErr
+	}
+	pLen := len(p)
+	instID := p[2]
+	if instID != BroadcastID && instID != d.id {
+		// Not for us. Ignore.
+		return pLen, nil
+	}
+
+	instLength := int(p[3])
+	instruction := p[4]
+	instParams := p[5 : 5+instLength-2]
+
+	errByte := d.errorByte
+	statusParams := []byte{}
+
+	switch instruction {
+	case ping:
+		//No params in Protocol 1.0 ping status.
+	case read:
+		statusParams = randBytes(int(instParams[1]))
+	case write:
+		//No behaivour to mock.
+	case regWrite:
+		//No behaivour to mock.
+	case action:
+		//No behaivour to mock.
+	case reset:
+		//No behaivour to mock.
+	case syncWrite:
+		//No behaivour to mock.
+	case bulkRead:
+		found := false
+		for i := 1; i < len(instParams); i += 3 {
+			if instParams[i+1] == d.id {
+				statusParams = randBytes(int(instParams[i]))
+				found = true
+			}
+		}
+		if !found {
+			// Nothing for us here. Ignore.
+			return pLen, nil
+		}
+	default:
+		errByte |= 1 << 6 // Instruction error
+	}
+
+	// Only Bulk Read returns status packets when the Broadcast ID is used.
+	if instID != BroadcastID || instruction == bulkRead {
+		if d.wrongParamCount {
+			if len(statusParams) > 1 {
+				statusParams = statusParams[:len(statusParams)-1]
+			}
+			if len(statusParams) <= 1 {
+				statusParams = append(statusParams, randBytes(1)...)
+			}
+		}
+
+		statusPacket := []byte{header1, header2, d.id, byte(len(statusParams) + 2), errByte}
+		statusPacket = append(statusPacket, statusParams...)
+		statusPacket = append(statusPacket, checksum(statusPacket[2:]))
+
+		if d.padWithGarbage {
+			statusPacket = append(randBytes(rand.Intn(6)), statusPacket...)
+			statusPacket = append(statusPacket, randBytes(rand.Intn(6))...)
+		}
+
+		if d.writeDelay > 0 {
+			d.buf.Write(statusPacket[:d.delayPos])
+			go func() {
+				time.Sleep(d.writeDelay)
+				d.buf.Write(statusPacket[d.delayPos:])
+			}()
+			return pLen, nil
+		}
+
+		d.buf.Write(statusPacket)
+	}
+
+	return pLen, nil
+}
+
+func randBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rand.Intn(0xFF))
+	}
+	return b
+}
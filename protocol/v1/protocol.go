@@ -0,0 +1,104 @@
+package protocol
+
+const (
+	minStatusLen       int  = 6
+	minStatusLengthVal byte = 2
+)
+
+const (
+	BroadcastID byte = 0xFE // The device ID used to broadcast messages
+	header1     byte = 0xFF
+	header2     byte = 0xFF
+)
+
+// Command (instruction) codes
+const (
+	ping      byte = 0x01
+	read      byte = 0x02
+	write     byte = 0x03
+	regWrite  byte = 0x04
+	action    byte = 0x05
+	reset     byte = 0x06
+	syncWrite byte = 0x83
+	bulkRead  byte = 0x92
+)
+
+type instruction struct {
+	id      byte
+	command byte
+	params  []byte
+}
+
+type status struct {
+	id     byte
+	err    error
+	params []byte
+}
+
+func (inst *instruction) packetBytes() ([]byte, error) {
+	if inst.id > BroadcastID {
+		return nil, ErrInvalidID
+	}
+	if len(inst.params) > 0xFF-2 {
+		return nil, ErrInstructionTooLong
+	}
+
+	length := 2 + len(inst.params)
+	packet := make([]byte, length+4)
+
+	// Headers, ID, Length
+	packet[0], packet[1], packet[2], packet[3] = header1, header2, inst.id, byte(length)
+	// Command
+	packet[4] = inst.command
+	// Params
+	copy(packet[5:], inst.params)
+	// Checksum
+	packet[len(packet)-1] = checksum(packet[2 : len(packet)-1])
+
+	return packet, nil
+}
+
+func parseStatusPacket(packet []byte) (status, error) {
+	l := len(packet)
+	if l < minStatusLen {
+		return status{}, ErrTruncatedStatus
+	}
+
+	if packet[0] != header1 || packet[1] != header2 {
+		return status{}, ErrMalformedStatus
+	}
+
+	length := packet[3]
+	if length < minStatusLengthVal || int(length) != l-4 {
+		return status{}, ErrInvalidStatusLength
+	}
+
+	if packet[l-1] != checksum(packet[2:l-1]) {
+		return status{}, ErrStatusChecksumInvalid
+	}
+
+	params := make([]byte, length-minStatusLengthVal)
+	copy(params, packet[5:l-1])
+	return status{
+		id:     packet[2],
+		err:    parseStatusErr(packet[4]),
+		params: params,
+	}, nil
+}
+
+func parseStatusErr(errByte byte) error {
+	if errByte == 0 {
+		return nil
+	}
+	return DeviceError(errByte)
+}
+
+// checksum returns the Protocol 1.0 checksum of the given bytes, which are the packet's bytes between the headers
+// and the checksum itself.
+func checksum(b []byte) byte {
+	var sum byte
+	for _, v := range b {
+		sum += v
+	}
+	return ^sum
+}
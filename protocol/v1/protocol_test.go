@@ -0,0 +1,223 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInstructionPacketBytes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		inst     *instruction
+		expErr   error
+		expBytes []byte
+	}{
+		{
+			name: "Invalid ID",
+			inst: &instruction{id: 0xFF,
+				command: ping},
+			expErr: ErrInvalidID,
+		},
+		{
+			name: "Too many params",
+			inst: &instruction{id: BroadcastID,
+				command: syncWrite,
+				params:  make([]byte, 0xFE)},
+			expErr: ErrInstructionTooLong,
+		},
+		{
+			name: "Valid instruction with no params",
+			inst: &instruction{
+				id:      0x01,
+				command: ping,
+			},
+			expBytes: []byte{0xFF, 0xFF, 0x01, 0x02, 0x01, 0xFB},
+		},
+		{
+			name: "Valid instruction with multiple params",
+			inst: &instruction{
+				id:      0x01,
+				command: read,
+				params:  []byte{0x2B, 0x01},
+			},
+			expBytes: []byte{0xFF, 0xFF, 0x01, 0x04, 0x02, 0x2B, 0x01, 0xCC},
+		},
+		{
+			name: "Valid broadcast instruction",
+			inst: &instruction{
+				id:      BroadcastID,
+				command: write,
+				params:  []byte{0x03, 0x01},
+			},
+			expBytes: []byte{0xFF, 0xFF, 0xFE, 0x04, 0x03, 0x03, 0x01, 0xF6},
+		},
+		{
+			name: "Valid instruction with many params",
+			inst: &instruction{
+				id:      BroadcastID,
+				command: syncWrite,
+				params: []byte{0x1E, 0x04, 0x00, 0x10, 0x00, 0x50, 0x01, 0x01, 0x20, 0x02, 0x60, 0x03, 0x02, 0x30,
+					0x00, 0x70, 0x01, 0x03, 0x20, 0x02, 0x80, 0x03},
+			},
+			expBytes: []byte{0xFF, 0xFF, 0xFE, 0x18, 0x83, 0x1E, 0x04, 0x00, 0x10, 0x00, 0x50, 0x01, 0x01, 0x20, 0x02,
+				0x60, 0x03, 0x02, 0x30, 0x00, 0x70, 0x01, 0x03, 0x20, 0x02, 0x80, 0x03, 0x12},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.inst.packetBytes()
+			if tc.expErr != nil {
+				if err == nil {
+					t.Error("Expected error, got nil")
+					return
+				}
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no errors, got %q", err)
+			}
+			if !reflect.DeepEqual(got, tc.expBytes) {
+				t.Errorf("Expected %v, got %v", tc.expBytes, got)
+			}
+		})
+	}
+}
+
+func TestParseStatusPacket(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packetBytes []byte
+		expErr      error
+		expStatus   status
+	}{
+		{
+			name:        "Valid Ping Response",
+			packetBytes: []byte{0xFF, 0xFF, 0x01, 0x02, 0x00, 0xFC},
+			expStatus:   status{id: 1, err: nil, params: []byte{}},
+		},
+		{
+			name:        "Valid Read Response",
+			packetBytes: []byte{0xFF, 0xFF, 0x01, 0x03, 0x00, 0x20, 0xDB},
+			expStatus:   status{id: 1, err: nil, params: []byte{0x20}},
+		},
+		{
+			name:        "Valid Response with Overload Error",
+			packetBytes: []byte{0xFF, 0xFF, 0x01, 0x02, 0x20, 0xDC},
+			expStatus:   status{id: 1, err: ErrOverload, params: []byte{}},
+		},
+		{
+			name:        "Valid Response with Overheating and Input Voltage Errors",
+			packetBytes: []byte{0xFF, 0xFF, 0x01, 0x02, 0x05, 0xF7},
+			expStatus:   status{id: 1, err: DeviceError(0x05), params: []byte{}},
+		},
+		{
+			name:        "Packet Too Short",
+			packetBytes: []byte{0xFF, 0xFF, 0x01, 0x02, 0x00},
+			expErr:      ErrTruncatedStatus,
+		},
+		{
+			name:        "Packet with Invalid Header",
+			packetBytes: []byte{0xFF, 0xFE, 0x01, 0x02, 0x00, 0xFC},
+			expErr:      ErrMalformedStatus,
+		},
+		{
+			name:        "Packet with Incorrect Length Value",
+			packetBytes: []byte{0xFF, 0xFF, 0x01, 0x04, 0x00, 0x20, 0xDB},
+			expErr:      ErrInvalidStatusLength,
+		},
+		{
+			name:        "Packet with Invalid Checksum",
+			packetBytes: []byte{0xFF, 0xFF, 0x01, 0x03, 0x00, 0x20, 0xDC},
+			expErr:      ErrStatusChecksumInvalid,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseStatusPacket(tc.packetBytes)
+			if tc.expErr != nil {
+				if err == nil {
+					t.Error("Expected error, got nil")
+					return
+				}
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no errors, got %q", err)
+			}
+			if got.id != tc.expStatus.id {
+				t.Errorf("Expected id to be %d, got %d", tc.expStatus.id, got.id)
+			}
+			if !errsEqual(got.err, tc.expStatus.err) {
+				t.Errorf("Expected err to be %q, got %q", tc.expStatus.err, got.err)
+			}
+			if !reflect.DeepEqual(got.params, tc.expStatus.params) {
+				t.Errorf("Expected params to be %+v, got %+v", tc.expStatus.params, got.params)
+			}
+		})
+	}
+}
+
+func TestDeviceError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       DeviceError
+		expIs     []error
+		expIsNot  []error
+		expString string
+	}{
+		{
+			name:      "Single error",
+			err:       DeviceError(0x40),
+			expIs:     []error{ErrInstruction},
+			expIsNot:  []error{ErrInputVoltage, ErrOverload},
+			expString: "device error - instruction error",
+		},
+		{
+			name:      "Multiple errors",
+			err:       DeviceError(0x24),
+			expIs:     []error{ErrOverheating, ErrOverload},
+			expIsNot:  []error{ErrRange, ErrChecksum},
+			expString: "device error - overheating error, device error - overload error",
+		},
+		{
+			name:      "Unknown error",
+			err:       DeviceError(0x80),
+			expIsNot:  []error{ErrInputVoltage, ErrAngleLimit, ErrOverheating, ErrRange, ErrChecksum, ErrOverload, ErrInstruction},
+			expString: "device error - unknown error",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, target := range tc.expIs {
+				if !errors.Is(tc.err, target) {
+					t.Errorf("Expected %q to match %q", tc.err, target)
+				}
+			}
+			for _, target := range tc.expIsNot {
+				if errors.Is(tc.err, target) {
+					t.Errorf("Expected %q not to match %q", tc.err, target)
+				}
+			}
+			if tc.err.Error() != tc.expString {
+				t.Errorf("Expected error string %q, got %q", tc.expString, tc.err.Error())
+			}
+		})
+	}
+}
+
+func errsEqual(err1, err2 error) bool {
+	if err1 == nil && err2 == nil {
+		return true
+	}
+	if err1 == nil || err2 == nil {
+		return false
+	}
+	return errors.Is(err1, err2)
+}
@@ -0,0 +1,108 @@
+package protocol_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/haguro/go-dxl/internal/simtest"
+	"github.com/haguro/go-dxl/protocol/v2"
+)
+
+// recorder records the bytes read from the io.ReadWriter it wraps.
+type recorder struct {
+	io.ReadWriter
+	rx []byte
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.ReadWriter.Read(p)
+	r.rx = append(r.rx, p[:n]...)
+	return n, err
+}
+
+// newBenchHandler returns a handler talking to devices that respond to every instruction with the status packets
+// returned by n simulated servos to the instruction sent by do.
+func newBenchHandler(b *testing.B, n int, do func(h *protocol.Handler) error) *protocol.Handler {
+	b.Helper()
+	models := make([]uint16, n)
+	for i := range models {
+		models[i] = 1020
+	}
+	r := &recorder{ReadWriter: simtest.NewBus(b, models...)}
+	if err := do(protocol.NewHandler(r, 0)); err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	h := protocol.NewHandler(&protocol.CannedDevices{Chunks: [][]byte{r.rx}}, 0)
+	if err := do(h); err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	return h
+}
+
+func BenchmarkPing(b *testing.B) {
+	ping := func(h *protocol.Handler) error {
+		_, err := h.Ping(1)
+		return err
+	}
+	h := newBenchHandler(b, 1, ping)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ping(h); err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	read := func(h *protocol.Handler) error {
+		_, err := h.Read(1, 132, 4)
+		return err
+	}
+	h := newBenchHandler(b, 1, read)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := read(h); err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkSyncRead20(b *testing.B) {
+	ids := make([]byte, 20)
+	for i := range ids {
+		ids[i] = byte(i + 1)
+	}
+	syncRead := func(h *protocol.Handler) error {
+		_, err := h.SyncRead(ids, 132, 4)
+		return err
+	}
+	h := newBenchHandler(b, len(ids), syncRead)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := syncRead(h); err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkFastSyncRead20(b *testing.B) {
+	ids := make([]byte, 20)
+	for i := range ids {
+		ids[i] = byte(i + 1)
+	}
+	fastSyncRead := func(h *protocol.Handler) error {
+		_, err := h.FastSyncRead(ids, 132, 4)
+		return err
+	}
+	h := newBenchHandler(b, len(ids), fastSyncRead)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := fastSyncRead(h); err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"errors"
+	"fmt"
 )
 
 // The errors returned by the device if the processing of the instruction fails.
@@ -24,10 +25,53 @@ var (
 	ErrMalformedStatus     = errors.New("malformed status packet")
 	ErrInvalidStatusLength = errors.New("invalid status packet length value")
 	ErrStatusCRCInvalid    = errors.New("status packet crc check failed")
+	ErrUnexpectedStatusID  = errors.New("unexpected device ID in status packet")
 )
 
 var (
 	ErrUnexpectedParamCount = errors.New("unexpected parameter count")
 	ErrNoStatusOnBroadcast  = errors.New("instruction does not respond to Broadcast ID")
 	ErrMinOneIDRequired     = errors.New("at least one ID is required")
+	ErrDuplicateID          = errors.New("more than one device with the same ID")
 )
+
+// DuplicateIDError is returned by BroadcastPing when more than one device appears to use the same ID. It matches
+// ErrDuplicateID (using errors.Is).
+type DuplicateIDError struct {
+	IDs []byte //The IDs used by more than one device.
+}
+
+func (e *DuplicateIDError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrDuplicateID, e.IDs)
+}
+
+// Is reports whether target is ErrDuplicateID.
+func (e *DuplicateIDError) Is(target error) bool {
+	return target == ErrDuplicateID
+}
+
+// RetryCancelledError is returned when the context is done while waiting to retry a failed transaction. It matches
+// both the error of the last attempt and the context's error (using errors.Is and errors.As).
+type RetryCancelledError struct {
+	Err    error //The error of the last attempt.
+	CtxErr error //The context's error.
+}
+
+func (e *RetryCancelledError) Error() string {
+	return fmt.Sprintf("%v, retry cancelled: %v", e.Err, e.CtxErr)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryCancelledError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the context's error matches target.
+func (e *RetryCancelledError) Is(target error) bool {
+	return errors.Is(e.CtxErr, target)
+}
+
+// As finds the first error in the context's error chain that matches target.
+func (e *RetryCancelledError) As(target interface{}) bool {
+	return errors.As(e.CtxErr, target)
+}
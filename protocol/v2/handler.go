@@ -1,9 +1,15 @@
 package protocol
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"time"
+
+	"github.com/haguro/go-dxl/bus"
 )
 
 const (
@@ -22,31 +28,44 @@ const (
 // Handler provides a high level API for interacting with Dynamixel devices
 // over a communication interface. It handles constructing protocol packets,
 // sending instructions, and parsing status responses.
+// A Handler is safe for concurrent use by multiple goroutines. Transactions (an instruction and its status, if any)
+// are serialized so that they don't interleave on the bus.
+// Status packets are read from the communication interface in chunks, as much as is available at a time, and any bytes
+// read past the end of a status packet are kept for the next one.
 type Handler struct {
 	rw          io.ReadWriter
 	readTimeout time.Duration
+	busy        chan struct{}
+	counters    stats
+	command     byte      // The instruction of the transaction in progress.
+	expected    byte      // The ID of the device expected to send the next status packet.
+	sentAt      time.Time // The time the instruction packet of the transaction in progress was written.
+	retryPolicy RetryPolicy
+	rx          []byte // The receive buffer, holding the bytes read that are yet to be parsed in rx[start:end].
+	start, end  int
+	stale       bool // Whether a status packet failed to arrive in time or to parse since the transaction started.
 }
 
+// rxBufferSize is the initial size of a handler's receive buffer, which is grown as needed to fit longer packets.
+const rxBufferSize = 1024
+
+// statusHeader is the header of a status packet (and any other packet).
+var statusHeader = []byte{header1, header2, header3, headerR}
+
 // PingResponse encapsulates the information returned by a ping instruction.
-type PingResponse struct {
-	ID       byte
-	Model    uint16
-	Firmware byte
-}
+type PingResponse = bus.PingResponse
 
-// BulkRDescriptor describes the information required to bulk-read data.
-type BulkReadDescriptor struct {
-	ID     byte   //The ID of the device to read from.
-	Addr   uint16 //The starting address to read from.
-	Length uint16 //The number of bytes to read.
-}
+// ReadResult holds the outcome of reading from a single device as part of a multi-device read instruction.
+type ReadResult = bus.ReadResult
 
-// BulkWDescriptor describes the information required to bulk-write data.
-type BulkWriteDescriptor struct {
-	ID   byte   //The ID of the device to write to.
-	Addr uint16 //The starting address to write to.
-	Data []byte //The data to write.
-}
+// BulkReadDescriptor describes the information required to bulk-read data.
+type BulkReadDescriptor = bus.BulkReadDescriptor
+
+// BulkWriteDescriptor describes the information required to bulk-write data.
+type BulkWriteDescriptor = bus.BulkWriteDescriptor
+
+// Handler implements the protocol-independent bus.Bus interface.
+var _ bus.Bus = (*Handler)(nil)
 
 // NewHandler creates a new handler for communicating with Dynamixel devices
 // with Protocol 2.0 support.
@@ -57,10 +76,37 @@ func NewHandler(rw io.ReadWriter, readTimeout time.Duration) *Handler {
 	return &Handler{
 		rw:          rw,
 		readTimeout: readTimeout,
+		busy:        make(chan struct{}, 1),
 	}
 }
 
-func (h *Handler) writeInstruction(id, command byte, params ...byte) error {
+// lock waits until no other transaction is in progress and marks the bus as busy, or returns the context's error if
+// the context is done first.
+// If a status packet of the previous transaction failed to arrive in time or to parse, any bytes left in the receive
+// buffer (such as the status packet that arrived late, followed by the one sent in response to a retry) are dropped so
+// that they aren't taken as the response to the next instruction. Otherwise they are kept for the next read.
+func (h *Handler) lock(ctx context.Context) error {
+	select {
+	case h.busy <- struct{}{}:
+		if h.stale {
+			h.discard()
+			h.stale = false
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlock marks the bus as no longer busy, allowing the next transaction to start.
+func (h *Handler) unlock() {
+	<-h.busy
+}
+
+func (h *Handler) writeInstruction(ctx context.Context, id, command byte, params ...byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	inst := &instruction{id, command, params}
 	packet, err := inst.packetBytes()
 	if err != nil {
@@ -70,159 +116,434 @@ func (h *Handler) writeInstruction(id, command byte, params ...byte) error {
 	if err != nil {
 		return fmt.Errorf("failed to write instruction packet bytes: %w", err)
 	}
+	h.command, h.expected, h.sentAt = command, id, time.Now()
+	h.counters.sent(id, command, len(packet))
 	return nil
 }
 
-func (h *Handler) readWithTimeout(b []byte) (int, error) {
-	var N int
-	timer := time.NewTimer(h.readTimeout)
-	defer timer.Stop()
-	p := make([]byte, 1)
-	for i := 0; i < len(b); i++ {
-		for {
-			n, err := h.rw.Read(p)
-			N += n
-			if err != nil {
-				if err == io.EOF {
-					select {
-					case <-timer.C:
-						return N, ErrReadTimeout
-					default:
-						continue
-					}
-				}
-				return N, err
-			}
-			break
+// fill reads from the communication interface into the receive buffer until it holds at least n unread bytes. It
+// returns ErrReadTimeout if no more bytes arrive within the read timeout, or the context's error if the context is
+// done first.
+func (h *Handler) fill(ctx context.Context, n int) error {
+	if h.end-h.start >= n {
+		return nil
+	}
+	// Move the unread bytes to the start of the buffer, growing it if they won't fit.
+	if n > len(h.rx) {
+		size := 2 * len(h.rx)
+		if size < rxBufferSize {
+			size = rxBufferSize
+		}
+		for size < n {
+			size *= 2
+		}
+		rx := make([]byte, size)
+		h.end = copy(rx, h.rx[h.start:h.end])
+		h.rx, h.start = rx, 0
+	} else if h.start+n > len(h.rx) {
+		h.end = copy(h.rx, h.rx[h.start:h.end])
+		h.start = 0
+	}
+
+	deadline := time.Now().Add(h.readTimeout)
+	for h.end-h.start < n {
+		m, err := h.rw.Read(h.rx[h.end:])
+		if m > 0 {
+			h.end += m
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
 		}
-		b[i] = p[0]
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if time.Now().After(deadline) {
+			return ErrReadTimeout
+		}
+		// Nothing to read yet. Let other goroutines (possibly the one feeding rw) run before retrying.
+		runtime.Gosched()
 	}
-	return N, nil
+	return nil
 }
 
-func (h *Handler) readStatus() (status, error) {
-	var packet []byte
+// discard drops the unread bytes of the receive buffer, e.g. those of a status packet that failed to arrive in full,
+// so that they aren't mistaken for part of the next one.
+func (h *Handler) discard() {
+	h.start, h.end = 0, 0
+}
 
-	//Find the header pattern in the stream of bytes
+// findStatusHeader reads from the communication interface until the receive buffer starts with a packet header,
+// discarding any bytes before it.
+func (h *Handler) findStatusHeader(ctx context.Context) error {
 	for {
-		b := make([]byte, 1)
-		_, err := h.readWithTimeout(b)
-		if err != nil {
-			return status{}, fmt.Errorf("failed to read status packet header: %w", err)
-		}
-
-		packet = append(packet, b[0])
-		hl := len(packet)
-		if hl >= 4 {
-			// Check if the last n bytes match pattern
-			if packet[hl-4] == header1 &&
-				packet[hl-3] == header2 &&
-				packet[hl-2] == header3 &&
-				packet[hl-1] == headerR {
-				// Header found, break out of loop
-				break
-			}
-			// No match, drop first byte and continue
-			packet = packet[1:]
+		i := bytes.Index(h.rx[h.start:h.end], statusHeader)
+		if i >= 0 {
+			h.start += i
+			return nil
+		}
+		// No match, drop all but the bytes that could be the start of the header and read more.
+		if h.end-h.start >= len(statusHeader) {
+			h.start = h.end - (len(statusHeader) - 1)
+		}
+		if err := h.fill(ctx, h.end-h.start+1); err != nil {
+			h.discard()
+			h.stale = true
+			return fmt.Errorf("failed to read status packet header: %w", err)
 		}
 	}
+}
 
-	idLength := make([]byte, 3)
-	_, err := h.readWithTimeout(idLength)
-	if err != nil {
-		return status{}, fmt.Errorf("failed to read status packet ID and Length: %w", err)
+// readStatusPacket reads the next status packet from the communication interface, discarding any bytes before its
+// header. The returned packet is only valid until the next read, as it refers to the receive buffer.
+func (h *Handler) readStatusPacket(ctx context.Context) ([]byte, error) {
+	if err := h.findStatusHeader(ctx); err != nil {
+		return nil, err
 	}
-	length := uint16(idLength[1]) + uint16(idLength[2])<<8
+
+	if err := h.fill(ctx, minStatusLen-4); err != nil {
+		h.discard()
+		h.stale = true
+		return nil, fmt.Errorf("failed to read status packet ID and Length: %w", err)
+	}
+	length := uint16(h.rx[h.start+5]) + uint16(h.rx[h.start+6])<<8
 	// It should be impossible for the length value to be less than 4 bytes (instruction, error, crc(low)
 	// and crc(high)).
 	// We have to check this again when parsing the packet but we need to stop early if it where to somehow happen.
 	if length < 4 {
-		return status{}, ErrInvalidStatusLength
+		// Skip the header so that the next read looks for the next packet.
+		h.start += len(statusHeader)
+		h.stale = true
+		return nil, ErrInvalidStatusLength
+	}
+
+	// instruction, error, params and crc bytes
+	if err := h.fill(ctx, 7+int(length)); err != nil {
+		h.discard()
+		h.stale = true
+		return nil, fmt.Errorf("failed to read status packet instruction, error, params and crc: %w", err)
 	}
-	packet = append(packet, idLength...)
+	packet := h.rx[h.start : h.start+7+int(length)]
+	h.start += len(packet)
+	return packet, nil
+}
 
-	instErrParamsCRC := make([]byte, length) // instruction, error, params and crc bytes
-	_, err = h.readWithTimeout(instErrParamsCRC)
+// readStatusFrom reads the status packet of the device with the given ID (any device may respond to the Broadcast ID),
+// returning ErrUnexpectedStatusID if another device sent it.
+func (h *Handler) readStatusFrom(ctx context.Context, id byte) (status, error) {
+	s, err := h.readStatus(ctx)
 	if err != nil {
-		return status{}, fmt.Errorf("failed to read status packet instruction, error, params and crc: %w", err)
+		return status{}, err
+	}
+	if id != BroadcastID && s.id != id {
+		return status{}, fmt.Errorf("got status from device ID %d: %w", s.id, ErrUnexpectedStatusID)
+	}
+	return s, nil
+}
+
+func (h *Handler) readStatus(ctx context.Context) (status, error) {
+	packet, err := h.readStatusPacket(ctx)
+	if err != nil {
+		h.observeStatus(h.expected, nil, err)
+		return status{}, err
+	}
+	s, err := parseStatusPacket(packet)
+	if err != nil {
+		h.stale = true
+		h.observeStatus(h.expected, packet, err)
+		return status{}, err
+	}
+	h.observeStatus(s.id, packet, s.err)
+	return s, nil
+}
+
+// readStatuses reads the status packets returned by the devices with the given IDs in response to a multi-device
+// read instruction, and returns one result for each ID. One status packet is read for each ID, and a status packet that
+// could not be read or parsed is attributed to the first device that hasn't responded yet, since devices respond in
+// the order they were given. Only errors that leave the communication interface in an unknown state are returned.
+func (h *Handler) readStatuses(ctx context.Context, ids []byte, lengths []uint16) ([]ReadResult, error) {
+	results := make([]ReadResult, len(ids))
+	done := make([]bool, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+		h.counters.sent(id, h.command, 0)
+	}
+	// pending returns the index of the first device that hasn't responded yet and has the given ID, or any ID if
+	// anyID is true. It returns -1 if there is no such device.
+	pending := func(id byte, anyID bool) int {
+		for i := range results {
+			if !done[i] && (anyID || results[i].ID == id) {
+				return i
+			}
+		}
+		return -1
 	}
-	packet = append(packet, instErrParamsCRC...)
 
-	return parseStatusPacket(packet)
+	for range ids {
+		h.expected = results[pending(0, true)].ID
+		r, err := h.readStatus(ctx)
+		if err != nil {
+			if !isStatusErr(err) {
+				return nil, err
+			}
+			i := pending(0, true)
+			results[i].Err, done[i] = err, true
+			continue
+		}
+
+		i := pending(r.id, false)
+		if i < 0 {
+			i = pending(0, true)
+			results[i].Err, done[i] = fmt.Errorf("got status from device ID %d: %w", r.id, ErrUnexpectedStatusID), true
+			continue
+		}
+		done[i] = true
+		if r.err != nil {
+			results[i].Err = r.err
+			continue
+		}
+		if len(r.params) != int(lengths[i]) {
+			results[i].Err = ErrUnexpectedParamCount
+			continue
+		}
+		results[i].Data = r.params
+	}
+
+	return results, nil
+}
+
+// isStatusErr reports whether err was caused by a status packet that failed to arrive in time, was corrupted or came
+// from the wrong device, as opposed to a failure of the communication interface.
+func isStatusErr(err error) bool {
+	return errors.Is(err, ErrReadTimeout) ||
+		errors.Is(err, ErrTruncatedStatus) ||
+		errors.Is(err, ErrMalformedStatus) ||
+		errors.Is(err, ErrInvalidStatusLength) ||
+		errors.Is(err, ErrStatusCRCInvalid) ||
+		errors.Is(err, ErrUnexpectedStatusID)
 }
 
 // Ping sends a `ping` instruction to the device with the given ID to check if it is alive and returns the device's
-// model number and firmware version.
+// model number and firmware version. Only the first response is returned if the Broadcast ID is used; use BroadcastPing
+// to get the responses of all devices.
 func (h *Handler) Ping(id byte) (PingResponse, error) {
-	if err := h.writeInstruction(id, ping); err != nil {
+	return h.PingContext(context.Background(), id)
+}
+
+// PingContext is like Ping but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) PingContext(ctx context.Context, id byte) (PingResponse, error) {
+	if err := h.lock(ctx); err != nil {
 		return PingResponse{}, fmt.Errorf("failed to send ping instruction: %w", err)
 	}
+	defer h.unlock()
+
+	var resp PingResponse
+	err := h.retry(ctx, false, func() error {
+		if err := h.writeInstruction(ctx, id, ping); err != nil {
+			return fmt.Errorf("failed to send ping instruction: %w", err)
+		}
 
-	r, err := h.readStatus()
+		r, err := h.readStatusFrom(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to parse ping status: %w", err)
+		}
+
+		if r.err != nil {
+			return r.err
+		}
+
+		if len(r.params) != 3 {
+			return ErrUnexpectedParamCount
+		}
+
+		resp = PingResponse{
+			ID:       r.id,
+			Model:    uint16(r.params[0]) + uint16(r.params[1])<<8,
+			Firmware: r.params[2],
+		}
+		return nil
+	})
 	if err != nil {
-		return PingResponse{}, fmt.Errorf("failed to parse ping status: %w", err)
+		return PingResponse{}, err
+	}
+	return resp, nil
+}
+
+// BroadcastPing sends a `ping` instruction to all devices (using the Broadcast ID) and returns the responses of every
+// device on the bus, in the order they were received (which is normally by ascending ID). Status packets are read until
+// none arrives within the handler's read timeout. Responses that report a processing error (e.g. a hardware alert) are
+// still returned, as the device is present, but their model number and firmware version are zero if the device didn't
+// include them.
+// If more than one device responds with the same ID, or responses are corrupted (as happens when devices with the same
+// ID talk over each other), the responses received are returned along with a *DuplicateIDError listing the IDs
+// involved.
+func (h *Handler) BroadcastPing() ([]PingResponse, error) {
+	return h.BroadcastPingContext(context.Background())
+}
+
+// BroadcastPingContext is like BroadcastPing but uses the given context to cancel the transaction or limit its
+// duration while waiting for the statuses. If the context is done before the transaction completes, the returned
+// error wraps the context's error.
+func (h *Handler) BroadcastPingContext(ctx context.Context) ([]PingResponse, error) {
+	if err := h.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to send ping instruction: %w", err)
 	}
+	defer h.unlock()
 
-	if r.err != nil {
-		return PingResponse{}, r.err
+	if err := h.writeInstruction(ctx, BroadcastID, ping); err != nil {
+		return nil, fmt.Errorf("failed to send ping instruction: %w", err)
 	}
 
-	if len(r.params) != 3 {
-		return PingResponse{}, ErrUnexpectedParamCount
+	var responses []PingResponse
+	var duplicates []byte
+	seen := map[byte]bool{}
+	addDuplicate := func(id byte) {
+		for _, d := range duplicates {
+			if d == id {
+				return
+			}
+		}
+		duplicates = append(duplicates, id)
+	}
+	for {
+		// Only a timeout while waiting for the next packet means all devices have responded. A timeout after a header
+		// is a packet cut short (or given a garbled length) by devices talking over each other.
+		if err := h.findStatusHeader(ctx); err != nil {
+			if errors.Is(err, ErrReadTimeout) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read ping status: %w", err)
+		}
+		if err := h.fill(ctx, len(statusHeader)+1); err != nil {
+			h.discard()
+			if errors.Is(err, ErrReadTimeout) {
+				// Not even the ID arrived, so there is no device to attribute the packet to.
+				continue
+			}
+			return nil, fmt.Errorf("failed to read ping status: %w", err)
+		}
+		id := h.rx[h.start+len(statusHeader)]
+
+		packet, err := h.readStatusPacket(ctx)
+		if err != nil {
+			if !isStatusErr(err) {
+				return nil, fmt.Errorf("failed to read ping status: %w", err)
+			}
+			h.observeStatus(id, nil, err)
+			addDuplicate(id)
+			continue
+		}
+		r, err := parseStatusPacket(packet)
+		if err != nil {
+			h.observeStatus(id, packet, err)
+		} else {
+			h.observeStatus(r.id, packet, r.err)
+		}
+		if err != nil || (r.err == nil && len(r.params) != 3) {
+			// Devices with the same ID respond at the same time, corrupting each other's status packets.
+			addDuplicate(id)
+			continue
+		}
+		if seen[r.id] {
+			addDuplicate(r.id)
+		}
+		seen[r.id] = true
+		resp := PingResponse{ID: r.id}
+		if len(r.params) == 3 {
+			resp.Model, resp.Firmware = uint16(r.params[0])+uint16(r.params[1])<<8, r.params[2]
+		}
+		responses = append(responses, resp)
 	}
 
-	return PingResponse{
-		ID:       r.id,
-		Model:    uint16(r.params[0]) + uint16(r.params[1])<<8,
-		Firmware: r.params[2],
-	}, nil
+	if len(duplicates) > 0 {
+		return responses, &DuplicateIDError{IDs: duplicates}
+	}
+	return responses, nil
 }
 
 // Read sends a `read` instruction to the device with the given ID to read a given length of data from the device's
 // control table starting at the given address.
 func (h *Handler) Read(id byte, addr, length uint16) (data []byte, err error) {
+	return h.ReadContext(context.Background(), id, addr, length)
+}
+
+// ReadContext is like Read but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) ReadContext(ctx context.Context, id byte, addr, length uint16) (data []byte, err error) {
 	if id == BroadcastID {
 		return nil, ErrNoStatusOnBroadcast
 	}
-	if err := h.writeInstruction(id, read, byte(addr), byte(addr>>8), byte(length), byte(length>>8)); err != nil {
+	if err := h.lock(ctx); err != nil {
 		return nil, fmt.Errorf("failed to send read instruction: %w", err)
 	}
+	defer h.unlock()
 
-	r, err := h.readStatus()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read/parse read status: %w", err)
-	}
+	err = h.retry(ctx, false, func() error {
+		params := []byte{byte(addr), byte(addr >> 8), byte(length), byte(length >> 8)}
+		if err := h.writeInstruction(ctx, id, read, params...); err != nil {
+			return fmt.Errorf("failed to send read instruction: %w", err)
+		}
 
-	if r.err != nil {
-		return nil, r.err
-	}
+		r, err := h.readStatusFrom(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to read/parse read status: %w", err)
+		}
 
-	if len(r.params) != int(length) {
-		return nil, ErrUnexpectedParamCount
-	}
+		if r.err != nil {
+			return r.err
+		}
 
-	return r.params, nil
+		if len(r.params) != int(length) {
+			return ErrUnexpectedParamCount
+		}
+
+		data = r.params
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 // Write sends a `write` instruction to the device with the given ID to write the given data to the given address of
 // the device's control table.
 func (h *Handler) Write(id byte, addr uint16, data ...byte) error {
+	return h.WriteContext(context.Background(), id, addr, data...)
+}
+
+// WriteContext is like Write but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) WriteContext(ctx context.Context, id byte, addr uint16, data ...byte) error {
 	params := []byte{byte(addr), byte(addr >> 8)}
 	params = append(params, data...)
 
-	if err := h.writeInstruction(id, write, params...); err != nil {
+	if err := h.lock(ctx); err != nil {
 		return fmt.Errorf("failed to send write instruction: %w", err)
 	}
-	if id != BroadcastID {
-		r, err := h.readStatus()
-		if err != nil {
-			return fmt.Errorf("failed to read/parse write status: %w", err)
+	defer h.unlock()
+
+	return h.retry(ctx, true, func() error {
+		if err := h.writeInstruction(ctx, id, write, params...); err != nil {
+			return fmt.Errorf("failed to send write instruction: %w", err)
 		}
-		if r.err != nil {
-			return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+		if id != BroadcastID {
+			r, err := h.readStatusFrom(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to read/parse write status: %w", err)
+			}
+			if r.err != nil {
+				return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // RegWrite sends a `register write` instruction to the device with the given ID to register writing the given data to the
@@ -231,31 +552,43 @@ func (h *Handler) RegWrite(id byte, addr uint16, data ...byte) error {
 	params := []byte{byte(addr), byte(addr >> 8)}
 	params = append(params, data...)
 
-	if err := h.writeInstruction(id, regWrite, params...); err != nil {
+	if err := h.lock(context.Background()); err != nil {
 		return fmt.Errorf("failed to send reg write instruction: %w", err)
 	}
+	defer h.unlock()
 
-	if id != BroadcastID {
-		r, err := h.readStatus()
-		if err != nil {
-			return fmt.Errorf("failed to read/parse reg write status: %w", err)
+	return h.retry(context.Background(), true, func() error {
+		if err := h.writeInstruction(context.Background(), id, regWrite, params...); err != nil {
+			return fmt.Errorf("failed to send reg write instruction: %w", err)
 		}
-		if r.err != nil {
-			return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+
+		if id != BroadcastID {
+			r, err := h.readStatusFrom(context.Background(), id)
+			if err != nil {
+				return fmt.Errorf("failed to read/parse reg write status: %w", err)
+			}
+			if r.err != nil {
+				return fmt.Errorf("device ID %d returned error: %w", id, r.err)
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // Action sends an `action` instruction to the device with the given ID to write the data in the previously registered instruction
 // (with the `regWrite` instruction) to the device's control table.
 func (h *Handler) Action(id byte) error {
-	if err := h.writeInstruction(id, action); err != nil {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send action instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, action); err != nil {
 		return fmt.Errorf("failed to send action instruction: %w", err)
 	}
 
 	if id != BroadcastID {
-		r, err := h.readStatus()
+		r, err := h.readStatusFrom(context.Background(), id)
 		if err != nil {
 			return fmt.Errorf("failed to read/parse action status: %w", err)
 		}
@@ -269,12 +602,17 @@ func (h *Handler) Action(id byte) error {
 
 // Reboot sends a `reboot` instruction to the device with the given ID to reboot the device.
 func (h *Handler) Reboot(id byte) error {
-	if err := h.writeInstruction(id, reboot); err != nil {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send reboot instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, reboot); err != nil {
 		return fmt.Errorf("failed to send reboot instruction: %w", err)
 	}
 
 	if id != BroadcastID {
-		r, err := h.readStatus()
+		r, err := h.readStatusFrom(context.Background(), id)
 		if err != nil {
 			return fmt.Errorf("failed to read/parse reboot status: %w", err)
 		}
@@ -295,12 +633,17 @@ func (h *Handler) Reboot(id byte) error {
 //
 // Note that using the `ResetAll` option cannot be used with BroadcastID.
 func (h *Handler) FactoryReset(id, option byte) error {
-	if err := h.writeInstruction(id, reset, option); err != nil {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send reset instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, reset, option); err != nil {
 		return fmt.Errorf("failed to send reset instruction: %w", err)
 	}
 
 	if id != BroadcastID {
-		r, err := h.readStatus()
+		r, err := h.readStatusFrom(context.Background(), id)
 		if err != nil {
 			return fmt.Errorf("failed to read/parse reset status: %w", err)
 		}
@@ -318,12 +661,17 @@ func (h *Handler) FactoryReset(id, option byte) error {
 // - `ClearMultiRotationPos`: Resets the Present Position value to an absolute value within one rotation (0-4095).lear the status packet.
 // Note that this can only be applied when the device is stopped.
 func (h *Handler) Clear(id, option byte) error {
-	if err := h.writeInstruction(id, clear, option, 0x44, 0x58, 0x4C, 0x22); err != nil {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send clear instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, clear, option, 0x44, 0x58, 0x4C, 0x22); err != nil {
 		return fmt.Errorf("failed to send clear instruction: %w", err)
 	}
 
 	if id != BroadcastID {
-		r, err := h.readStatus()
+		r, err := h.readStatusFrom(context.Background(), id)
 		if err != nil {
 			return fmt.Errorf("failed to read/parse clear status: %w", err)
 		}
@@ -344,12 +692,17 @@ func (h *Handler) Clear(id, option byte) error {
 //
 // Note that this will only work if the device is in Torque OFF mode.
 func (h *Handler) ControlTableBackup(id byte, option byte) error {
-	if err := h.writeInstruction(id, backup, option, 0x43, 0x54, 0x52, 0x4C); err != nil {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send backup instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), id, backup, option, 0x43, 0x54, 0x52, 0x4C); err != nil {
 		return fmt.Errorf("failed to send backup instruction: %w", err)
 	}
 
 	if id != BroadcastID {
-		r, err := h.readStatus()
+		r, err := h.readStatusFrom(context.Background(), id)
 		if err != nil {
 			return fmt.Errorf("failed to read/parse backup status: %w", err)
 		}
@@ -363,31 +716,62 @@ func (h *Handler) ControlTableBackup(id byte, option byte) error {
 
 // SyncRead sends a `sync read` instruction to the device(s) with the given IDs to read a given length of data from the
 // given address from each of the device's control tables.
-// Returns a slice of slices of bytes where each inner slice is the data read each the device's control table.
-func (h *Handler) SyncRead(ids []byte, addr, length uint16) ([][]byte, error) {
+// Returns a slice of results, one for each of the given IDs and in the same order, holding the data read from the
+// device's control table or the error that prevented it. A device that fails to respond or responds with an error does
+// not prevent the data of the remaining devices from being read, and it is left to the caller to decide whether such
+// failure is fatal. The returned error is only non-nil if the instruction could not be sent or the communication
+// interface itself failed.
+func (h *Handler) SyncRead(ids []byte, addr, length uint16) ([]ReadResult, error) {
+	return h.SyncReadContext(context.Background(), ids, addr, length)
+}
+
+// SyncReadContext is like SyncRead but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) SyncReadContext(ctx context.Context, ids []byte, addr, length uint16) ([]ReadResult, error) {
+	if err := h.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to send sync read instruction: %w", err)
+	}
+	defer h.unlock()
+
+	results, err := h.syncRead(ctx, ids, addr, length)
+	if err != nil {
+		return nil, err
+	}
+	err = h.retryReads(ctx, results, func(indexes []int) ([]ReadResult, error) {
+		retryIDs := make([]byte, len(indexes))
+		for i, j := range indexes {
+			retryIDs[i] = ids[j]
+		}
+		return h.syncRead(ctx, retryIDs, addr, length)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// syncRead sends a `sync read` instruction and reads the statuses of the devices (see SyncRead). The lock must be
+// held.
+func (h *Handler) syncRead(ctx context.Context, ids []byte, addr, length uint16) ([]ReadResult, error) {
 	params := []byte{byte(addr), byte(addr >> 8), byte(length), byte(length >> 8)}
 	params = append(params, ids...)
 
-	if err := h.writeInstruction(BroadcastID, syncRead, params...); err != nil {
+	if err := h.writeInstruction(ctx, BroadcastID, syncRead, params...); err != nil {
 		return nil, fmt.Errorf("failed to send sync read instruction: %w", err)
 	}
 
-	var responses [][]byte
-	for range ids {
-		r, err := h.readStatus()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read/parse sync read status: %w", err)
-		}
-		if r.err != nil {
-			return nil, r.err
-		}
-		if len(r.params) != int(length) {
-			return nil, ErrUnexpectedParamCount
-		}
-		responses = append(responses, r.params)
+	lengths := make([]uint16, len(ids))
+	for i := range lengths {
+		lengths[i] = length
+	}
+	results, err := h.readStatuses(ctx, ids, lengths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync read status: %w", err)
 	}
 
-	return responses, nil
+	return results, nil
 }
 
 // SyncWrite sends a `sync write` instruction to the device(s) with the given IDs to write the given data to the
@@ -396,7 +780,12 @@ func (h *Handler) SyncWrite(addr, length uint16, data ...byte) error {
 	params := []byte{byte(addr), byte(addr >> 8), byte(length), byte(length >> 8)}
 	params = append(params, data...)
 
-	if err := h.writeInstruction(BroadcastID, syncWrite, params...); err != nil {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send sync write instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), BroadcastID, syncWrite, params...); err != nil {
 		return fmt.Errorf("failed to send sync write instruction: %w", err)
 	}
 
@@ -405,10 +794,44 @@ func (h *Handler) SyncWrite(addr, length uint16, data ...byte) error {
 
 // BulkRead sends a `bulk read` instruction to one or more devices. This can read data of different lengths from different
 // addresses from different devices.
-// Returns a slice of slices of bytes where each inner slice is the data read from the device. The order of the slices
-// corresponds to the order of each device ID in the `data` slice.
+// Returns a slice of results, one for each descriptor in `data` and in the same order, holding the data read from the
+// device or the error that prevented it. As with `SyncRead`, a failure to read from one device does not prevent the
+// data of the remaining devices from being read.
 // Note that each device ID in the `data` can only be used once.
-func (h *Handler) BulkRead(data []BulkReadDescriptor) ([][]byte, error) {
+func (h *Handler) BulkRead(data []BulkReadDescriptor) ([]ReadResult, error) {
+	return h.BulkReadContext(context.Background(), data)
+}
+
+// BulkReadContext is like BulkRead but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) BulkReadContext(ctx context.Context, data []BulkReadDescriptor) ([]ReadResult, error) {
+	if err := h.lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to send bulk read instruction: %w", err)
+	}
+	defer h.unlock()
+
+	results, err := h.bulkRead(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	err = h.retryReads(ctx, results, func(indexes []int) ([]ReadResult, error) {
+		retryData := make([]BulkReadDescriptor, len(indexes))
+		for i, j := range indexes {
+			retryData[i] = data[j]
+		}
+		return h.bulkRead(ctx, retryData)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// bulkRead sends a `bulk read` instruction and reads the statuses of the devices (see BulkRead). The lock must be
+// held.
+func (h *Handler) bulkRead(ctx context.Context, data []BulkReadDescriptor) ([]ReadResult, error) {
 	params := []byte{}
 	for _, dd := range data {
 		params = append(params,
@@ -416,25 +839,21 @@ func (h *Handler) BulkRead(data []BulkReadDescriptor) ([][]byte, error) {
 			byte(dd.Length), byte(dd.Length>>8))
 	}
 
-	if err := h.writeInstruction(BroadcastID, bulkRead, params...); err != nil {
+	if err := h.writeInstruction(ctx, BroadcastID, bulkRead, params...); err != nil {
 		return nil, fmt.Errorf("failed to send bulk read instruction: %w", err)
 	}
 
-	var responses [][]byte
-	for _, dd := range data {
-		r, err := h.readStatus()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read/parse bulk read status: %w", err)
-		}
-		if r.err != nil {
-			return nil, r.err
-		}
-		if len(r.params) != int(dd.Length) {
-			return nil, ErrUnexpectedParamCount
-		}
-		responses = append(responses, r.params)
+	ids := make([]byte, len(data))
+	lengths := make([]uint16, len(data))
+	for i, dd := range data {
+		ids[i], lengths[i] = dd.ID, dd.Length
 	}
-	return responses, nil
+	results, err := h.readStatuses(ctx, ids, lengths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bulk read status: %w", err)
+	}
+
+	return results, nil
 }
 
 // BulkWrite sends a `bulk write` instruction to one or more devices. This can write data of different lengths
@@ -450,7 +869,12 @@ func (h *Handler) BulkWrite(data []BulkWriteDescriptor) error {
 		params = append(params, dd.Data...)
 	}
 
-	if err := h.writeInstruction(BroadcastID, bulkWrite, params...); err != nil {
+	if err := h.lock(context.Background()); err != nil {
+		return fmt.Errorf("failed to send bulk write instruction: %w", err)
+	}
+	defer h.unlock()
+
+	if err := h.writeInstruction(context.Background(), BroadcastID, bulkWrite, params...); err != nil {
 		return fmt.Errorf("failed to send bulk write instruction: %w", err)
 	}
 
@@ -460,8 +884,16 @@ func (h *Handler) BulkWrite(data []BulkWriteDescriptor) error {
 // FastSyncRead sends a `fast sync read` instruction to the device(s) with the given IDs to read a given length of data from the
 // given address from each of the device's control tables.
 // Returns a slice of slices of bytes where each inner slice is the data read each the device's control table.
-// The signature is identical to `SyncRead` although this is marginally faster
+// Unlike `SyncRead`, all devices respond with a single status packet, so this is marginally faster but the data is
+// only returned if it was read successfully from all devices.
 func (h *Handler) FastSyncRead(ids []byte, addr, length uint16) ([][]byte, error) {
+	return h.FastSyncReadContext(context.Background(), ids, addr, length)
+}
+
+// FastSyncReadContext is like FastSyncRead but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) FastSyncReadContext(ctx context.Context, ids []byte, addr, length uint16) ([][]byte, error) {
 	if len(ids) < 1 {
 		return nil, ErrMinOneIDRequired
 	}
@@ -469,31 +901,45 @@ func (h *Handler) FastSyncRead(ids []byte, addr, length uint16) ([][]byte, error
 	params := []byte{byte(addr), byte(addr >> 8), byte(length), byte(length >> 8)}
 	params = append(params, ids...)
 
-	if err := h.writeInstruction(BroadcastID, fastSyncRead, params...); err != nil {
+	if err := h.lock(ctx); err != nil {
 		return nil, fmt.Errorf("failed to send fast sync read instruction: %w", err)
 	}
+	defer h.unlock()
 
-	r, err := h.readStatus()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read/parse fast sync read status: %w", err)
-	}
+	var responses [][]byte
+	err := h.retry(ctx, false, func() error {
+		if err := h.writeInstruction(ctx, BroadcastID, fastSyncRead, params...); err != nil {
+			return fmt.Errorf("failed to send fast sync read instruction: %w", err)
+		}
 
-	if r.err != nil {
-		return nil, r.err
-	}
+		packet, err := h.readStatusPacket(ctx)
+		if err != nil {
+			h.observeStatus(BroadcastID, nil, err)
+			return fmt.Errorf("failed to read/parse fast sync read status: %w", err)
+		}
 
-	if len(r.params) != int(length)+(len(ids)-1)*(int(length)+4)+1 {
-		return nil, ErrUnexpectedParamCount //TODO likely need a seeperate error value here for malformed FSR response
-	}
+		lengths := make([]uint16, len(ids))
+		for i := range lengths {
+			lengths[i] = length
+		}
+		segments, err := parseFastStatusPacket(packet, ids, lengths)
+		h.stale = h.stale || err != nil
+		h.observeFastStatus(packet, segments, err)
+		if err != nil {
+			return fmt.Errorf("failed to read/parse fast sync read status: %w", err)
+		}
 
-	responses := make([][]byte, len(ids))
-	start := 1
-	end := start + int(length)
-	responses[0] = r.params[start:end]
-	for i := 1; i < len(ids); i++ {
-		start = end + 4 //Skip the previous CRC bytes as well as the error and ID bytes
-		end = start + int(length)
-		responses[i] = r.params[start:end]
+		responses = make([][]byte, len(segments))
+		for i, seg := range segments {
+			if seg.err != nil {
+				return fmt.Errorf("device ID %d returned error: %w", seg.id, seg.err)
+			}
+			responses[i] = seg.params
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return responses, nil
@@ -502,8 +948,16 @@ func (h *Handler) FastSyncRead(ids []byte, addr, length uint16) ([][]byte, error
 // FastBulkRead sends a `fast bulk read` instruction to the device(s) with the given IDs to read a given length of data from the
 // given address from each of the device's control tables. Lengths and addresses can be different for each device.
 // Returns a slice of slices of bytes where each inner slice is the data read each the device's control table.
-// The signature is identical to `BulkRead` although this should be marginally faster
+// Unlike `BulkRead`, all devices respond with a single status packet, so this should be marginally faster but the data
+// is only returned if it was read successfully from all devices.
 func (h *Handler) FastBulkRead(data []BulkReadDescriptor) ([][]byte, error) {
+	return h.FastBulkReadContext(context.Background(), data)
+}
+
+// FastBulkReadContext is like FastBulkRead but uses the given context to cancel the transaction or limit its duration while
+// waiting for the status. If the context is done before the transaction completes, the returned error wraps the
+// context's error.
+func (h *Handler) FastBulkReadContext(ctx context.Context, data []BulkReadDescriptor) ([][]byte, error) {
 	if len(data) < 1 {
 		return nil, ErrMinOneIDRequired
 	}
@@ -515,37 +969,47 @@ func (h *Handler) FastBulkRead(data []BulkReadDescriptor) ([][]byte, error) {
 			byte(dd.Length), byte(dd.Length>>8))
 	}
 
-	if err := h.writeInstruction(BroadcastID, fastBulkRead, params...); err != nil {
+	if err := h.lock(ctx); err != nil {
 		return nil, fmt.Errorf("failed to send fast bulk read instruction: %w", err)
 	}
+	defer h.unlock()
 
-	r, err := h.readStatus()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read/parse fast bulk read status: %w", err)
-	}
+	var responses [][]byte
+	err := h.retry(ctx, false, func() error {
+		if err := h.writeInstruction(ctx, BroadcastID, fastBulkRead, params...); err != nil {
+			return fmt.Errorf("failed to send fast bulk read instruction: %w", err)
+		}
 
-	if r.err != nil {
-		return nil, r.err
-	}
+		packet, err := h.readStatusPacket(ctx)
+		if err != nil {
+			h.observeStatus(BroadcastID, nil, err)
+			return fmt.Errorf("failed to read/parse fast bulk read status: %w", err)
+		}
 
-	statusParamsLength := int(data[0].Length) + 1
-	for _, dd := range data[1:] {
-		statusParamsLength += int(dd.Length) + 4
-	}
-	if len(r.params) != statusParamsLength {
-		return nil, ErrUnexpectedParamCount //TODO likely need a seeperate error value here for malformed FBR response
-	}
+		ids := make([]byte, len(data))
+		lengths := make([]uint16, len(data))
+		for i, dd := range data {
+			ids[i], lengths[i] = dd.ID, dd.Length
+		}
+		segments, err := parseFastStatusPacket(packet, ids, lengths)
+		h.stale = h.stale || err != nil
+		h.observeFastStatus(packet, segments, err)
+		if err != nil {
+			return fmt.Errorf("failed to read/parse fast bulk read status: %w", err)
+		}
 
-	responses := make([][]byte, len(data))
-	start := 1
-	end := start + int(data[0].Length)
-	responses[0] = r.params[start:end]
-	for i := 1; i < len(data); i++ {
-		start = end + 4 //Skip the previous CRC bytes as well as the error and ID bytes
-		end = start + int(data[i].Length)
-		responses[i] = r.params[start:end]
+		responses = make([][]byte, len(segments))
+		for i, seg := range segments {
+			if seg.err != nil {
+				return fmt.Errorf("device ID %d returned error: %w", seg.id, seg.err)
+			}
+			responses[i] = seg.params
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return responses, nil
-
 }
@@ -1,8 +1,12 @@
 package protocol_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"sync"
 	"testing"
 	"time"
 
@@ -637,7 +641,9 @@ func TestSyncRead(t *testing.T) {
 		errOnRead       bool
 		errOnWrite      bool
 		wrongParamCount bool
+		midNoResponse   bool
 		expectErr       error
+		expectResultErr [3]error
 	}{
 		{
 			name: "No errors",
@@ -645,7 +651,7 @@ func TestSyncRead(t *testing.T) {
 		{
 			name:            "Device Error",
 			processingError: 0x80,
-			expectErr:       protocol.ErrDeviceError,
+			expectResultErr: [3]error{nil, nil, protocol.ErrDeviceError},
 		},
 		{
 			name:      "Read Error",
@@ -660,7 +666,18 @@ func TestSyncRead(t *testing.T) {
 		{
 			name:            "Wrong Status Param Count",
 			wrongParamCount: true,
-			expectErr:       protocol.ErrUnexpectedParamCount,
+			expectResultErr: [3]error{nil, nil, protocol.ErrUnexpectedParamCount},
+		},
+		{
+			name:            "No Response mid-chain",
+			midNoResponse:   true,
+			expectResultErr: [3]error{nil, protocol.ErrReadTimeout, nil},
+		},
+		{
+			name:            "No Response mid-chain and Device Error",
+			processingError: 0x80,
+			midNoResponse:   true,
+			expectResultErr: [3]error{nil, protocol.ErrReadTimeout, protocol.ErrDeviceError},
 		},
 	}
 	for _, tc := range testCases {
@@ -682,6 +699,9 @@ func TestSyncRead(t *testing.T) {
 			d2 := protocol.NewMockDevice(config2)
 			d3 := protocol.NewMockDevice(config3)
 			c := protocol.NewDeviceChain(d1, d2, d3)
+			if tc.midNoResponse {
+				c = protocol.NewDeviceChain(d1, d3)
+			}
 			h := protocol.NewHandler(c, 0)
 
 			addr, length := 51, 12
@@ -701,12 +721,24 @@ func TestSyncRead(t *testing.T) {
 			if tc.expectErr != nil {
 				t.Errorf("Expected error but got none")
 			}
-			if len(got) != 3 {
-				t.Errorf("Expected 3 responses, got %d", len(got))
+			if len(got) != len(ids) {
+				t.Fatalf("Expected %d results, got %d", len(ids), len(got))
 			}
-			for i, v := range got {
-				if len(v) != length {
-					t.Errorf("Expected %d bytes from response %d, got %d", length, i+1, len(v))
+			for i, r := range got {
+				if r.ID != ids[i] {
+					t.Errorf("Expected result %d to be of device ID %d, got %d", i+1, ids[i], r.ID)
+				}
+				if tc.expectResultErr[i] != nil {
+					if !errors.Is(r.Err, tc.expectResultErr[i]) {
+						t.Errorf("Expected error of %q from result %d but got %q", tc.expectResultErr[i], i+1, r.Err)
+					}
+					continue
+				}
+				if r.Err != nil {
+					t.Errorf("Unexpected error from result %d: %v", i+1, r.Err)
+				}
+				if len(r.Data) != length {
+					t.Errorf("Expected %d bytes from result %d, got %d", length, i+1, len(r.Data))
 				}
 			}
 		})
@@ -786,7 +818,9 @@ func TestBulkRead(t *testing.T) {
 		errOnRead       bool
 		errOnWrite      bool
 		wrongParamCount bool
+		midNoResponse   bool
 		expectErr       error
+		expectResultErr [3]error
 	}{
 		{
 			name: "No errors",
@@ -794,7 +828,7 @@ func TestBulkRead(t *testing.T) {
 		{
 			name:            "Device Error",
 			processingError: 0x80,
-			expectErr:       protocol.ErrDeviceError,
+			expectResultErr: [3]error{nil, nil, protocol.ErrDeviceError},
 		},
 		{
 			name:      "Read Error",
@@ -809,7 +843,18 @@ func TestBulkRead(t *testing.T) {
 		{
 			name:            "Wrong Status Param Count",
 			wrongParamCount: true,
-			expectErr:       protocol.ErrUnexpectedParamCount,
+			expectResultErr: [3]error{nil, nil, protocol.ErrUnexpectedParamCount},
+		},
+		{
+			name:            "No Response mid-chain",
+			midNoResponse:   true,
+			expectResultErr: [3]error{nil, protocol.ErrReadTimeout, nil},
+		},
+		{
+			name:            "No Response mid-chain and Device Error",
+			processingError: 0x80,
+			midNoResponse:   true,
+			expectResultErr: [3]error{nil, protocol.ErrReadTimeout, protocol.ErrDeviceError},
 		},
 	}
 	for _, tc := range testCases {
@@ -831,6 +876,9 @@ func TestBulkRead(t *testing.T) {
 			d2 := protocol.NewMockDevice(config2)
 			d3 := protocol.NewMockDevice(config3)
 			c := protocol.NewDeviceChain(d1, d2, d3)
+			if tc.midNoResponse {
+				c = protocol.NewDeviceChain(d1, d3)
+			}
 			l := protocol.NewPacketLogger(c, protocol.LogReadWrite, io.Discard)
 			h := protocol.NewHandler(l, 0)
 
@@ -851,6 +899,7 @@ func TestBulkRead(t *testing.T) {
 					Length: 22,
 				},
 			}
+			ids := []byte{byte(config1.ID), byte(config2.ID), byte(config3.ID)}
 			got, err := h.BulkRead(brDesc)
 
 			if err != nil {
@@ -866,13 +915,24 @@ func TestBulkRead(t *testing.T) {
 			if tc.expectErr != nil {
 				t.Errorf("Expected error but got none")
 			}
-
-			if len(got) != len(brDesc) {
-				t.Fatalf("Expected all %d devices to return status, only %d did", len(brDesc), len(got))
+			if len(got) != len(ids) {
+				t.Fatalf("Expected %d results, got %d", len(ids), len(got))
 			}
-			for i, v := range got {
-				if len(v) != int(brDesc[i].Length) {
-					t.Errorf("Expected %d bytes from response %d, got %d", int(brDesc[i].Length), i+1, len(v))
+			for i, r := range got {
+				if r.ID != ids[i] {
+					t.Errorf("Expected result %d to be of device ID %d, got %d", i+1, ids[i], r.ID)
+				}
+				if tc.expectResultErr[i] != nil {
+					if !errors.Is(r.Err, tc.expectResultErr[i]) {
+						t.Errorf("Expected error of %q from result %d but got %q", tc.expectResultErr[i], i+1, r.Err)
+					}
+					continue
+				}
+				if r.Err != nil {
+					t.Errorf("Unexpected error from result %d: %v", i+1, r.Err)
+				}
+				if len(r.Data) != int(brDesc[i].Length) {
+					t.Errorf("Expected %d bytes from result %d, got %d", int(brDesc[i].Length), i+1, len(r.Data))
 				}
 			}
 		})
@@ -957,6 +1017,8 @@ func TestFastSyncRead(t *testing.T) {
 		errOnRead       bool
 		errOnWrite      bool
 		wrongParamCount bool
+		midDeviceError  int
+		midWrongCRC     bool
 		expectErr       error
 	}{
 		{
@@ -982,6 +1044,16 @@ func TestFastSyncRead(t *testing.T) {
 			wrongParamCount: true,
 			expectErr:       protocol.ErrUnexpectedParamCount,
 		},
+		{
+			name:           "Device Error mid-chain",
+			midDeviceError: 0x80,
+			expectErr:      protocol.ErrDeviceError,
+		},
+		{
+			name:        "Invalid Segment CRC mid-chain",
+			midWrongCRC: true,
+			expectErr:   protocol.ErrStatusCRCInvalid,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -993,7 +1065,9 @@ func TestFastSyncRead(t *testing.T) {
 				ErrorOnRead:        tc.errOnRead,
 			}
 			config2 := protocol.MockDeviceConfig{
-				ID: 0x07,
+				ID:                 0x07,
+				ProcessingError:    tc.midDeviceError,
+				SimWrongSegmentCRC: tc.midWrongCRC,
 			}
 			config3 := protocol.MockDeviceConfig{
 				ID: 0x04,
@@ -1041,6 +1115,8 @@ func TestFastBulkRead(t *testing.T) {
 		errOnRead       bool
 		errOnWrite      bool
 		wrongParamCount bool
+		midDeviceError  int
+		midWrongCRC     bool
 		expectErr       error
 	}{
 		{
@@ -1066,6 +1142,16 @@ func TestFastBulkRead(t *testing.T) {
 			wrongParamCount: true,
 			expectErr:       protocol.ErrUnexpectedParamCount,
 		},
+		{
+			name:           "Device Error mid-chain",
+			midDeviceError: 0x80,
+			expectErr:      protocol.ErrDeviceError,
+		},
+		{
+			name:        "Invalid Segment CRC mid-chain",
+			midWrongCRC: true,
+			expectErr:   protocol.ErrStatusCRCInvalid,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -1077,7 +1163,9 @@ func TestFastBulkRead(t *testing.T) {
 				SimWrongParamCount: tc.wrongParamCount,
 			}
 			config2 := protocol.MockDeviceConfig{
-				ID: 0x07,
+				ID:                 0x07,
+				ProcessingError:    tc.midDeviceError,
+				SimWrongSegmentCRC: tc.midWrongCRC,
 			}
 			config3 := protocol.MockDeviceConfig{
 				ID: 0x04,
@@ -1132,3 +1220,457 @@ func TestFastBulkRead(t *testing.T) {
 		})
 	}
 }
+
+func TestBroadcastPing(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		ids             []int
+		processingError int
+		wrongParamCount bool
+		errOnRead       bool
+		errOnWrite      bool
+		expectIDs       []byte
+		expectDupIDs    []byte
+		expectErr       error
+	}{
+		{
+			name:      "No errors",
+			ids:       []int{0x01, 0x02, 0x03},
+			expectIDs: []byte{0x01, 0x02, 0x03},
+		},
+		{
+			name:      "No devices",
+			expectIDs: nil,
+		},
+		{
+			name:            "Device Error",
+			ids:             []int{0x01, 0x02, 0x03},
+			processingError: 0x80,
+			expectIDs:       []byte{0x01, 0x02, 0x03},
+		},
+		{
+			name:         "Duplicate ID",
+			ids:          []int{0x01, 0x02, 0x02, 0x03},
+			expectIDs:    []byte{0x01, 0x02, 0x02, 0x03},
+			expectDupIDs: []byte{0x02},
+		},
+		{
+			name:            "Corrupted Status",
+			ids:             []int{0x01, 0x02, 0x03},
+			wrongParamCount: true,
+			expectIDs:       []byte{0x01, 0x02},
+			expectDupIDs:    []byte{0x03},
+		},
+		{
+			name:      "Read Error",
+			ids:       []int{0x01, 0x02, 0x03},
+			errOnRead: true,
+			expectErr: protocol.ErrMockReadError,
+		},
+		{
+			name:       "Write Error",
+			ids:        []int{0x01, 0x02, 0x03},
+			errOnWrite: true,
+			expectErr:  protocol.ErrMockWriteError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var devices []*protocol.MockDevice
+			for i, id := range tc.ids {
+				config := protocol.MockDeviceConfig{ID: id}
+				if i == len(tc.ids)-1 {
+					config.ProcessingError = tc.processingError
+					config.SimWrongParamCount = tc.wrongParamCount
+					config.ErrorOnRead = tc.errOnRead
+					config.ErrorOnWrite = tc.errOnWrite
+				}
+				devices = append(devices, protocol.NewMockDevice(config))
+			}
+			h := protocol.NewHandler(protocol.NewDeviceChain(devices...), 0)
+
+			got, err := h.BroadcastPing()
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expectErr, err)
+				}
+				return
+			}
+			if tc.expectDupIDs != nil {
+				var dupErr *protocol.DuplicateIDError
+				if !errors.As(err, &dupErr) {
+					t.Fatalf("Expected a duplicate ID error but got %v", err)
+				}
+				if fmt.Sprint(dupErr.IDs) != fmt.Sprint(tc.expectDupIDs) {
+					t.Errorf("Expected duplicate IDs %v, got %v", tc.expectDupIDs, dupErr.IDs)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var ids []byte
+			for _, r := range got {
+				ids = append(ids, r.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tc.expectIDs) {
+				t.Errorf("Expected responses from IDs %v, got %v", tc.expectIDs, ids)
+			}
+		})
+	}
+}
+
+func TestUnexpectedStatusID(t *testing.T) {
+	var operations = []struct {
+		name string
+		call func(h *protocol.Handler) error
+	}{
+		{
+			name: "Ping",
+			call: func(h *protocol.Handler) error { _, err := h.Ping(0x01); return err },
+		},
+		{
+			name: "Read",
+			call: func(h *protocol.Handler) error { _, err := h.Read(0x01, 132, 0); return err },
+		},
+		{
+			name: "Write",
+			call: func(h *protocol.Handler) error { return h.Write(0x01, 65, 1) },
+		},
+		{
+			name: "RegWrite",
+			call: func(h *protocol.Handler) error { return h.RegWrite(0x01, 65, 1) },
+		},
+		{
+			name: "Action",
+			call: func(h *protocol.Handler) error { return h.Action(0x01) },
+		},
+		{
+			name: "Reboot",
+			call: func(h *protocol.Handler) error { return h.Reboot(0x01) },
+		},
+		{
+			name: "FactoryReset",
+			call: func(h *protocol.Handler) error { return h.FactoryReset(0x01, protocol.ResetAllExceptID) },
+		},
+		{
+			name: "Clear",
+			call: func(h *protocol.Handler) error { return h.Clear(0x01, protocol.ClearMultiRotationPos) },
+		},
+		{
+			name: "ControlTableBackup",
+			call: func(h *protocol.Handler) error { return h.ControlTableBackup(0x01, protocol.BackupStore) },
+		},
+	}
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			// Device ID 2 responds to instructions sent to device ID 1.
+			devices := &protocol.CannedDevices{Chunks: [][]byte{protocol.StatusPacket(0x02, 0, 0x26, 0x04, 0x2D)}}
+			if op.name != "Ping" {
+				devices.Chunks = [][]byte{protocol.StatusPacket(0x02, 0)}
+			}
+			h := protocol.NewHandler(devices, 0)
+			if err := op.call(h); !errors.Is(err, protocol.ErrUnexpectedStatusID) {
+				t.Errorf("Expected error of %q but got %q", protocol.ErrUnexpectedStatusID, err)
+			}
+		})
+	}
+}
+
+func TestBroadcastPingCollisions(t *testing.T) {
+	ping := func(id byte) []byte {
+		return protocol.StatusPacket(id, 0, 0x26, 0x04, 0x2D)
+	}
+	var testCases = []struct {
+		name         string
+		chunks       [][]byte
+		expectIDs    []byte
+		expectDupIDs []byte
+	}{
+		{
+			name: "Garbled length",
+			chunks: [][]byte{
+				ping(0x01),
+				{0xFF, 0xFF, 0xFD, 0x00, 0x03, 0xFF, 0xFF, 0x55, 0x00},
+				{},
+				ping(0x05),
+			},
+			expectIDs:    []byte{0x01, 0x05},
+			expectDupIDs: []byte{0x03},
+		},
+		{
+			name: "Invalid length",
+			chunks: [][]byte{
+				{0xFF, 0xFF, 0xFD, 0x00, 0x04, 0x02, 0x00, 0x55, 0x00},
+				ping(0x06),
+			},
+			expectIDs:    []byte{0x06},
+			expectDupIDs: []byte{0x04},
+		},
+		{
+			name: "Header without ID",
+			chunks: [][]byte{
+				{0xFF, 0xFF, 0xFD, 0x00},
+				{},
+				ping(0x02),
+			},
+			expectIDs: []byte{0x02},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices := &protocol.CannedDevices{Chunks: tc.chunks, Pause: 30 * time.Millisecond}
+			h := protocol.NewHandler(devices, 20*time.Millisecond)
+
+			got, err := h.BroadcastPing()
+			if tc.expectDupIDs != nil {
+				var dupErr *protocol.DuplicateIDError
+				if !errors.As(err, &dupErr) {
+					t.Fatalf("Expected a duplicate ID error but got %v", err)
+				}
+				if fmt.Sprint(dupErr.IDs) != fmt.Sprint(tc.expectDupIDs) {
+					t.Errorf("Expected duplicate IDs %v, got %v", tc.expectDupIDs, dupErr.IDs)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var ids []byte
+			for _, r := range got {
+				ids = append(ids, r.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tc.expectIDs) {
+				t.Errorf("Expected responses from IDs %v, got %v", tc.expectIDs, ids)
+			}
+		})
+	}
+}
+
+func TestStatusPacketReading(t *testing.T) {
+	data := func(n int, seed byte) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = seed + byte(i)
+		}
+		return b
+	}
+	status1 := protocol.StatusPacket(0x01, 0, data(4, 0x10)...)
+	status2 := protocol.StatusPacket(0x02, 0, data(4, 0x20)...)
+	long := protocol.StatusPacket(0x01, 0, data(1500, 0)...)
+
+	var testCases = []struct {
+		name       string
+		ids        []byte
+		length     uint16
+		chunks     [][]byte
+		expectData [][]byte
+		expectErrs []error
+	}{
+		{
+			name:       "Header split across reads",
+			ids:        []byte{0x01},
+			length:     4,
+			chunks:     [][]byte{status1[:2], status1[2:6], status1[6:]},
+			expectData: [][]byte{data(4, 0x10)},
+		},
+		{
+			name:       "Two status packets in one read",
+			ids:        []byte{0x01, 0x02},
+			length:     4,
+			chunks:     [][]byte{append(append([]byte{}, status1...), status2...)},
+			expectData: [][]byte{data(4, 0x10), data(4, 0x20)},
+		},
+		{
+			name:       "Garbage before header",
+			ids:        []byte{0x01},
+			length:     4,
+			chunks:     [][]byte{{0x12, 0xFF, 0xFD, 0x00, 0xFF}, status1},
+			expectData: [][]byte{data(4, 0x10)},
+		},
+		{
+			name:       "Packet longer than receive buffer",
+			ids:        []byte{0x01},
+			length:     1500,
+			chunks:     [][]byte{long[:700], long[700:]},
+			expectData: [][]byte{data(1500, 0)},
+		},
+		{
+			name:       "Timeout mid-packet",
+			ids:        []byte{0x01, 0x02},
+			length:     4,
+			chunks:     [][]byte{status1[:9], {}, status2},
+			expectData: [][]byte{nil, data(4, 0x20)},
+			expectErrs: []error{protocol.ErrReadTimeout, nil},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices := &protocol.CannedDevices{Chunks: tc.chunks, Pause: 30 * time.Millisecond}
+			h := protocol.NewHandler(devices, 20*time.Millisecond)
+			results, err := h.SyncRead(tc.ids, 132, tc.length)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for i, r := range results {
+				var expectErr error
+				if tc.expectErrs != nil {
+					expectErr = tc.expectErrs[i]
+				}
+				if expectErr != nil {
+					if !errors.Is(r.Err, expectErr) {
+						t.Errorf("Expected error of %q for ID %d but got %q", expectErr, r.ID, r.Err)
+					}
+					continue
+				}
+				if r.Err != nil {
+					t.Errorf("Unexpected error for ID %d: %v", r.ID, r.Err)
+				}
+				if !bytes.Equal(r.Data, tc.expectData[i]) {
+					t.Errorf("Expected data %v for ID %d, got %v", tc.expectData[i], r.ID, r.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	var operations = []struct {
+		name string
+		call func(ctx context.Context, h *protocol.Handler, id byte) error
+	}{
+		{
+			name: "Ping",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				_, err := h.PingContext(ctx, id)
+				return err
+			},
+		},
+		{
+			name: "BroadcastPing",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				_, err := h.BroadcastPingContext(ctx)
+				return err
+			},
+		},
+		{
+			name: "Read",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				_, err := h.ReadContext(ctx, id, 0x84, 4)
+				return err
+			},
+		},
+		{
+			name: "Write",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				return h.WriteContext(ctx, id, 0x74, 0x00, 0x08, 0x00, 0x00)
+			},
+		},
+		{
+			name: "SyncRead",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				_, err := h.SyncReadContext(ctx, []byte{id}, 0x84, 4)
+				return err
+			},
+		},
+		{
+			name: "BulkRead",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				_, err := h.BulkReadContext(ctx, []protocol.BulkReadDescriptor{{ID: id, Addr: 0x84, Length: 4}})
+				return err
+			},
+		},
+		{
+			name: "FastSyncRead",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				_, err := h.FastSyncReadContext(ctx, []byte{id}, 0x84, 4)
+				return err
+			},
+		},
+		{
+			name: "FastBulkRead",
+			call: func(ctx context.Context, h *protocol.Handler, id byte) error {
+				_, err := h.FastBulkReadContext(ctx, []protocol.BulkReadDescriptor{{ID: id, Addr: 0x84, Length: 4}})
+				return err
+			},
+		},
+	}
+	deviceID := 0x21
+	readTimeout := 500 * time.Millisecond
+	for _, op := range operations {
+		t.Run(op.name+", deadline exceeded while waiting for status", func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+				ID:             deviceID,
+				MidPacketDelay: 2 * readTimeout,
+			})
+			h := protocol.NewHandler(protocol.NewDeviceChain(d), readTimeout)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := op.call(ctx, h, byte(deviceID))
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected error of %q but got %q", context.DeadlineExceeded, err)
+			}
+			if elapsed := time.Since(start); elapsed >= readTimeout {
+				t.Errorf("Expected to return before the read timeout (%v), took %v", readTimeout, elapsed)
+			}
+		})
+		t.Run(op.name+", cancelled before sending instruction", func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{
+				ID: deviceID,
+			})
+			h := protocol.NewHandler(protocol.NewDeviceChain(d), readTimeout)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := op.call(ctx, h, byte(deviceID))
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected error of %q but got %q", context.Canceled, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	ids := []byte{0x11, 0x12, 0x13}
+	var devices []*protocol.MockDevice
+	for _, id := range ids {
+		devices = append(devices, protocol.NewMockDevice(protocol.MockDeviceConfig{ID: int(id)}))
+	}
+	h := protocol.NewHandler(protocol.NewDeviceChain(devices...), 0)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(ids))
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id byte) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				p, err := h.Ping(id)
+				if err != nil {
+					errs <- fmt.Errorf("ping of device ID %d failed: %w", id, err)
+					return
+				}
+				if p.ID != id {
+					errs <- fmt.Errorf("ping of device ID %d returned status of device ID %d", id, p.ID)
+					return
+				}
+				if err := h.Write(id, 0x74, 0x00, 0x08, 0x00, 0x00); err != nil {
+					errs <- fmt.Errorf("write to device ID %d failed: %w", id, err)
+					return
+				}
+				data, err := h.Read(id, 0x84, 4)
+				if err != nil {
+					errs <- fmt.Errorf("read from device ID %d failed: %w", id, err)
+					return
+				}
+				if len(data) != 4 {
+					errs <- fmt.Errorf("read from device ID %d returned %d bytes, expected 4", id, len(data))
+					return
+				}
+			}
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -1,8 +1,14 @@
 package protocol
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haguro/go-dxl/internal/dxl2"
 )
 
 const (
@@ -45,3 +51,241 @@ func (l *PacketLogger) Write(p []byte) (n int, err error) {
 	}
 	return n, err
 }
+
+// Direction is the direction of a packet logged by a DecodingLogger.
+type Direction byte
+
+const (
+	Sent     Direction = iota // An instruction packet written to the bus.
+	Received                  // A status packet read from the bus.
+)
+
+func (d Direction) String() string {
+	if d == Received {
+		return "received"
+	}
+	return "sent"
+}
+
+// PacketRecord is a complete packet written to or read from the bus, decoded by a DecodingLogger.
+type PacketRecord struct {
+	Time        time.Time     //The time the last byte of the packet was written or read.
+	Direction   Direction     //Whether the packet was written (an instruction) or read (a status).
+	ID          byte          //The ID in the packet header.
+	Instruction byte          //The instruction code, 0x55 for status packets.
+	Address     uint16        //The start address of read, write, reg write, sync read and sync write instructions.
+	Length      uint16        //The data length of read, write, reg write, sync read and sync write instructions.
+	Params      []byte        //The parameters, without byte stuffing (for a status packet, after the error byte).
+	Error       byte          //The error byte of a status packet.
+	CRCValid    bool          //Whether the CRC of the packet is valid.
+	Latency     time.Duration //For a status packet, the time since the last instruction packet was written.
+	Raw         []byte        //The packet as it was written or read.
+}
+
+// Err returns the processing error reported by the error byte of a status packet, if any.
+func (r PacketRecord) Err() error {
+	return parseProcessingErr(r.Error)
+}
+
+// InstructionName returns the name of the packet's instruction, e.g. "Sync Read".
+func (r PacketRecord) InstructionName() string {
+	return instructionName(r.Instruction)
+}
+
+// hasAddress reports whether the Address and Length fields apply to the packet's instruction.
+func (r PacketRecord) hasAddress() bool {
+	switch r.Instruction {
+	case read, write, regWrite, syncRead, syncWrite, fastSyncRead:
+		return true
+	}
+	return false
+}
+
+func (r PacketRecord) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s ID %d %s", r.Direction, r.ID, r.InstructionName())
+	if r.hasAddress() {
+		fmt.Fprintf(&b, " addr=%d len=%d", r.Address, r.Length)
+	}
+	fmt.Fprintf(&b, " params=[% X]", r.Params)
+	if r.Direction == Received {
+		fmt.Fprintf(&b, " error=0x%02X", r.Error)
+		if err := r.Err(); err != nil {
+			fmt.Fprintf(&b, " (%v)", err)
+		}
+	}
+	if r.CRCValid {
+		b.WriteString(" crc=ok")
+	} else {
+		b.WriteString(" crc=invalid")
+	}
+	if r.Latency > 0 {
+		fmt.Fprintf(&b, " latency=%v", r.Latency)
+	}
+	return b.String()
+}
+
+// PacketSink receives the packets decoded by a DecodingLogger.
+type PacketSink interface {
+	LogPacket(r PacketRecord)
+}
+
+// PacketSinkFunc is a function that implements PacketSink.
+type PacketSinkFunc func(r PacketRecord)
+
+// LogPacket calls f(r).
+func (f PacketSinkFunc) LogPacket(r PacketRecord) {
+	f(r)
+}
+
+// WriterSink returns a PacketSink that writes each packet to w as a line of text, prefixed with its timestamp.
+func WriterSink(w io.Writer) PacketSink {
+	var mu sync.Mutex
+	return PacketSinkFunc(func(r PacketRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s %s\n", r.Time.Format(time.RFC3339Nano), r)
+	})
+}
+
+// DecodingLogger wraps an io.ReadWriter (e.g. the one passed to a Handler) and reassembles the bytes written to and
+// read from it into complete packets, which it decodes and passes to a PacketSink. Unlike PacketLogger, it logs whole
+// packets no matter how many bytes are read or written at a time.
+// A DecodingLogger is safe for concurrent use by multiple goroutines.
+type DecodingLogger struct {
+	rw    io.ReadWriter
+	sink  PacketSink
+	level byte
+	mu    sync.Mutex
+	rx    []byte    // Bytes read that don't form a complete packet yet.
+	tx    []byte    // Bytes written that don't form a complete packet yet.
+	sent  time.Time // The time the last instruction packet was written.
+}
+
+// NewDecodingLogger creates a DecodingLogger that logs the packets written to and/or read from readWriter, according
+// to level, to sink.
+func NewDecodingLogger(readWriter io.ReadWriter, level byte, sink PacketSink) *DecodingLogger {
+	return &DecodingLogger{
+		rw:    readWriter,
+		sink:  sink,
+		level: level,
+	}
+}
+
+func (l *DecodingLogger) Read(p []byte) (n int, err error) {
+	n, err = l.rw.Read(p)
+	if n > 0 && n <= len(p) {
+		l.log(Received, p[:n])
+	}
+	return n, err
+}
+
+func (l *DecodingLogger) Write(p []byte) (n int, err error) {
+	n, err = l.rw.Write(p)
+	if n > 0 && n <= len(p) {
+		l.log(Sent, p[:n])
+	}
+	return n, err
+}
+
+// log adds the given bytes to those written or read so far, and passes any complete packets on to the sink.
+func (l *DecodingLogger) log(d Direction, b []byte) {
+	l.mu.Lock()
+	now := time.Now()
+	buf := &l.tx
+	if d == Received {
+		buf = &l.rx
+	}
+	*buf = append(*buf, b...)
+	var records []PacketRecord
+	for {
+		packet, rest := nextPacket(*buf)
+		*buf = rest
+		if packet == nil {
+			break
+		}
+		r := decodePacket(packet, d)
+		r.Time = now
+		if d == Sent {
+			l.sent = now
+		} else if !l.sent.IsZero() {
+			r.Latency = now.Sub(l.sent)
+		}
+		records = append(records, r)
+	}
+	l.mu.Unlock()
+
+	if (d == Sent && l.level&LogWrite == 0) || (d == Received && l.level&LogRead == 0) {
+		return
+	}
+	for _, r := range records {
+		l.sink.LogPacket(r)
+	}
+}
+
+// nextPacket returns the first complete packet in b and the bytes that follow it, discarding anything before the
+// packet's header. If there is no complete packet in b, it returns nil and the bytes that may still become one.
+func nextPacket(b []byte) (packet, rest []byte) {
+	for {
+		i := bytes.Index(b, statusHeader)
+		if i < 0 {
+			// Keep what could be the start of a header.
+			if len(b) > 3 {
+				b = b[len(b)-3:]
+			}
+			return nil, b
+		}
+		b = b[i:]
+		if len(b) < 7 {
+			return nil, b
+		}
+		length := int(b[5]) + int(b[6])<<8
+		if length < 3 {
+			// Not a valid packet, look for the next header.
+			b = b[1:]
+			continue
+		}
+		end := length + 7
+		if end > len(b) {
+			end = len(b)
+		}
+		// Byte stuffing keeps a header out of any packet, so a header within the packet means its length is corrupt
+		// (otherwise a length of up to 64KiB could hold back the packets that follow for good).
+		if j := bytes.Index(b[len(statusHeader):end], statusHeader); j >= 0 {
+			b = b[len(statusHeader)+j:]
+			continue
+		}
+		if len(b) < length+7 {
+			return nil, b
+		}
+		return b[:length+7], b[length+7:]
+	}
+}
+
+// decodePacket decodes a complete packet, as returned by nextPacket.
+func decodePacket(packet []byte, d Direction) PacketRecord {
+	l := len(packet)
+	crc := dxl2.CRC(packet[:l-2])
+	r := PacketRecord{
+		Direction: d,
+		ID:        packet[4],
+		CRCValid:  packet[l-2] == byte(crc) && packet[l-1] == byte(crc>>8),
+		Raw:       append([]byte(nil), packet...),
+	}
+	body := dxl2.Destuff(packet[7 : l-2])
+	r.Instruction, r.Params = body[0], body[1:]
+	if r.Instruction == statusCmd && len(r.Params) > 0 {
+		r.Error, r.Params = r.Params[0], r.Params[1:]
+	}
+	switch p := r.Params; r.Instruction {
+	case write, regWrite:
+		if len(p) >= 2 {
+			r.Address, r.Length = uint16(p[0])+uint16(p[1])<<8, uint16(len(p)-2)
+		}
+	case read, syncRead, syncWrite, fastSyncRead:
+		if len(p) >= 4 {
+			r.Address, r.Length = uint16(p[0])+uint16(p[1])<<8, uint16(p[2])+uint16(p[3])<<8
+		}
+	}
+	return r
+}
@@ -0,0 +1,149 @@
+package protocol_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/haguro/go-dxl/protocol/v2"
+)
+
+// recordSink collects the packets passed to it.
+type recordSink struct {
+	records []protocol.PacketRecord
+}
+
+func (s *recordSink) LogPacket(r protocol.PacketRecord) {
+	s.records = append(s.records, r)
+}
+
+func TestDecodingLogger(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		level           byte
+		processingError int
+		expectErr       error
+		expectRecords   int
+	}{
+		{
+			name:          "Read and write",
+			level:         protocol.LogReadWrite,
+			expectRecords: 2,
+		},
+		{
+			name:          "Write only",
+			level:         protocol.LogWrite,
+			expectRecords: 1,
+		},
+		{
+			name:          "Read only",
+			level:         protocol.LogRead,
+			expectRecords: 1,
+		},
+		{
+			name:            "Processing error",
+			level:           protocol.LogReadWrite,
+			processingError: 7,
+			expectErr:       protocol.ErrAccessError,
+			expectRecords:   2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x5A, ProcessingError: tc.processingError})
+			sink := &recordSink{}
+			h := protocol.NewHandler(protocol.NewDecodingLogger(d, tc.level, sink), 0)
+			_, err := h.Read(0x5A, 132, 4)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error of %q but got %q", tc.expectErr, err)
+			}
+
+			if len(sink.records) != tc.expectRecords {
+				t.Fatalf("Expected %d packets to be logged but got %d", tc.expectRecords, len(sink.records))
+			}
+			for _, r := range sink.records {
+				if r.ID != 0x5A || !r.CRCValid || r.Time.IsZero() {
+					t.Errorf("Unexpected packet: %v", r)
+				}
+				switch r.Direction {
+				case protocol.Sent:
+					if r.InstructionName() != "Read" || r.Address != 132 || r.Length != 4 {
+						t.Errorf("Expected a read of 4 bytes from address 132 but got %v", r)
+					}
+				case protocol.Received:
+					if r.InstructionName() != "Status" || !errors.Is(r.Err(), tc.expectErr) {
+						t.Errorf("Expected a status with error %v but got %v", tc.expectErr, r)
+					}
+					if tc.expectErr == nil && len(r.Params) != 4 {
+						t.Errorf("Expected 4 params but got %d", len(r.Params))
+					}
+					if tc.level&protocol.LogWrite != 0 && r.Latency <= 0 {
+						t.Errorf("Expected a positive latency but got %v", r.Latency)
+					}
+				}
+			}
+		})
+	}
+}
+
+// discardReadWriter discards writes and has nothing to read.
+type discardReadWriter struct{}
+
+func (discardReadWriter) Read(p []byte) (int, error)  { return 0, nil }
+func (discardReadWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestDecodingLoggerReassembly(t *testing.T) {
+	// A sync write of 0xFF 0xFF 0xFD, which is byte stuffed, preceded by noise.
+	packet := []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0C, 0x00, 0x83, 0x74, 0x00, 0x03, 0x00, 0x01, 0xFF, 0xFF, 0xFD,
+		0xFD}
+	var buf bytes.Buffer
+	sink := &recordSink{}
+	writer := protocol.WriterSink(&buf)
+	l := protocol.NewDecodingLogger(discardReadWriter{}, protocol.LogWrite,
+		protocol.PacketSinkFunc(func(r protocol.PacketRecord) {
+			sink.LogPacket(r)
+			writer.LogPacket(r)
+		}))
+	stream := append([]byte{0xFF, 0x00, 0xFF, 0xFF}, packet...)
+	stream = append(stream, 0x00, 0x00) // An invalid CRC.
+	stream = append(stream, 0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x03, 0x00, 0x01, 0x19, 0x4E)
+	for _, b := range stream {
+		l.Write([]byte{b})
+	}
+
+	if len(sink.records) != 2 {
+		t.Fatalf("Expected 2 packets to be logged but got %d", len(sink.records))
+	}
+	r := sink.records[0]
+	expectParams := []byte{0x74, 0x00, 0x03, 0x00, 0x01, 0xFF, 0xFF, 0xFD}
+	if r.InstructionName() != "Sync Write" || r.ID != protocol.BroadcastID || r.Address != 116 || r.Length != 3 ||
+		!bytes.Equal(r.Params, expectParams) || r.CRCValid {
+		t.Errorf("Unexpected sync write packet: %v", r)
+	}
+	if r := sink.records[1]; r.InstructionName() != "Ping" || r.ID != 1 || !r.CRCValid {
+		t.Errorf("Unexpected ping packet: %v", r)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], "sent ID 1 Ping params=[] crc=ok") {
+		t.Errorf("Unexpected log output: %q", buf.String())
+	}
+}
+
+func TestDecodingLoggerCorruptLength(t *testing.T) {
+	sink := &recordSink{}
+	l := protocol.NewDecodingLogger(discardReadWriter{}, protocol.LogWrite, sink)
+	// A packet with a corrupt length of 0xFFFF, followed by a ping.
+	stream := []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0xFF, 0xFF, 0x02, 0x84, 0x00}
+	stream = append(stream, 0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x03, 0x00, 0x01, 0x19, 0x4E)
+	for _, b := range stream {
+		l.Write([]byte{b})
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected 1 packet to be logged but got %d", len(sink.records))
+	}
+	if r := sink.records[0]; r.InstructionName() != "Ping" || r.ID != 1 || !r.CRCValid {
+		t.Errorf("Unexpected ping packet: %v", r)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"math/rand"
 	"sync"
 	"time"
+
+	"github.com/haguro/go-dxl/internal/dxl2"
 )
 
 var ErrMockWriteError = errors.New("mock write error")
@@ -31,6 +33,56 @@ func (b *Buffer) Write(p []byte) (n int, err error) {
 	return b.b.Write(p)
 }
 
+// CannedDevices respond to every instruction with the same bytes, split into the chunks returned by successive calls
+// to Read. An empty chunk makes Read return nothing for the Pause duration, e.g. to let a read time out. Once all chunks
+// are read, Read returns nothing until the next instruction is written. Reading doesn't allocate.
+type CannedDevices struct {
+	Chunks   [][]byte
+	Pause    time.Duration
+	next     int
+	chunk    []byte
+	pausedAt time.Time
+}
+
+func (d *CannedDevices) Read(p []byte) (int, error) {
+	for len(d.chunk) == 0 {
+		if d.next == len(d.Chunks) {
+			return 0, io.EOF
+		}
+		if len(d.Chunks[d.next]) == 0 {
+			if d.pausedAt.IsZero() {
+				d.pausedAt = time.Now()
+			}
+			if time.Since(d.pausedAt) < d.Pause {
+				return 0, io.EOF
+			}
+			d.pausedAt = time.Time{}
+		}
+		d.chunk = d.Chunks[d.next]
+		d.next++
+	}
+	n := copy(p, d.chunk)
+	d.chunk = d.chunk[n:]
+	return n, nil
+}
+
+func (d *CannedDevices) Write(p []byte) (int, error) {
+	d.next, d.chunk, d.pausedAt = 0, nil, time.Time{}
+	return len(p), nil
+}
+
+// StatusPacket returns the status packet a device with the given ID would send with the given error byte and
+// parameters.
+func StatusPacket(id, errByte byte, params ...byte) []byte {
+	body := dxl2.Stuff(append([]byte{statusCmd, errByte}, params...))
+	length := len(body) + 2
+	packet := []byte{header1, header2, header3, headerR, id, byte(length), byte(length >> 8)}
+	packet = append(packet, body...)
+	packet = append(packet, 0, 0)
+	updatePacketCRCBytes(packet)
+	return packet
+}
+
 type DeviceChain struct {
 	devices []*MockDevice
 	buf     *Buffer
@@ -63,9 +115,91 @@ func (c *DeviceChain) Write(p []byte) (int, error) {
 			return 0, fmt.Errorf("failed to write to device chain: %w", err)
 		}
 	}
+	if p[4] == BroadcastID && (p[7] == fastSyncRead || p[7] == fastBulkRead) {
+		c.writeFastStatus(p)
+	}
 	return len(p), nil
 }
 
+// writeFastStatus assembles the merged status packet of a fast sync read or fast bulk read instruction from the
+// segments of the requested devices in the chain, in the requested order.
+func (c *DeviceChain) writeFastStatus(p []byte) {
+	instLength := uint16(p[5]) + uint16(p[6])<<8
+	instParams := dxl2.Destuff(p[8 : 8+instLength-3])
+
+	var ids []byte
+	var lengths []int
+	if p[7] == fastSyncRead {
+		l := int(instParams[2]) + int(instParams[3])<<8
+		for _, id := range instParams[4:] {
+			ids = append(ids, id)
+			lengths = append(lengths, l)
+		}
+	} else {
+		for i := 0; i < len(instParams); i += 5 {
+			ids = append(ids, instParams[i])
+			lengths = append(lengths, int(instParams[i+3])+int(instParams[i+4])<<8)
+		}
+	}
+
+	var segments []*MockDevice
+	var data [][]byte
+	for i, id := range ids {
+		for _, d := range c.devices {
+			if d.id == id {
+				segments = append(segments, d)
+				data = append(data, d.segmentData(lengths[i]))
+				break
+			}
+		}
+	}
+	if len(segments) == 0 {
+		return
+	}
+
+	length := 3 // Instruction and CRC
+	for i := range segments {
+		length += 2 + len(data[i])
+		if i < len(segments)-1 {
+			length += 2
+		}
+	}
+
+	// Random data never contains 0xFF (see randBytes) so none of the segments need byte stuffing.
+	packet := []byte{header1, header2, header3, headerR, BroadcastID, byte(length), byte(length >> 8), statusCmd}
+	for i, d := range segments {
+		packet = append(packet, d.errorByte, d.id)
+		packet = append(packet, data[i]...)
+		if i < len(segments)-1 {
+			crc := dxl2.CRC(packet)
+			if d.wrongSegmentCRC {
+				crc = ^crc
+			}
+			packet = append(packet, byte(crc), byte(crc>>8))
+		}
+	}
+	packet = append(packet, 0, 0)
+	updatePacketCRCBytes(packet)
+
+	packet = append(randBytes(rand.Intn(6)), packet...)
+	packet = append(packet, randBytes(rand.Intn(6))...)
+	// The first device in the requested order starts responding and so determines when the packet is written.
+	writeDelayed(c.buf, packet, segments[0].writeDelay, segments[0].delayPos)
+}
+
+// writeDelayed writes the packet to buf, with the bytes from delayPos onwards written after the given delay, if any.
+func writeDelayed(buf *Buffer, packet []byte, delay time.Duration, delayPos int) {
+	if delay > 0 {
+		buf.Write(packet[:delayPos])
+		go func() {
+			time.Sleep(delay)
+			buf.Write(packet[delayPos:])
+		}()
+		return
+	}
+	buf.Write(packet)
+}
+
 type MockDeviceConfig struct {
 	ID                 int
 	MidPacketDelay     time.Duration //Simulate delay occuring while writing status packet
@@ -74,6 +208,7 @@ type MockDeviceConfig struct {
 	ErrorOnRead        bool
 	ErrorOnWrite       bool
 	SimWrongParamCount bool
+	SimWrongSegmentCRC bool //Simulate a corrupted CRC in the device's segment of a fast read status
 }
 
 type MockDevice struct {
@@ -85,6 +220,7 @@ type MockDevice struct {
 	writeErr        error
 	readErr         error
 	wrongParamCount bool
+	wrongSegmentCRC bool
 	padWithGarbage  bool
 }
 
@@ -97,6 +233,7 @@ func NewMockDevice(config MockDeviceConfig) *MockDevice {
 		delayPos:        config.DelayPosition,
 		errorByte:       byte(config.ProcessingError),
 		wrongParamCount: config.SimWrongParamCount,
+		wrongSegmentCRC: config.SimWrongSegmentCRC,
 		padWithGarbage:  true, //Always pad status with garbage to simulate potential leftover bytes or noise in channel
 	}
 	if config.ErrorOnRead {
@@ -128,12 +265,11 @@ func (d *MockDevice) Write(p []byte) (int, error) {
 
 	instLength := uint16(p[5]) + uint16(p[6])<<8
 	instruction := p[7]
-	instParams := p[8 : 8+instLength-3]
+	instParams := dxl2.Destuff(p[8 : 8+instLength-3])
 
 	errByte := d.errorByte
 	statusParams := []byte{}
 	statusPacket := []byte{}
-	length := 4
 
 	switch instruction {
 	case ping:
@@ -186,42 +322,9 @@ func (d *MockDevice) Write(p []byte) (int, error) {
 		}
 	case bulkWrite:
 		//No behaivour to mock.
-	case fastSyncRead:
-		l := int(instParams[2]) + int(instParams[3])<<8
-		ids := instParams[4:]
-		if d.id != ids[0] {
-			return pLen, nil
-		}
-		statusParams = append([]byte{d.id}, randBytes(l)...)
-		if len(ids) > 1 {
-			statusParams = append(statusParams, randBytes(2)...) // TODO: ideally we need to be able to verify the CRC of the EACH of the status packets. For now, we just append two random bytes.
-			for k, id := range ids[1:] {
-				statusParams = append(statusParams, 0, id)
-				statusParams = append(statusParams, randBytes(l)...)
-				if k < len(ids)-2 {
-					statusParams = append(statusParams, randBytes(2)...) //TODO: Random CRC bytes. See above TODO.
-				}
-			}
-		}
-
-	case fastBulkRead:
-		if d.id != instParams[0] {
-			return pLen, nil
-		}
-		l := int(instParams[3]) + int(instParams[4])<<8
-		statusParams = append([]byte{d.id}, randBytes(l)...)
-		if len(instParams) > 5 {
-			statusParams = append(statusParams, randBytes(2)...) // TODO: ideally we need to be able to verify the CRC of the EACH of the status packets. For now, we just append two random bytes.
-			for i := 5; i < len(instParams); i += 5 {
-				statusParams = append(statusParams, 0, instParams[i])
-				l = int(instParams[i+3]) + int(instParams[i+4])<<8
-				statusParams = append(statusParams, randBytes(l)...)
-				if i < len(instParams)-7 {
-					statusParams = append(statusParams, randBytes(2)...) //TODO: Random CRC bytes. See above TODO.
-				}
-			}
-		}
-
+	case fastSyncRead, fastBulkRead:
+		// The merged status packet is assembled by the device chain from each device's segment.
+		return pLen, nil
 	default:
 		errByte = 0x02
 	}
@@ -240,14 +343,15 @@ func (d *MockDevice) Write(p []byte) (int, error) {
 			}
 		}
 
+		body := []byte{statusCmd, errByte}
 		if errByte == 0 {
-			length += len(statusParams)
+			body = append(body, statusParams...)
 		}
+		body = dxl2.Stuff(body)
+		length := len(body) + 2
 		statusPacket = append(statusPacket, header1, header2, header3, headerR)
-		statusPacket = append(statusPacket, instID, byte(length), byte(length>>8), statusCmd, byte(errByte))
-		if errByte == 0 {
-			statusPacket = append(statusPacket, statusParams...)
-		}
+		statusPacket = append(statusPacket, d.id, byte(length), byte(length>>8))
+		statusPacket = append(statusPacket, body...)
 		statusPacket = append(statusPacket, 0, 0)
 		updatePacketCRCBytes(statusPacket)
 
@@ -256,21 +360,21 @@ func (d *MockDevice) Write(p []byte) (int, error) {
 			statusPacket = append(statusPacket, randBytes(rand.Intn(6))...)
 		}
 
-		if d.writeDelay > 0 {
-			d.buf.Write(statusPacket[:d.delayPos])
-			go func() {
-				time.Sleep(d.writeDelay)
-				d.buf.Write(statusPacket[d.delayPos:])
-			}()
-			return pLen, nil
-		}
-
-		d.buf.Write(statusPacket)
+		writeDelayed(d.buf, statusPacket, d.writeDelay, d.delayPos)
 	}
 
 	return pLen, nil
 }
 
+// segmentData returns the data the device includes in its segment of a fast read status packet.
+func (d *MockDevice) segmentData(length int) []byte {
+	data := randBytes(length)
+	if d.wrongParamCount && length > 0 {
+		data = data[:length-1]
+	}
+	return data
+}
+
 func randBytes(n int) []byte {
 	b := make([]byte, n)
 	for i := range b {
@@ -56,6 +56,16 @@ func TestInstructionPacketBytes(t *testing.T) {
 			expBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x12, 0x00, 0x9A, 0x03, 0x84, 0x00, 0x04, 0x00, 0x07,
 				0x7C, 0x00, 0x02, 0x00, 0x04, 0x92, 0x00, 0x01, 0x00, 0xDA, 0x2D},
 		},
+		{
+			name: "Valid instruction with header pattern in params",
+			inst: &instruction{
+				id:      0x01,
+				command: write,
+				params:  []byte{0x74, 0x00, 0xFF, 0xFF, 0xFD, 0x00},
+			},
+			expBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x0A, 0x00, 0x03, 0x74, 0x00, 0xFF, 0xFF, 0xFD, 0xFD,
+				0x00, 0x21, 0xE7},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -137,6 +147,12 @@ func TestParseStatusPacket(t *testing.T) {
 			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x04, 0x00, 0x55, 0x07, 0xB0, 0x8C},
 			expStatus:   status{id: 0x01, err: ErrAccessError, params: []byte{}},
 		},
+		{
+			name: "Valid Response with Stuffed Params",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0x02, 0x09, 0x00, 0x55, 0x00, 0xFF, 0xFF, 0xFD, 0xFD, 0x01,
+				0xE1, 0xBC},
+			expStatus: status{id: 0x02, err: nil, params: []byte{0xFF, 0xFF, 0xFD, 0x01}},
+		},
 		{
 			name:        "Packet Too Short",
 			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFF, 0x01, 0x00, 0x55, 0x00},
@@ -187,6 +203,115 @@ func TestParseStatusPacket(t *testing.T) {
 	}
 }
 
+func TestParseFastStatusPacket(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packetBytes []byte
+		ids         []byte
+		lengths     []uint16
+		expErr      error
+		expStatuses []status
+	}{
+		{
+			name: "Valid Response",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0D, 0x00, 0x55, 0x00, 0x01, 0xAA, 0xBB, 0xCE, 0xC2,
+				0x00, 0x02, 0xCC, 0xDD, 0xC5, 0x08},
+			ids:     []byte{0x01, 0x02},
+			lengths: []uint16{2, 2},
+			expStatuses: []status{
+				{id: 0x01, params: []byte{0xAA, 0xBB}},
+				{id: 0x02, params: []byte{0xCC, 0xDD}},
+			},
+		},
+		{
+			name: "Valid Response with Stuffed Segment",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0F, 0x00, 0x55, 0x00, 0x01, 0xFF, 0xFF, 0xFD, 0xFD,
+				0x22, 0x8F, 0x00, 0x02, 0xCC, 0xDD, 0x17, 0x43},
+			ids:     []byte{0x01, 0x02},
+			lengths: []uint16{3, 2},
+			expStatuses: []status{
+				{id: 0x01, params: []byte{0xFF, 0xFF, 0xFD}},
+				{id: 0x02, params: []byte{0xCC, 0xDD}},
+			},
+		},
+		{
+			name: "Valid Response with Segment Error",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0D, 0x00, 0x55, 0x00, 0x01, 0xAA, 0xBB, 0xCE, 0xC2,
+				0x06, 0x02, 0xCC, 0xDD, 0xC5, 0x70},
+			ids:     []byte{0x01, 0x02},
+			lengths: []uint16{2, 2},
+			expStatuses: []status{
+				{id: 0x01, params: []byte{0xAA, 0xBB}},
+				{id: 0x02, err: ErrDataLimitError, params: []byte{0xCC, 0xDD}},
+			},
+		},
+		{
+			name: "Unexpected Segment ID",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0D, 0x00, 0x55, 0x00, 0x01, 0xAA, 0xBB, 0xCE, 0xC2,
+				0x00, 0x03, 0xCC, 0xDD, 0xD2, 0x88},
+			ids:     []byte{0x01, 0x02},
+			lengths: []uint16{2, 2},
+			expErr:  ErrUnexpectedStatusID,
+		},
+		{
+			name: "Invalid Segment CRC",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0D, 0x00, 0x55, 0x00, 0x01, 0xAA, 0xBB, 0x34, 0x12,
+				0x00, 0x02, 0xCC, 0xDD, 0xB3, 0x9D},
+			ids:     []byte{0x01, 0x02},
+			lengths: []uint16{2, 2},
+			expErr:  ErrStatusCRCInvalid,
+		},
+		{
+			name: "Unexpected Segment Length",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0D, 0x00, 0x55, 0x00, 0x01, 0xAA, 0xBB, 0xCE, 0xC2,
+				0x00, 0x02, 0xCC, 0xDD, 0xC5, 0x08},
+			ids:     []byte{0x01, 0x02},
+			lengths: []uint16{2, 3},
+			expErr:  ErrUnexpectedParamCount,
+		},
+		{
+			name: "Invalid Packet CRC",
+			packetBytes: []byte{0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x0D, 0x00, 0x55, 0x00, 0x01, 0xAA, 0xBB, 0xCE, 0xC2,
+				0x00, 0x02, 0xCC, 0xDD, 0xC5, 0x09},
+			ids:     []byte{0x01, 0x02},
+			lengths: []uint16{2, 2},
+			expErr:  ErrStatusCRCInvalid,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFastStatusPacket(tc.packetBytes, tc.ids, tc.lengths)
+			if tc.expErr != nil {
+				if err == nil {
+					t.Error("Expected error, got nil")
+					return
+				}
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no errors, got %q", err)
+			}
+			if len(got) != len(tc.expStatuses) {
+				t.Fatalf("Expected %d statuses, got %d", len(tc.expStatuses), len(got))
+			}
+			for i, exp := range tc.expStatuses {
+				if got[i].id != exp.id {
+					t.Errorf("Expected id of status %d to be %d, got %d", i, exp.id, got[i].id)
+				}
+				if !errsEqual(got[i].err, exp.err) {
+					t.Errorf("Expected err of status %d to be %q, got %q", i, exp.err, got[i].err)
+				}
+				if !reflect.DeepEqual(got[i].params, exp.params) {
+					t.Errorf("Expected params of status %d to be %+v, got %+v", i, exp.params, got[i].params)
+				}
+			}
+		})
+	}
+}
+
 func errsEqual(err1, err2 error) bool {
 	if err1 == nil && err2 == nil {
 		return true
@@ -0,0 +1,121 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetryPolicy configures the automatic retries of a Handler's transactions that fail because of a status packet that
+// didn't arrive in time or was corrupted, e.g. by noise on the bus.
+// Only instructions that are safe to repeat are retried: ping (but not broadcast ping, which already waits for every
+// status it can get), read, sync read, bulk read, fast sync read and fast bulk read. A sync read or bulk read is only
+// repeated for the devices whose status failed. Write and reg write instructions are only retried if RetryWrites is
+// set, and other instructions (e.g. action, reboot or factory reset) are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a transaction, including the first one. Transactions are not
+	// retried if it is less than 2.
+	MaxAttempts int
+	// Backoff is the time to wait before the first retry. It doubles with each further retry, up to MaxBackoff if
+	// that is not zero.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable reports whether a failed transaction should be retried. Defaults to IsRetryable.
+	Retryable func(err error) bool
+	// RetryWrites enables the retries of write and reg write instructions. A write whose status was lost may have
+	// been applied, so this should only be set if writing the same data twice is harmless.
+	RetryWrites bool
+}
+
+// IsRetryable reports whether err was caused by a status packet that didn't arrive within the read timeout, failed
+// the CRC check, was truncated or malformed, or came from another device, any of which a retry may fix.
+func IsRetryable(err error) bool {
+	return isStatusErr(err)
+}
+
+// SetRetryPolicy sets the retry policy of the handler. The zero RetryPolicy (the default) disables retries.
+func (h *Handler) SetRetryPolicy(p RetryPolicy) {
+	h.lock(context.Background())
+	defer h.unlock()
+	h.retryPolicy = p
+}
+
+// retryable reports whether a transaction that failed with err should be retried.
+func (h *Handler) retryable(err error) bool {
+	if h.retryPolicy.Retryable != nil {
+		return h.retryPolicy.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff waits before the given retry (1 for the first one), or returns the context's error if the context is done
+// first.
+func (h *Handler) backoff(ctx context.Context, retry int) error {
+	d := h.retryPolicy.Backoff
+	for i := 1; i < retry && d > 0; i++ {
+		d *= 2
+		if h.retryPolicy.MaxBackoff > 0 && d >= h.retryPolicy.MaxBackoff {
+			d = h.retryPolicy.MaxBackoff
+			break
+		}
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retry calls f, the attempt of a transaction, and calls it again according to the retry policy while it fails with
+// a retryable error. Write-type transactions (isWrite) are only retried if the policy allows it. It returns the error
+// of the last attempt, wrapped in a *RetryCancelledError if the context is done while waiting to retry. The lock must
+// be held.
+func (h *Handler) retry(ctx context.Context, isWrite bool, f func() error) error {
+	err := f()
+	if isWrite && !h.retryPolicy.RetryWrites {
+		return err
+	}
+	for attempt := 2; err != nil && attempt <= h.retryPolicy.MaxAttempts && h.retryable(err); attempt++ {
+		if ctxErr := h.backoff(ctx, attempt-1); ctxErr != nil {
+			return &RetryCancelledError{Err: err, CtxErr: ctxErr}
+		}
+		err = f()
+	}
+	return err
+}
+
+// retryReads repeats the reads of a sync read or bulk read whose results failed with a retryable error, according to
+// the retry policy, updating their results. readAgain reads again from the devices of the results with the given
+// indexes, returning one result for each index. It returns the first error of readAgain, which is only returned if
+// the communication interface failed. The lock must be held.
+func (h *Handler) retryReads(ctx context.Context, results []ReadResult,
+	readAgain func(indexes []int) ([]ReadResult, error)) error {
+	for attempt := 2; attempt <= h.retryPolicy.MaxAttempts; attempt++ {
+		var failed []int
+		for i, r := range results {
+			if r.Err != nil && h.retryable(r.Err) {
+				failed = append(failed, i)
+			}
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+		if err := h.backoff(ctx, attempt-1); err != nil {
+			return fmt.Errorf("retry cancelled: %w", err)
+		}
+		rs, err := readAgain(failed)
+		if err != nil {
+			return err
+		}
+		for i, r := range rs {
+			results[failed[i]] = r
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package protocol_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/internal/simtest"
+	"github.com/haguro/go-dxl/protocol/v2"
+	"github.com/haguro/go-dxl/sim"
+)
+
+// newSimBus returns a handler with a short read timeout talking to a simulated bus of servos with IDs 1 and 2.
+func newSimBus(t *testing.T) (*protocol.Handler, *sim.Bus) {
+	t.Helper()
+	b := simtest.NewBus(t, 1020, 1020)
+	return protocol.NewHandler(b, 2*time.Millisecond), b
+}
+
+func TestRetry(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		policy       protocol.RetryPolicy
+		faults       []sim.Fault
+		do           func(h *protocol.Handler) error
+		instruction  string
+		expectErr    error
+		expectTxs    uint64
+		expectErrors uint64
+	}{
+		{
+			name:        "Read retried",
+			policy:      protocol.RetryPolicy{MaxAttempts: 3},
+			faults:      []sim.Fault{{ID: 1, Instruction: sim.ReadInstruction, Times: 2, Drop: true}},
+			do:          func(h *protocol.Handler) error { _, err := h.Read(1, 132, 4); return err },
+			instruction: "Read",
+			expectTxs:   3, expectErrors: 2,
+		},
+		{
+			name:        "Read attempts exhausted",
+			policy:      protocol.RetryPolicy{MaxAttempts: 2},
+			faults:      []sim.Fault{{ID: 1, Instruction: sim.ReadInstruction, Times: 2, Drop: true}},
+			do:          func(h *protocol.Handler) error { _, err := h.Read(1, 132, 4); return err },
+			instruction: "Read",
+			expectErr:   protocol.ErrReadTimeout,
+			expectTxs:   2, expectErrors: 2,
+		},
+		{
+			name:        "No retries by default",
+			faults:      []sim.Fault{{ID: 1, Instruction: sim.ReadInstruction, Times: 1, Drop: true}},
+			do:          func(h *protocol.Handler) error { _, err := h.Read(1, 132, 4); return err },
+			instruction: "Read",
+			expectErr:   protocol.ErrReadTimeout,
+			expectTxs:   1, expectErrors: 1,
+		},
+		{
+			name:        "Ping retried after CRC failure",
+			policy:      protocol.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+			faults:      []sim.Fault{{ID: 1, Instruction: sim.PingInstruction, Times: 1, FlipBits: []int{70}}},
+			do:          func(h *protocol.Handler) error { _, err := h.Ping(1); return err },
+			instruction: "Ping",
+			expectTxs:   2, expectErrors: 1,
+		},
+		{
+			name:        "Processing error not retried",
+			policy:      protocol.RetryPolicy{MaxAttempts: 3},
+			do:          func(h *protocol.Handler) error { _, err := h.Read(1, 1000, 4); return err },
+			instruction: "Read",
+			expectErr:   protocol.ErrDataRangeError,
+			expectTxs:   1, expectErrors: 1,
+		},
+		{
+			name: "Custom retryable errors",
+			policy: protocol.RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool {
+				return errors.Is(err, protocol.ErrDataRangeError)
+			}},
+			do:          func(h *protocol.Handler) error { _, err := h.Read(1, 1000, 4); return err },
+			instruction: "Read",
+			expectErr:   protocol.ErrDataRangeError,
+			expectTxs:   3, expectErrors: 3,
+		},
+		{
+			name:        "Write not retried",
+			policy:      protocol.RetryPolicy{MaxAttempts: 3},
+			faults:      []sim.Fault{{ID: 1, Instruction: sim.WriteInstruction, Times: 1, Drop: true}},
+			do:          func(h *protocol.Handler) error { return h.Write(1, 65, 1) },
+			instruction: "Write",
+			expectErr:   protocol.ErrReadTimeout,
+			expectTxs:   1, expectErrors: 1,
+		},
+		{
+			name:        "Write retried",
+			policy:      protocol.RetryPolicy{MaxAttempts: 3, RetryWrites: true},
+			faults:      []sim.Fault{{ID: 1, Instruction: sim.WriteInstruction, Times: 1, Drop: true}},
+			do:          func(h *protocol.Handler) error { return h.Write(1, 65, 1) },
+			instruction: "Write",
+			expectTxs:   2, expectErrors: 1,
+		},
+		{
+			name:   "Fast sync read retried",
+			policy: protocol.RetryPolicy{MaxAttempts: 2},
+			faults: []sim.Fault{
+				{ID: sim.BroadcastID, Instruction: sim.FastSyncReadInstruction, Times: 1, Truncate: 3},
+			},
+			do:          func(h *protocol.Handler) error { _, err := h.FastSyncRead([]byte{1, 2}, 132, 4); return err },
+			instruction: "Fast Sync Read",
+			expectTxs:   2, expectErrors: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, b := newSimBus(t)
+			h.SetRetryPolicy(tc.policy)
+			for _, f := range tc.faults {
+				b.AddFault(f)
+			}
+
+			err := tc.do(h)
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected error of %q but got %q", tc.expectErr, err)
+			}
+
+			id := byte(1)
+			if tc.instruction == "Fast Sync Read" {
+				id = protocol.BroadcastID
+			}
+			s := findStats(t, h.Stats(), id, tc.instruction)
+			failed := s.Timeouts + s.CRCErrors + s.MalformedStatuses + s.ProcessingErrors
+			if s.Transactions != tc.expectTxs || failed != tc.expectErrors {
+				t.Errorf("Expected %d transactions with %d failures but got %d with %d", tc.expectTxs,
+					tc.expectErrors, s.Transactions, failed)
+			}
+		})
+	}
+}
+
+func TestRetrySyncRead(t *testing.T) {
+	h, b := newSimBus(t)
+	h.SetRetryPolicy(protocol.RetryPolicy{MaxAttempts: 3})
+	b.AddFault(sim.Fault{ID: 2, Instruction: sim.SyncReadInstruction, Times: 2, Drop: true})
+
+	rs, err := h.SyncRead([]byte{1, 2}, 132, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, r := range rs {
+		if r.ID != byte(i+1) || r.Err != nil || len(r.Data) != 4 {
+			t.Errorf("Unexpected result %+v", r)
+		}
+	}
+	// Only the device whose status was lost is read again.
+	stats := h.Stats()
+	if s := findStats(t, stats, 1, "Sync Read"); s.Transactions != 1 {
+		t.Errorf("Expected 1 read from device ID 1 but got %d", s.Transactions)
+	}
+	if s := findStats(t, stats, 2, "Sync Read"); s.Transactions != 3 || s.Timeouts != 2 {
+		t.Errorf("Expected 3 reads from device ID 2 with 2 timeouts but got %d with %d", s.Transactions, s.Timeouts)
+	}
+}
+
+func TestRetryBulkRead(t *testing.T) {
+	h, b := newSimBus(t)
+	h.SetRetryPolicy(protocol.RetryPolicy{MaxAttempts: 2})
+	b.AddFault(sim.Fault{ID: 1, Instruction: sim.BulkReadInstruction, Times: 2, Drop: true})
+
+	rs, err := h.BulkRead([]protocol.BulkReadDescriptor{{ID: 1, Addr: 132, Length: 4}, {ID: 2, Addr: 0, Length: 2}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !errors.Is(rs[0].Err, protocol.ErrReadTimeout) {
+		t.Errorf("Expected error of %q but got %q", protocol.ErrReadTimeout, rs[0].Err)
+	}
+	// The device whose status was lost on every attempt doesn't prevent the other one from being read.
+	if rs[1].Err != nil || len(rs[1].Data) != 2 || rs[1].Data[0] != 0xFC || rs[1].Data[1] != 0x03 {
+		t.Errorf("Unexpected result %+v", rs[1])
+	}
+}
+
+func TestRetryLateStatus(t *testing.T) {
+	h, b := newSimBus(t)
+	h.SetRetryPolicy(protocol.RetryPolicy{MaxAttempts: 3})
+	b.AddFault(sim.Fault{ID: 1, Instruction: sim.ReadInstruction, Times: 1, Delay: 3 * time.Millisecond})
+
+	if _, err := h.Read(1, 0, 2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The status of the attempt that timed out arrives during the retry, leaving the retry's own status behind. It
+	// must not be taken as the response to the next read.
+	time.Sleep(5 * time.Millisecond)
+	got, err := h.Read(1, 7, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got[0] != 1 {
+		t.Errorf("Expected to read ID 1 from address 7 but got %v", got)
+	}
+}
+
+func TestRetryBackoffCancelled(t *testing.T) {
+	h, b := newSimBus(t)
+	h.SetRetryPolicy(protocol.RetryPolicy{MaxAttempts: 2, Backoff: time.Hour})
+	b.AddFault(sim.Fault{ID: 1, Instruction: sim.ReadInstruction, Drop: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := h.ReadContext(ctx, 1, 132, 4)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error of %q but got %q", context.DeadlineExceeded, err)
+	}
+	if !errors.Is(err, protocol.ErrReadTimeout) {
+		t.Errorf("Expected error of %q but got %q", protocol.ErrReadTimeout, err)
+	}
+	var cancelErr *protocol.RetryCancelledError
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("Expected a retry cancelled error but got %v", err)
+	}
+	if !errors.Is(cancelErr.Err, protocol.ErrReadTimeout) || cancelErr.CtxErr != context.DeadlineExceeded {
+		t.Errorf("Unexpected retry cancelled error %+v", cancelErr)
+	}
+}
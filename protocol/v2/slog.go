@@ -0,0 +1,45 @@
+//go:build go1.21
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogSink returns a PacketSink that logs each packet to logger as a structured record at the given level, or at the
+// warning level if that is higher and the packet has an invalid CRC or reports a processing error.
+func SlogSink(logger *slog.Logger, level slog.Level) PacketSink {
+	return PacketSinkFunc(func(r PacketRecord) {
+		l := level
+		if (!r.CRCValid || r.Error != 0) && l < slog.LevelWarn {
+			l = slog.LevelWarn
+		}
+		ctx := context.Background()
+		if !logger.Enabled(ctx, l) {
+			return
+		}
+		rec := slog.NewRecord(r.Time, l, "dxl packet", 0)
+		rec.AddAttrs(
+			slog.String("direction", r.Direction.String()),
+			slog.Int("id", int(r.ID)),
+			slog.String("instruction", r.InstructionName()),
+		)
+		if r.hasAddress() {
+			rec.AddAttrs(slog.Int("addr", int(r.Address)), slog.Int("len", int(r.Length)))
+		}
+		rec.AddAttrs(slog.String("params", fmt.Sprintf("% X", r.Params)))
+		if r.Direction == Received {
+			rec.AddAttrs(slog.Int("error", int(r.Error)))
+			if err := r.Err(); err != nil {
+				rec.AddAttrs(slog.String("err", err.Error()))
+			}
+			if r.Latency > 0 {
+				rec.AddAttrs(slog.Duration("latency", r.Latency))
+			}
+		}
+		rec.AddAttrs(slog.Bool("crc_valid", r.CRCValid))
+		logger.Handler().Handle(ctx, rec)
+	})
+}
@@ -0,0 +1,46 @@
+//go:build go1.21
+
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/haguro/go-dxl/protocol/v2"
+)
+
+func TestSlogSink(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	d := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x5A, ProcessingError: 4})
+	h := protocol.NewHandler(protocol.NewDecodingLogger(d, protocol.LogReadWrite, protocol.SlogSink(logger,
+		slog.LevelDebug)), 0)
+	h.Write(0x5A, 64, 1)
+
+	// The instruction is logged at the debug level, which the logger discards.
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v (output: %q)", err, buf.String())
+	}
+	expect := map[string]interface{}{
+		"level":       "WARN",
+		"msg":         "dxl packet",
+		"direction":   "received",
+		"id":          float64(0x5A),
+		"instruction": "Status",
+		"params":      "",
+		"error":       float64(4),
+		"err":         protocol.ErrDataRangeError.Error(),
+		"crc_valid":   true,
+	}
+	for k, v := range expect {
+		if entry[k] != v {
+			t.Errorf("Expected %s of %v but got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["latency"]; !ok {
+		t.Error("Expected latency to be logged")
+	}
+}
@@ -0,0 +1,200 @@
+package protocol
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the buckets of the latency histograms.
+var latencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	200 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// Stats holds the statistics of the transactions of a Handler with a single device ID and instruction.
+// An instruction packet is counted under the ID it was sent to, which is BroadcastID for multi-device instructions.
+// The reads of a sync read or bulk read are also counted as transactions of each device read from, and their status
+// packets are counted under the device that was expected to send them. Everything about fast sync read and fast bulk
+// read instructions is counted under BroadcastID, as all devices respond with a single status packet.
+type Stats struct {
+	ID                uint8            //The device ID.
+	Instruction       string           //The name of the instruction, e.g. "Sync Read".
+	Transactions      uint64           //The number of instructions sent, or reads from the device.
+	Timeouts          uint64           //The number of status packets that didn't arrive within the read timeout.
+	CRCErrors         uint64           //The number of status packets that failed the CRC check.
+	MalformedStatuses uint64           //The number of status packets that were truncated or malformed.
+	ProcessingErrors  uint64           //The number of status packets reporting a processing error.
+	BytesSent         uint64           //The number of instruction packet bytes written.
+	BytesReceived     uint64           //The number of status packet bytes read.
+	Latency           LatencyHistogram //The time from writing the instruction packet to reading each status packet.
+}
+
+// LatencyHistogram is a histogram of round-trip latencies.
+type LatencyHistogram struct {
+	Bounds []time.Duration //The upper bounds (inclusive) of the buckets, in increasing order.
+	Counts []uint64        //The number of latencies in each bucket, followed by the number above the last bound.
+	Count  uint64          //The total number of latencies.
+	Sum    time.Duration   //The sum of all latencies.
+	Max    time.Duration   //The largest latency.
+}
+
+// Mean returns the mean latency, or 0 if there are none.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+func (h *LatencyHistogram) observe(d time.Duration) {
+	if h.Counts == nil {
+		h.Bounds = latencyBounds
+		h.Counts = make([]uint64, len(latencyBounds)+1)
+	}
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// statsKey identifies the Stats of a device ID and instruction.
+type statsKey struct {
+	id      byte
+	command byte
+}
+
+// stats collects the statistics of a Handler.
+type stats struct {
+	mu      sync.Mutex
+	entries map[statsKey]*Stats
+}
+
+// get returns the entry of the given ID and instruction, creating it if needed. The lock must be held.
+func (s *stats) get(id, command byte) *Stats {
+	k := statsKey{id, command}
+	e, ok := s.entries[k]
+	if !ok {
+		if s.entries == nil {
+			s.entries = map[statsKey]*Stats{}
+		}
+		e = &Stats{ID: id, Instruction: instructionName(command)}
+		s.entries[k] = e
+	}
+	return e
+}
+
+// sent records a transaction with the given ID and instruction, and the number of instruction packet bytes written
+// for it, if any.
+func (s *stats) sent(id, command byte, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.get(id, command)
+	e.Transactions++
+	e.BytesSent += uint64(n)
+}
+
+// received records the outcome of reading a status packet from the device with the given ID in response to the
+// given instruction: the packet read, if any, the time since the instruction packet was written, and the error that
+// prevented the packet from being read or parsed, or the processing error it reported.
+func (s *stats) received(id, command byte, packet []byte, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.get(id, command)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrReadTimeout):
+		e.Timeouts++
+	case errors.Is(err, ErrStatusCRCInvalid):
+		e.CRCErrors++
+	case isStatusErr(err):
+		e.MalformedStatuses++
+	case isProcessingErr(err):
+		e.ProcessingErrors++
+	}
+	if packet != nil {
+		e.BytesReceived += uint64(len(packet))
+		e.Latency.observe(latency)
+	}
+}
+
+// snapshot returns a copy of the collected statistics, sorted by ID and then by instruction code.
+func (s *stats) snapshot() []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]statsKey, 0, len(s.entries))
+	for k := range s.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].id != keys[j].id {
+			return keys[i].id < keys[j].id
+		}
+		return keys[i].command < keys[j].command
+	})
+	snapshot := make([]Stats, len(keys))
+	for i, k := range keys {
+		snapshot[i] = *s.entries[k]
+		snapshot[i].Latency.Bounds = append([]time.Duration(nil), snapshot[i].Latency.Bounds...)
+		snapshot[i].Latency.Counts = append([]uint64(nil), snapshot[i].Latency.Counts...)
+	}
+	return snapshot
+}
+
+// reset discards the collected statistics.
+func (s *stats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+}
+
+// isProcessingErr reports whether err is a processing error reported by a device in its status packet.
+func isProcessingErr(err error) bool {
+	for _, e := range []error{ErrDeviceError, ErrResultError, ErrInstructionError, ErrDeviceCRCError, ErrDataRangeError,
+		ErrDataLengthError, ErrDataLimitError, ErrAccessError} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns a snapshot of the statistics of the handler's transactions since it was created or its statistics
+// were last reset, one entry for each device ID and instruction, sorted by ID and then by instruction code.
+func (h *Handler) Stats() []Stats {
+	return h.counters.snapshot()
+}
+
+// ResetStats discards the statistics collected so far.
+func (h *Handler) ResetStats() {
+	h.counters.reset()
+}
+
+// observeStatus records the outcome of reading a status packet from the device with the given ID in response to the
+// instruction being processed (see stats.received).
+func (h *Handler) observeStatus(id byte, packet []byte, err error) {
+	h.counters.received(id, h.command, packet, time.Since(h.sentAt), err)
+}
+
+// observeFastStatus records the outcome of reading the status packet of a fast sync read or fast bulk read
+// instruction, and parsing it into the given segments.
+func (h *Handler) observeFastStatus(packet []byte, segments []status, err error) {
+	for _, seg := range segments {
+		if err == nil {
+			err = seg.err
+		}
+	}
+	h.observeStatus(BroadcastID, packet, err)
+}
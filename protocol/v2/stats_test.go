@@ -0,0 +1,131 @@
+package protocol_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/protocol/v2"
+)
+
+// findStats returns the statistics of the given ID and instruction.
+func findStats(t *testing.T, stats []protocol.Stats, id byte, instruction string) protocol.Stats {
+	t.Helper()
+	for _, s := range stats {
+		if s.ID == id && s.Instruction == instruction {
+			return s
+		}
+	}
+	t.Fatalf("Expected statistics of device ID %d and instruction %q but found none", id, instruction)
+	return protocol.Stats{}
+}
+
+func TestStats(t *testing.T) {
+	d1 := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x5A})
+	d2 := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x5B, ProcessingError: 7})
+	h := protocol.NewHandler(protocol.NewDeviceChain(d1, d2), 5*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if _, err := h.Read(0x5A, 132, 4); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := h.Write(0x5B, 64, 1); !errors.Is(err, protocol.ErrAccessError) {
+		t.Errorf("Expected error of %q but got %q", protocol.ErrAccessError, err)
+	}
+	if _, err := h.Read(0x5C, 132, 4); !errors.Is(err, protocol.ErrReadTimeout) {
+		t.Errorf("Expected error of %q but got %q", protocol.ErrReadTimeout, err)
+	}
+	if _, err := h.SyncRead([]byte{0x5A, 0x5C}, 132, 4); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stats := h.Stats()
+	for i := 1; i < len(stats); i++ {
+		if stats[i].ID < stats[i-1].ID {
+			t.Errorf("Expected statistics to be sorted by ID")
+		}
+	}
+
+	read := findStats(t, stats, 0x5A, "Read")
+	if read.Transactions != 2 || read.BytesSent != 2*14 || read.BytesReceived != 2*15 || read.Timeouts != 0 {
+		t.Errorf("Unexpected statistics %+v", read)
+	}
+	if read.Latency.Count != 2 || read.Latency.Max <= 0 || read.Latency.Mean() > read.Latency.Max ||
+		len(read.Latency.Counts) != len(read.Latency.Bounds)+1 {
+		t.Errorf("Unexpected latency histogram %+v", read.Latency)
+	}
+	var n uint64
+	for _, c := range read.Latency.Counts {
+		n += c
+	}
+	if n != read.Latency.Count {
+		t.Errorf("Expected bucket counts to add up to %d but got %d", read.Latency.Count, n)
+	}
+
+	write := findStats(t, stats, 0x5B, "Write")
+	if write.Transactions != 1 || write.ProcessingErrors != 1 || write.BytesReceived != 11 {
+		t.Errorf("Unexpected statistics %+v", write)
+	}
+	if s := findStats(t, stats, 0x5C, "Read"); s.Transactions != 1 || s.Timeouts != 1 || s.Latency.Count != 0 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+
+	// The sync read is counted under the Broadcast ID, and as a read from each device.
+	if s := findStats(t, stats, protocol.BroadcastID, "Sync Read"); s.Transactions != 1 || s.BytesSent != 16 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+	if s := findStats(t, stats, 0x5A, "Sync Read"); s.Transactions != 1 || s.BytesReceived != 15 || s.Timeouts != 0 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+	if s := findStats(t, stats, 0x5C, "Sync Read"); s.Transactions != 1 || s.Timeouts != 1 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+
+	h.ResetStats()
+	if stats := h.Stats(); len(stats) != 0 {
+		t.Errorf("Expected no statistics after reset but got %d entries", len(stats))
+	}
+}
+
+func TestStatsSnapshotCopy(t *testing.T) {
+	h := protocol.NewHandler(protocol.NewDeviceChain(protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x5A})), 0)
+	if _, err := h.Ping(0x5A); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s := findStats(t, h.Stats(), 0x5A, "Ping")
+	bound := s.Latency.Bounds[0]
+	s.Latency.Bounds[0] = time.Hour
+	s.Latency.Counts[0] = 1000
+
+	s = findStats(t, h.Stats(), 0x5A, "Ping")
+	if s.Latency.Bounds[0] != bound || s.Latency.Counts[0] == 1000 {
+		t.Errorf("Expected changes to a snapshot not to affect the statistics, got %+v", s.Latency)
+	}
+}
+
+func TestStatsCRCError(t *testing.T) {
+	// A ping status packet that fails the CRC check.
+	corrupt := []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x04, 0x00, 0x55, 0x00, 0x00, 0x00}
+	h := protocol.NewHandler(&protocol.CannedDevices{Chunks: [][]byte{corrupt}}, 0)
+	if _, err := h.Ping(0x01); !errors.Is(err, protocol.ErrStatusCRCInvalid) {
+		t.Errorf("Expected error of %q but got %q", protocol.ErrStatusCRCInvalid, err)
+	}
+	s := findStats(t, h.Stats(), 0x01, "Ping")
+	if s.Transactions != 1 || s.CRCErrors != 1 || s.BytesSent != 10 || s.BytesReceived != 11 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+}
+
+func TestStatsFastSyncRead(t *testing.T) {
+	d1 := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x5A})
+	d2 := protocol.NewMockDevice(protocol.MockDeviceConfig{ID: 0x5B, ProcessingError: 4})
+	h := protocol.NewHandler(protocol.NewDeviceChain(d1, d2), 0)
+	if _, err := h.FastSyncRead([]byte{0x5A, 0x5B}, 132, 4); !errors.Is(err, protocol.ErrDataRangeError) {
+		t.Errorf("Expected error of %q but got %q", protocol.ErrDataRangeError, err)
+	}
+	s := findStats(t, h.Stats(), protocol.BroadcastID, "Fast Sync Read")
+	if s.Transactions != 1 || s.ProcessingErrors != 1 || s.BytesReceived == 0 || s.Latency.Count != 1 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+}
@@ -0,0 +1,33 @@
+// Package statsvar publishes the transaction statistics of Protocol 2.0 handlers (see protocol.Handler.Stats) as
+// expvar variables, so that the quality of the bus of a long-running program can be watched, e.g. at /debug/vars.
+// It is a package of its own so that programs that don't use it don't depend on expvar, and hence net/http.
+package statsvar
+
+import (
+	"expvar"
+	"strconv"
+
+	protocol "github.com/haguro/go-dxl/protocol/v2"
+)
+
+// Func returns an expvar.Var whose value is a snapshot of the handler's statistics, as a map of device IDs (as
+// decimal strings) to maps of instruction names to the statistics of the device's transactions with the instruction.
+func Func(h *protocol.Handler) expvar.Func {
+	return func() interface{} {
+		devices := map[string]map[string]protocol.Stats{}
+		for _, s := range h.Stats() {
+			id := strconv.Itoa(int(s.ID))
+			if devices[id] == nil {
+				devices[id] = map[string]protocol.Stats{}
+			}
+			devices[id][s.Instruction] = s
+		}
+		return devices
+	}
+}
+
+// Publish publishes the handler's statistics (see Func) as the expvar variable with the given name. As with
+// expvar.Publish, it panics if a variable with the name already exists.
+func Publish(name string, h *protocol.Handler) {
+	expvar.Publish(name, Func(h))
+}
@@ -0,0 +1,50 @@
+package statsvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+
+	"github.com/haguro/go-dxl/internal/simtest"
+	protocol "github.com/haguro/go-dxl/protocol/v2"
+)
+
+// runs is the number of times TestPublish was run.
+var runs int
+
+func TestPublish(t *testing.T) {
+	h := protocol.NewHandler(simtest.NewBus(t, 1020), 0)
+	if _, err := h.Ping(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := h.Read(2, 132, 4); err == nil {
+		t.Fatal("Expected an error but got none")
+	}
+
+	// expvar variables can't be unpublished, so each run of the test needs a name of its own.
+	runs++
+	name := fmt.Sprintf("dxl_test_%d", runs)
+	Publish(name, h)
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatal("Expected the variable to be published")
+	}
+	var devices map[string]map[string]protocol.Stats
+	if err := json.Unmarshal([]byte(v.String()), &devices); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s := devices["1"]["Ping"]; s.Transactions != 1 || s.BytesReceived != 14 || s.Latency.Count != 1 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+	if s := devices["2"]["Read"]; s.Transactions != 1 || s.Timeouts != 1 {
+		t.Errorf("Unexpected statistics %+v", s)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected publishing the same name again to panic")
+		}
+	}()
+	Publish(name, h)
+}
@@ -0,0 +1,178 @@
+// Package provision safely changes the ID and baud rate of Dynamixel devices, e.g. when assembling a robot from
+// devices with the factory default settings (ID 1 at 57600 baud).
+// Each change checks that the new setting is not already in use, disables torque (as the settings are stored in the
+// EEPROM area of the control table), writes the new setting and verifies it by pinging the device with it. If the
+// device can't be found with the new setting, the change is rolled back.
+package provision
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/haguro/go-dxl/bus"
+	"github.com/haguro/go-dxl/controltable"
+	protocol1 "github.com/haguro/go-dxl/protocol/v1"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+var (
+	ErrInvalidID           = errors.New("invalid device ID")
+	ErrIDInUse             = errors.New("ID already in use")
+	ErrUnsupportedBaudRate = errors.New("baud rate not supported by the device")
+	ErrVerifyFailed        = errors.New("device not found with the new setting")
+	ErrRollbackFailed      = errors.New("failed to roll back the change")
+)
+
+// BaudRateSetter is implemented by transports whose baud rate can be changed, such as serial.Port.
+type BaudRateSetter interface {
+	SetBaudRate(baudRate int) error
+	BaudRate() (int, error)
+}
+
+// maxID is the highest ID a device can have.
+const maxID byte = 252
+
+// ChangeID changes the ID of the device with the given ID to newID. It returns ErrIDInUse if a device already
+// responds to newID. Torque is left disabled once the ID is changed.
+func ChangeID(b bus.Bus, id, newID byte) error {
+	if newID > maxID || newID == id {
+		return fmt.Errorf("new ID %d: %w", newID, ErrInvalidID)
+	}
+	m, err := prepare(b, id)
+	if err != nil {
+		return err
+	}
+	if err := checkUnused(b, newID); err != nil {
+		return err
+	}
+	idReg, err := m.Register("ID")
+	if err != nil {
+		return err
+	}
+
+	if err := controltable.WriteRegister(b, id, idReg, int64(newID)); err != nil {
+		return fmt.Errorf("failed to change ID %d to %d: %w", id, newID, err)
+	}
+
+	if err := verify(b, newID, m); err != nil {
+		// The device may have taken the new ID without responding to it. Try both IDs to restore the old one.
+		if rbErr := controltable.WriteRegister(b, newID, idReg, int64(id)); rbErr != nil {
+			if verify(b, id, m) != nil {
+				return fmt.Errorf("failed to change ID %d to %d: %w: %v", id, newID, ErrRollbackFailed, err)
+			}
+		}
+		return fmt.Errorf("failed to change ID %d to %d: %w: %v", id, newID, ErrVerifyFailed, err)
+	}
+	return nil
+}
+
+// ChangeBaudRate changes the baud rate of the device with the given ID, communicated with over the given bus and
+// transport, to the given baud rate. The transport must be set to the device's current baud rate and is left set to
+// the new baud rate if the change succeeds. It returns ErrIDInUse if a device with the same ID already responds at the
+// new baud rate. Torque is left disabled once the baud rate is changed.
+func ChangeBaudRate(b bus.Bus, t BaudRateSetter, id byte, baudRate int) error {
+	oldBaudRate, err := t.BaudRate()
+	if err != nil {
+		return fmt.Errorf("failed to get baud rate: %w", err)
+	}
+	if oldBaudRate == baudRate {
+		return nil
+	}
+	m, err := prepare(b, id)
+	if err != nil {
+		return err
+	}
+	baudReg, err := m.Register("Baud Rate")
+	if err != nil {
+		return err
+	}
+	v, ok := m.BaudRates[baudRate]
+	if !ok {
+		return fmt.Errorf("%d on model %s: %w", baudRate, m.Name, ErrUnsupportedBaudRate)
+	}
+	oldV, err := controltable.ReadRegister(b, id, baudReg)
+	if err != nil {
+		return fmt.Errorf("failed to read baud rate of device ID %d: %w", id, err)
+	}
+
+	if err := t.SetBaudRate(baudRate); err != nil {
+		return fmt.Errorf("failed to set baud rate to %d: %w", baudRate, err)
+	}
+	err = checkUnused(b, id)
+	if rErr := t.SetBaudRate(oldBaudRate); rErr != nil {
+		return fmt.Errorf("failed to restore baud rate to %d: %w", oldBaudRate, rErr)
+	}
+	if err != nil {
+		return fmt.Errorf("at %d baud: %w", baudRate, err)
+	}
+
+	if err := controltable.WriteRegister(b, id, baudReg, v); err != nil {
+		return fmt.Errorf("failed to change baud rate of device ID %d: %w", id, err)
+	}
+
+	if err := t.SetBaudRate(baudRate); err != nil {
+		return fmt.Errorf("failed to set baud rate to %d: %w", baudRate, err)
+	}
+	verifyErr := verify(b, id, m)
+	if verifyErr == nil {
+		return nil
+	}
+
+	// The device may have taken the new baud rate without responding at it. Try to restore the old one at both baud
+	// rates.
+	rbErr := controltable.WriteRegister(b, id, baudReg, oldV)
+	if err := t.SetBaudRate(oldBaudRate); err != nil {
+		return fmt.Errorf("failed to restore baud rate to %d: %w", oldBaudRate, err)
+	}
+	if rbErr != nil {
+		if verify(b, id, m) != nil {
+			return fmt.Errorf("failed to change baud rate of device ID %d: %w: %v", id, ErrRollbackFailed, verifyErr)
+		}
+	}
+	return fmt.Errorf("failed to change baud rate of device ID %d: %w: %v", id, ErrVerifyFailed, verifyErr)
+}
+
+// prepare pings the device with the given ID, looks up its model and disables its torque.
+func prepare(b bus.Bus, id byte) (*controltable.Model, error) {
+	p, err := b.Ping(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ping device ID %d: %w", id, err)
+	}
+	m, err := controltable.Lookup(p.Model)
+	if err != nil {
+		return nil, fmt.Errorf("device ID %d: %w", id, err)
+	}
+	torque, err := m.Register("Torque Enable")
+	if err != nil {
+		return nil, err
+	}
+	if err := controltable.WriteRegister(b, id, torque, 0); err != nil {
+		return nil, fmt.Errorf("failed to disable torque of device ID %d: %w", id, err)
+	}
+	return m, nil
+}
+
+// checkUnused returns ErrIDInUse if a device responds to the given ID. Any error other than a read timeout means the
+// ID can't be confirmed as unused, and is returned.
+func checkUnused(b bus.Bus, id byte) error {
+	_, err := b.Ping(id)
+	if err == nil {
+		return fmt.Errorf("%d: %w", id, ErrIDInUse)
+	}
+	if errors.Is(err, protocol2.ErrReadTimeout) || errors.Is(err, protocol1.ErrReadTimeout) {
+		return nil
+	}
+	return fmt.Errorf("failed to check if ID %d is in use: %w", id, err)
+}
+
+// verify pings the device with the given ID and checks it is of the given model.
+func verify(b bus.Bus, id byte, m *controltable.Model) error {
+	p, err := b.Ping(id)
+	if err != nil {
+		return err
+	}
+	if p.Model != m.Number {
+		return fmt.Errorf("expected model %d, device ID %d is model %d", m.Number, id, p.Model)
+	}
+	return nil
+}
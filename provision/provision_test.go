@@ -0,0 +1,278 @@
+package provision
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/haguro/go-dxl/bus"
+	"github.com/haguro/go-dxl/controltable"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+var errMockBus = errors.New("mock bus error")
+
+// mockDevice is a device whose ID and baud rate are taken from its control table.
+type mockDevice struct {
+	model          *controltable.Model
+	table          [1024]byte
+	ignoreWrite    bool //Acknowledge writes to the ID and Baud Rate registers without applying them.
+	muteAfterWrite bool //Stop responding after the ID or Baud Rate registers are written.
+	mute           bool
+}
+
+func newMockDevice(model uint16, id byte, baudRate int) *mockDevice {
+	m, err := controltable.Lookup(model)
+	if err != nil {
+		panic(err)
+	}
+	d := &mockDevice{model: m}
+	binary.LittleEndian.PutUint16(d.table[0:], model)
+	d.table[7] = id
+	d.table[8] = byte(m.BaudRates[baudRate])
+	d.table[d.torqueAddr()] = 1
+	return d
+}
+
+// torqueAddr returns the address of the device's Torque Enable register.
+func (d *mockDevice) torqueAddr() uint16 {
+	r, _ := d.model.Register("Torque Enable")
+	return r.Addr
+}
+
+// baudRate returns the baud rate the device communicates at.
+func (d *mockDevice) baudRate() int {
+	for rate, v := range d.model.BaudRates {
+		if v == int64(d.table[8]) {
+			return rate
+		}
+	}
+	return 0
+}
+
+// mockBus is a bus of devices behind a transport whose baud rate can be changed.
+type mockBus struct {
+	baudRate int
+	devices  []*mockDevice
+}
+
+func (b *mockBus) SetBaudRate(baudRate int) error {
+	b.baudRate = baudRate
+	return nil
+}
+
+func (b *mockBus) BaudRate() (int, error) { return b.baudRate, nil }
+
+// device returns the device that responds to the given ID at the current baud rate, or an error.
+func (b *mockBus) device(id byte) (*mockDevice, error) {
+	var found []*mockDevice
+	for _, d := range b.devices {
+		if d.table[7] == id && d.baudRate() == b.baudRate && !d.mute {
+			found = append(found, d)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, protocol2.ErrReadTimeout
+	case 1:
+		return found[0], nil
+	}
+	return nil, protocol2.ErrStatusCRCInvalid
+}
+
+func (b *mockBus) Ping(id byte) (bus.PingResponse, error) {
+	d, err := b.device(id)
+	if err != nil {
+		return bus.PingResponse{}, err
+	}
+	return bus.PingResponse{ID: id, Model: binary.LittleEndian.Uint16(d.table[0:])}, nil
+}
+
+func (b *mockBus) Read(id byte, addr, length uint16) ([]byte, error) {
+	d, err := b.device(id)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, d.table[addr:addr+length]...), nil
+}
+
+func (b *mockBus) Write(id byte, addr uint16, data ...byte) error {
+	d, err := b.device(id)
+	if err != nil {
+		return err
+	}
+	if addr == 7 || addr == 8 {
+		if d.table[d.torqueAddr()] != 0 {
+			return protocol2.ErrAccessError
+		}
+		d.mute = d.muteAfterWrite
+		if d.ignoreWrite {
+			return nil
+		}
+	}
+	copy(d.table[addr:], data)
+	return nil
+}
+
+func (b *mockBus) SyncRead(ids []byte, addr, length uint16) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+func (b *mockBus) SyncWrite(addr, length uint16, data ...byte) error { return errMockBus }
+func (b *mockBus) BulkRead(data []bus.BulkReadDescriptor) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+func (b *mockBus) BulkWrite(data []bus.BulkWriteDescriptor) error { return errMockBus }
+
+func TestChangeID(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		newID       byte
+		otherID     byte
+		ignoreWrite bool
+		mute        bool
+		expID       byte
+		expErr      error
+	}{
+		{
+			name:  "No errors",
+			newID: 0x05,
+			expID: 0x05,
+		},
+		{
+			name:   "ID in use",
+			newID:  0x02,
+			expID:  0x01,
+			expErr: ErrIDInUse,
+		},
+		{
+			name:   "Invalid ID",
+			newID:  0xFD,
+			expID:  0x01,
+			expErr: ErrInvalidID,
+		},
+		{
+			name:   "Same ID",
+			newID:  0x01,
+			expID:  0x01,
+			expErr: ErrInvalidID,
+		},
+		{
+			name:        "Change not applied",
+			newID:       0x05,
+			ignoreWrite: true,
+			expID:       0x01,
+			expErr:      ErrVerifyFailed,
+		},
+		{
+			name:   "Device lost",
+			newID:  0x05,
+			mute:   true,
+			expID:  0x05,
+			expErr: ErrRollbackFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newMockDevice(1020, 0x01, 57600)
+			d.ignoreWrite = tc.ignoreWrite
+			d.muteAfterWrite = tc.mute
+			b := &mockBus{baudRate: 57600, devices: []*mockDevice{d, newMockDevice(1020, 0x02, 57600)}}
+
+			err := ChangeID(b, 0x01, tc.newID)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if d.table[7] != tc.expID {
+				t.Errorf("Expected device ID to be %d, got %d", tc.expID, d.table[7])
+			}
+			if tc.expErr == nil && d.table[d.torqueAddr()] != 0 {
+				t.Errorf("Expected torque to be disabled")
+			}
+		})
+	}
+}
+
+func TestChangeBaudRate(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		model       uint16
+		baudRate    int
+		ignoreWrite bool
+		expBaudRate int
+		expErr      error
+	}{
+		{
+			name:        "No errors",
+			baudRate:    1000000,
+			expBaudRate: 1000000,
+		},
+		{
+			name:        "ID in use at new baud rate",
+			baudRate:    115200,
+			expBaudRate: 57600,
+			expErr:      ErrIDInUse,
+		},
+		{
+			name:        "Unsupported baud rate",
+			baudRate:    12345,
+			expBaudRate: 57600,
+			expErr:      ErrUnsupportedBaudRate,
+		},
+		{
+			name:        "Unsupported baud rate for model",
+			baudRate:    10500000,
+			expBaudRate: 57600,
+			expErr:      ErrUnsupportedBaudRate,
+		},
+		{
+			name:        "PRO model",
+			model:       54024,
+			baudRate:    2400,
+			expBaudRate: 2400,
+		},
+		{
+			name:        "Unsupported baud rate for PRO model",
+			model:       54024,
+			baudRate:    9600,
+			expBaudRate: 57600,
+			expErr:      ErrUnsupportedBaudRate,
+		},
+		{
+			name:        "Change not applied",
+			baudRate:    1000000,
+			ignoreWrite: true,
+			expBaudRate: 57600,
+			expErr:      ErrVerifyFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			model := tc.model
+			if model == 0 {
+				model = 1020
+			}
+			d := newMockDevice(model, 0x01, 57600)
+			d.ignoreWrite = tc.ignoreWrite
+			b := &mockBus{baudRate: 57600, devices: []*mockDevice{d, newMockDevice(model, 0x01, 115200)}}
+
+			err := ChangeBaudRate(b, b, 0x01, tc.baudRate)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if d.baudRate() != tc.expBaudRate {
+				t.Errorf("Expected device baud rate to be %d, got register value %d", tc.expBaudRate, d.table[8])
+			}
+			if b.baudRate != tc.expBaudRate {
+				t.Errorf("Expected transport baud rate to be %d, got %d", tc.expBaudRate, b.baudRate)
+			}
+		})
+	}
+}
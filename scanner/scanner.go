@@ -0,0 +1,231 @@
+// Package scanner discovers the Dynamixel devices on a bus, across device IDs, protocol versions and (over transports
+// that support it, such as serial.Port) baud rates.
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/haguro/go-dxl/bus"
+	protocol1 "github.com/haguro/go-dxl/protocol/v1"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+// DefaultReadTimeout is the time to wait for a device to respond before moving on to the next ID (or, when pinging
+// all devices at once, the quiet period after which no more devices are expected to respond).
+const DefaultReadTimeout = 10 * time.Millisecond
+
+// MaxID is the highest ID a device can have.
+const MaxID byte = 252
+
+// StandardBaudRates are the baud rates supported by Dynamixel devices, in the order they are scanned by default. The
+// factory default rates come first.
+var StandardBaudRates = []int{57600, 1000000, 115200, 9600, 2000000, 3000000, 4000000, 4500000}
+
+// BroadcastPinger is implemented by handlers that can ping every device on the bus with a single instruction and
+// collect all their responses. Handlers that don't implement it are scanned one ID at a time.
+type BroadcastPinger interface {
+	BroadcastPing() ([]bus.PingResponse, error)
+}
+
+// The Protocol 2.0 handler collects the responses of all devices to a broadcast ping.
+var _ BroadcastPinger = (*protocol2.Handler)(nil)
+
+// BaudRateSetter is implemented by transports whose baud rate can be changed, such as serial.Port.
+type BaudRateSetter interface {
+	SetBaudRate(baudRate int) error
+	BaudRate() (int, error)
+}
+
+// Protocol describes a protocol version to scan for devices with.
+type Protocol struct {
+	Version string                                                    //The protocol version, e.g. "2.0".
+	NewBus  func(rw io.ReadWriter, readTimeout time.Duration) bus.Bus //Creates a handler for the protocol.
+}
+
+var (
+	Protocol1 = Protocol{Version: "1.0", NewBus: func(rw io.ReadWriter, readTimeout time.Duration) bus.Bus {
+		return protocol1.NewHandler(rw, readTimeout)
+	}}
+	Protocol2 = Protocol{Version: "2.0", NewBus: func(rw io.ReadWriter, readTimeout time.Duration) bus.Bus {
+		return protocol2.NewHandler(rw, readTimeout)
+	}}
+)
+
+// Config describes what to scan for.
+type Config struct {
+	// Protocols are the protocol versions to scan for, in order. Defaults to Protocol 2.0 then 1.0.
+	Protocols []Protocol
+	// BaudRates are the baud rates to scan at, in order. They are only used if the transport implements
+	// BaudRateSetter, and default to StandardBaudRates. The transport's baud rate is restored once the scan is done.
+	BaudRates []int
+	// ReadTimeout is the time to wait for devices to respond. Defaults to DefaultReadTimeout.
+	ReadTimeout time.Duration
+}
+
+// Device describes a device found by a scan.
+type Device struct {
+	ID       byte   //The ID of the device.
+	Model    uint16 //The model number of the device. Zero if the device responded with an error.
+	Firmware byte   //The firmware version of the device. Zero if the device responded with an error.
+	Protocol string //The protocol version the device responded to.
+	BaudRate int    //The baud rate the device responded at. Zero if the transport's baud rate can't be changed.
+	Err      error  //The error reported by the device when it was pinged, if any (e.g. a hardware alert).
+}
+
+// Collision reports an ID that appears to be used by more than one device, either because more than one device
+// responded with it or because the responses were corrupted by devices talking over each other.
+type Collision struct {
+	ID       byte
+	Protocol string
+	BaudRate int
+}
+
+// Result holds the devices found by a scan.
+type Result struct {
+	Devices    []Device
+	Collisions []Collision
+}
+
+// Scan scans the bus behind the given transport for devices, at each of the configured baud rates and protocol
+// versions. Where the protocol handler implements BroadcastPinger, all the devices are pinged at once, otherwise each
+// ID is pinged in turn.
+func Scan(rw io.ReadWriter, config Config) (Result, error) {
+	if len(config.Protocols) == 0 {
+		config.Protocols = []Protocol{Protocol2, Protocol1}
+	}
+	if config.ReadTimeout == 0 {
+		config.ReadTimeout = DefaultReadTimeout
+	}
+
+	var result Result
+	baudRates := []int{0}
+	brs, ok := rw.(BaudRateSetter)
+	if ok {
+		baudRates = config.BaudRates
+		if len(baudRates) == 0 {
+			baudRates = StandardBaudRates
+		}
+		original, err := brs.BaudRate()
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to get baud rate: %w", err)
+		}
+		defer brs.SetBaudRate(original)
+	}
+
+	for _, baudRate := range baudRates {
+		if ok {
+			if err := brs.SetBaudRate(baudRate); err != nil {
+				return result, fmt.Errorf("failed to set baud rate to %d: %w", baudRate, err)
+			}
+		}
+		for _, p := range config.Protocols {
+			devices, collisions, err := ScanBus(p.NewBus(rw, config.ReadTimeout))
+			if err != nil {
+				return result, fmt.Errorf("failed to scan with Protocol %s at %d baud: %w", p.Version, baudRate, err)
+			}
+			for _, d := range devices {
+				d.Protocol, d.BaudRate = p.Version, baudRate
+				result.Devices = append(result.Devices, d)
+			}
+			for _, id := range collisions {
+				result.Collisions = append(result.Collisions, Collision{ID: id, Protocol: p.Version, BaudRate: baudRate})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ScanBus scans the given bus for devices and returns the devices found, ordered by ID, and the IDs that appear to be
+// used by more than one device. The Protocol and BaudRate of the returned devices are left for the caller to fill in.
+func ScanBus(b bus.Bus) ([]Device, []byte, error) {
+	if bp, ok := b.(BroadcastPinger); ok {
+		return broadcastScan(bp)
+	}
+
+	var devices []Device
+	var collisions []byte
+	for id := 0; id <= int(MaxID); id++ {
+		p, err := b.Ping(byte(id))
+		switch {
+		case err == nil:
+			devices = append(devices, Device{ID: p.ID, Model: p.Model, Firmware: p.Firmware})
+		case isTimeout(err):
+			continue
+		case isCorrupted(err):
+			collisions = append(collisions, byte(id))
+		case isDeviceErr(err):
+			devices = append(devices, Device{ID: byte(id), Err: err})
+		default:
+			return nil, nil, fmt.Errorf("failed to ping ID %d: %w", id, err)
+		}
+	}
+	return devices, collisions, nil
+}
+
+func broadcastScan(bp BroadcastPinger) ([]Device, []byte, error) {
+	responses, err := bp.BroadcastPing()
+	var duplicateErr *protocol2.DuplicateIDError
+	if err != nil && !errors.As(err, &duplicateErr) {
+		return nil, nil, fmt.Errorf("failed to broadcast ping: %w", err)
+	}
+
+	var devices []Device
+	var collisions []byte
+	seen := map[byte]int{}
+	for _, p := range responses {
+		seen[p.ID]++
+		if seen[p.ID] == 2 {
+			collisions = append(collisions, p.ID)
+		}
+		if seen[p.ID] > 1 {
+			continue
+		}
+		devices = append(devices, Device{ID: p.ID, Model: p.Model, Firmware: p.Firmware})
+	}
+	if duplicateErr != nil {
+		for _, id := range duplicateErr.IDs {
+			if seen[id] < 2 {
+				collisions = append(collisions, id)
+			}
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i] < collisions[j] })
+	return devices, collisions, nil
+}
+
+func isTimeout(err error) bool {
+	return errors.Is(err, protocol2.ErrReadTimeout) || errors.Is(err, protocol1.ErrReadTimeout)
+}
+
+// isCorrupted reports whether err was caused by a corrupted status packet, which is what happens when more than one
+// device responds at the same time.
+func isCorrupted(err error) bool {
+	return errors.Is(err, protocol2.ErrStatusCRCInvalid) ||
+		errors.Is(err, protocol2.ErrMalformedStatus) ||
+		errors.Is(err, protocol2.ErrTruncatedStatus) ||
+		errors.Is(err, protocol2.ErrInvalidStatusLength) ||
+		errors.Is(err, protocol1.ErrStatusChecksumInvalid) ||
+		errors.Is(err, protocol1.ErrMalformedStatus) ||
+		errors.Is(err, protocol1.ErrTruncatedStatus) ||
+		errors.Is(err, protocol1.ErrInvalidStatusLength)
+}
+
+// isDeviceErr reports whether err was reported by a device in its status packet, meaning the device is present.
+func isDeviceErr(err error) bool {
+	var deviceErr protocol1.DeviceError
+	return errors.As(err, &deviceErr) ||
+		errors.Is(err, protocol2.ErrDeviceError) ||
+		errors.Is(err, protocol2.ErrResultError) ||
+		errors.Is(err, protocol2.ErrInstructionError) ||
+		errors.Is(err, protocol2.ErrDeviceCRCError) ||
+		errors.Is(err, protocol2.ErrDataRangeError) ||
+		errors.Is(err, protocol2.ErrDataLengthError) ||
+		errors.Is(err, protocol2.ErrDataLimitError) ||
+		errors.Is(err, protocol2.ErrAccessError)
+}
@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/bus"
+	protocol1 "github.com/haguro/go-dxl/protocol/v1"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+var errMockBus = errors.New("mock bus error")
+
+// mockTransport is a transport whose baud rate can be changed. It is never read from or written to, as the mock buses
+// respond based on its baud rate only.
+type mockTransport struct {
+	baudRate int
+}
+
+func (t *mockTransport) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (t *mockTransport) Write(p []byte) (int, error) { return len(p), nil }
+func (t *mockTransport) SetBaudRate(baudRate int) error {
+	t.baudRate = baudRate
+	return nil
+}
+func (t *mockTransport) BaudRate() (int, error) { return t.baudRate, nil }
+
+// mockBus responds to pings with the given responses, keyed by ID. The responses are ignored if the transport's baud
+// rate doesn't match.
+type mockBus struct {
+	transport *mockTransport
+	baudRate  int
+	responses map[byte][]bus.PingResponse
+	errs      map[byte]error
+	pinged    int
+}
+
+func (b *mockBus) Ping(id byte) (bus.PingResponse, error) {
+	b.pinged++
+	if b.transport != nil && b.transport.baudRate != b.baudRate {
+		return bus.PingResponse{}, protocol2.ErrReadTimeout
+	}
+	if err, ok := b.errs[id]; ok {
+		return bus.PingResponse{}, err
+	}
+	rs, ok := b.responses[id]
+	if !ok {
+		return bus.PingResponse{}, protocol2.ErrReadTimeout
+	}
+	if len(rs) > 1 {
+		return bus.PingResponse{}, protocol2.ErrStatusCRCInvalid
+	}
+	return rs[0], nil
+}
+
+func (b *mockBus) Read(id byte, addr, length uint16) ([]byte, error) { return nil, errMockBus }
+func (b *mockBus) Write(id byte, addr uint16, data ...byte) error    { return errMockBus }
+func (b *mockBus) SyncRead(ids []byte, addr, length uint16) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+func (b *mockBus) SyncWrite(addr, length uint16, data ...byte) error { return errMockBus }
+func (b *mockBus) BulkRead(data []bus.BulkReadDescriptor) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+func (b *mockBus) BulkWrite(data []bus.BulkWriteDescriptor) error { return errMockBus }
+
+// mockBroadcastBus is a mockBus that can also ping all devices at once.
+type mockBroadcastBus struct {
+	mockBus
+	broadcasts int
+	corrupted  []byte
+}
+
+func (b *mockBroadcastBus) BroadcastPing() ([]bus.PingResponse, error) {
+	b.broadcasts++
+	if b.transport != nil && b.transport.baudRate != b.baudRate {
+		return nil, nil
+	}
+	var all []bus.PingResponse
+	for id := 0; id <= 0xFF; id++ {
+		all = append(all, b.responses[byte(id)]...)
+	}
+	if len(b.corrupted) > 0 {
+		return all, &protocol2.DuplicateIDError{IDs: b.corrupted}
+	}
+	return all, nil
+}
+
+func TestScanBus(t *testing.T) {
+	responses := map[byte][]bus.PingResponse{
+		0x01: {{ID: 0x01, Model: 1020, Firmware: 45}},
+		0x05: {{ID: 0x05, Model: 1060, Firmware: 44}, {ID: 0x05, Model: 1060, Firmware: 44}},
+		0x20: {{ID: 0x20, Model: 1200, Firmware: 46}},
+	}
+	var testCases = []struct {
+		name          string
+		bus           bus.Bus
+		expDevices    []Device
+		expCollisions []byte
+		expErr        error
+	}{
+		{
+			name: "One ID at a time",
+			bus: &mockBus{responses: responses, errs: map[byte]error{
+				0x30: fmt.Errorf("device ID 48: %w", protocol2.ErrDeviceError),
+				0x31: protocol1.DeviceError(0x04),
+			}},
+			expDevices: []Device{
+				{ID: 0x01, Model: 1020, Firmware: 45},
+				{ID: 0x20, Model: 1200, Firmware: 46},
+				{ID: 0x30, Err: fmt.Errorf("device ID 48: %w", protocol2.ErrDeviceError)},
+				{ID: 0x31, Err: protocol1.DeviceError(0x04)},
+			},
+			expCollisions: []byte{0x05},
+		},
+		{
+			name: "Broadcast",
+			bus:  &mockBroadcastBus{mockBus: mockBus{responses: responses}},
+			expDevices: []Device{
+				{ID: 0x01, Model: 1020, Firmware: 45},
+				{ID: 0x05, Model: 1060, Firmware: 44},
+				{ID: 0x20, Model: 1200, Firmware: 46},
+			},
+			expCollisions: []byte{0x05},
+		},
+		{
+			name: "Broadcast with corrupted responses",
+			bus:  &mockBroadcastBus{mockBus: mockBus{responses: responses}, corrupted: []byte{0x05, 0x09}},
+			expDevices: []Device{
+				{ID: 0x01, Model: 1020, Firmware: 45},
+				{ID: 0x05, Model: 1060, Firmware: 44},
+				{ID: 0x20, Model: 1200, Firmware: 46},
+			},
+			expCollisions: []byte{0x05, 0x09},
+		},
+		{
+			name:   "Bus error",
+			bus:    &mockBus{errs: map[byte]error{0x10: errMockBus}},
+			expErr: errMockBus,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, collisions, err := ScanBus(tc.bus)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(devices, tc.expDevices) {
+				t.Errorf("Expected devices %+v, got %+v", tc.expDevices, devices)
+			}
+			if !reflect.DeepEqual(collisions, tc.expCollisions) {
+				t.Errorf("Expected collisions %v, got %v", tc.expCollisions, collisions)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	transport := &mockTransport{baudRate: 9600}
+	bus1 := &mockBus{transport: transport, baudRate: 1000000, responses: map[byte][]bus.PingResponse{
+		0x02: {{ID: 0x02, Model: 12, Firmware: 24}},
+	}}
+	bus2 := &mockBroadcastBus{mockBus: mockBus{transport: transport, baudRate: 57600,
+		responses: map[byte][]bus.PingResponse{
+			0x01: {{ID: 0x01, Model: 1020, Firmware: 45}},
+			0x07: {{ID: 0x07, Model: 1020, Firmware: 45}, {ID: 0x07, Model: 1020, Firmware: 45}},
+		}}}
+	config := Config{
+		Protocols: []Protocol{
+			{Version: "2.0", NewBus: func(rw io.ReadWriter, readTimeout time.Duration) bus.Bus { return bus2 }},
+			{Version: "1.0", NewBus: func(rw io.ReadWriter, readTimeout time.Duration) bus.Bus { return bus1 }},
+		},
+		BaudRates: []int{57600, 1000000},
+	}
+
+	got, err := Scan(transport, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := Result{
+		Devices: []Device{
+			{ID: 0x01, Model: 1020, Firmware: 45, Protocol: "2.0", BaudRate: 57600},
+			{ID: 0x07, Model: 1020, Firmware: 45, Protocol: "2.0", BaudRate: 57600},
+			{ID: 0x02, Model: 12, Firmware: 24, Protocol: "1.0", BaudRate: 1000000},
+		},
+		Collisions: []Collision{{ID: 0x07, Protocol: "2.0", BaudRate: 57600}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected %+v, got %+v", exp, got)
+	}
+	if bus2.broadcasts != 2 || bus2.pinged != 0 {
+		t.Errorf("Expected Protocol 2.0 bus to be broadcast pinged twice, got %d broadcasts and %d pings",
+			bus2.broadcasts, bus2.pinged)
+	}
+	if bus1.pinged != 2*(int(MaxID)+1) {
+		t.Errorf("Expected Protocol 1.0 bus to be pinged %d times, got %d", 2*(int(MaxID)+1), bus1.pinged)
+	}
+	if transport.baudRate != 9600 {
+		t.Errorf("Expected baud rate to be restored to 9600, got %d", transport.baudRate)
+	}
+}
@@ -0,0 +1,41 @@
+// Package serial provides a serial port transport for communicating with Dynamixel devices, using only the Go standard
+// library. A Port implements io.ReadWriter and can be passed directly to the protocol handlers.
+package serial
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultBaudRate is the factory default baud rate of most Dynamixel devices.
+const DefaultBaudRate = 57600
+
+var (
+	ErrUnsupportedPlatform = errors.New("serial ports are not supported on this platform")
+	ErrInvalidBaudRate     = errors.New("invalid baud rate")
+	ErrInvalidReadTimeout  = errors.New("read timeout must be between 0 and 25.5s")
+	ErrPortClosed          = errors.New("serial port is closed")
+)
+
+// Config describes the settings a serial port is opened with. The port is always configured in raw mode with 8 data
+// bits, no parity, one stop bit and no flow control (8N1), as required by Dynamixel devices.
+type Config struct {
+	// BaudRate is the communication speed in bits per second. Any rate supported by the serial adapter can be used
+	// (e.g. 57600 up to 4.5M), not just the standard rates. Defaults to DefaultBaudRate.
+	BaudRate int
+	// ReadTimeout is the longest time a call to Read waits for data to arrive (VTIME), in increments of 100ms. If
+	// zero, Read returns immediately when there is no data to read. Either way, Read returns io.EOF when no data
+	// arrived, which the protocol handlers treat as "nothing to read yet".
+	ReadTimeout time.Duration
+	// MinRead is the minimum number of bytes a call to Read waits for (VMIN) before returning, unless ReadTimeout
+	// expires first.
+	MinRead byte
+}
+
+// vtime returns the VTIME value (in tenths of a second) for the config's read timeout.
+func (c Config) vtime() (byte, error) {
+	if c.ReadTimeout < 0 || c.ReadTimeout > 255*100*time.Millisecond {
+		return 0, ErrInvalidReadTimeout
+	}
+	return byte((c.ReadTimeout + 99*time.Millisecond) / (100 * time.Millisecond)), nil
+}
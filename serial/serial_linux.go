@@ -0,0 +1,335 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64 || loong64)
+
+package serial
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Poll event flags from the asm-generic poll header.
+const (
+	pollin   = 0x1
+	pollout  = 0x4
+	pollerr  = 0x8
+	pollhup  = 0x10
+	pollnval = 0x20
+)
+
+// pollFd mirrors the kernel's struct pollfd.
+type pollFd struct {
+	fd      int32
+	events  int16
+	revents int16
+}
+
+// Port is an open serial port (e.g. /dev/ttyUSB0 or /dev/ttyACM0).
+//
+// The port's file descriptor is non-blocking and Read and Write wait for it with poll, along with the read end of a
+// wake pipe. Close writes to the pipe before closing the file descriptor, which wakes any Read or Write waiting on the
+// port instead of waiting for them to time out (or, with no read timeout, forever).
+type Port struct {
+	name        string
+	readTimeout time.Duration
+	minRead     int
+	closing     int32        // Set (atomically) once Close is called.
+	mu          sync.RWMutex // Guards fd and wake against being closed while in use.
+	fd          int
+	wake        [2]int // The read and write ends of the wake pipe.
+}
+
+// Open opens the serial port with the given device name, in raw 8N1 mode with the given config, and for exclusive
+// use. Any data already in the port's input and output buffers is discarded.
+func Open(name string, config Config) (*Port, error) {
+	if config.BaudRate == 0 {
+		config.BaudRate = DefaultBaudRate
+	}
+	if config.BaudRate < 0 {
+		return nil, ErrInvalidBaudRate
+	}
+	vt, err := config.vtime()
+	if err != nil {
+		return nil, err
+	}
+
+	// Opening in non-blocking mode also prevents waiting for the carrier detect line which most adapters don't set.
+	fd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serial port %s: %w", name, err)
+	}
+	p := &Port{
+		name:        name,
+		readTimeout: time.Duration(vt) * 100 * time.Millisecond,
+		minRead:     int(config.MinRead),
+		fd:          fd,
+	}
+
+	if err := syscall.Pipe2(p.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to create wake pipe for serial port %s: %w", name, err)
+	}
+	if err := ioctl(fd, tiocexcl, 0); err != nil {
+		p.closeFds()
+		return nil, fmt.Errorf("failed to get exclusive access to serial port %s: %w", name, err)
+	}
+
+	t, err := getTermios(fd)
+	if err != nil {
+		p.closeFds()
+		return nil, fmt.Errorf("failed to get serial port %s attributes: %w", name, err)
+	}
+	t.Iflag &^= ignbrk | brkint | parmrk | istrip | inlcr | igncr | icrnl | ixon | ixany | ixoff | inpck
+	t.Oflag &^= opost
+	t.Lflag &^= echo | echonl | icanon | isig | iexten
+	t.Cflag &^= csize | parenb | cstopb | crtscts
+	t.Cflag |= cs8 | cread | clocal
+	setSpeed(t, config.BaudRate)
+	// The read timeout and minimum are applied by Read, so reads of the non-blocking file descriptor return at once.
+	t.Cc[vmin] = 0
+	t.Cc[vtime] = 0
+	if err := setTermios(fd, t); err != nil {
+		p.closeFds()
+		return nil, fmt.Errorf("failed to set serial port %s attributes: %w", name, err)
+	}
+
+	if err := ioctl(fd, tcflsh, tcioflush); err != nil {
+		p.closeFds()
+		return nil, fmt.Errorf("failed to flush serial port %s: %w", name, err)
+	}
+
+	return p, nil
+}
+
+// Name returns the device name the port was opened with.
+func (p *Port) Name() string {
+	return p.name
+}
+
+// Read reads up to len(b) bytes from the port, waiting for data as configured by the port's ReadTimeout and MinRead
+// (with the same meaning as the terminal's VTIME and VMIN). It returns io.EOF if no data arrived, or ErrPortClosed if
+// the port is closed while waiting.
+func (p *Port) Read(b []byte) (int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.fd < 0 {
+		return 0, ErrPortClosed
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	// With no minimum, the read timeout limits the wait for the first byte; otherwise it's the longest gap between
+	// bytes (and there is no limit on the wait for the first byte).
+	timeout := p.readTimeout
+	if p.minRead > 0 && timeout == 0 {
+		timeout = -1
+	}
+	first := timeout
+	if p.minRead > 0 {
+		first = -1
+	}
+	want := p.minRead
+	if want > len(b) {
+		want = len(b)
+	}
+
+	var N int
+	for wait := first; ; wait = timeout {
+		ready, err := p.poll(pollin, wait)
+		if err != nil {
+			return N, fmt.Errorf("failed to read from serial port %s: %w", p.name, err)
+		}
+		if !ready {
+			break
+		}
+		n, err := syscall.Read(p.fd, b[N:])
+		if err == syscall.EINTR || err == syscall.EAGAIN {
+			continue
+		}
+		if err != nil {
+			return N, fmt.Errorf("failed to read from serial port %s: %w", p.name, err)
+		}
+		if n == 0 {
+			break
+		}
+		N += n
+		if N >= want {
+			break
+		}
+	}
+	if N == 0 {
+		return 0, io.EOF
+	}
+	return N, nil
+}
+
+// Write writes all of b to the port.
+func (p *Port) Write(b []byte) (int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.fd < 0 {
+		return 0, ErrPortClosed
+	}
+	var N int
+	for N < len(b) {
+		n, err := syscall.Write(p.fd, b[N:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN {
+			// The output buffer is full; wait for room.
+			_, err = p.poll(pollout, -1)
+			n = 0
+		}
+		if err != nil {
+			return N, fmt.Errorf("failed to write to serial port %s: %w", p.name, err)
+		}
+		N += n
+	}
+	return N, nil
+}
+
+// SetBaudRate changes the port's baud rate. Any data waiting to be sent is transmitted at the previous rate first.
+func (p *Port) SetBaudRate(baudRate int) error {
+	if baudRate <= 0 {
+		return ErrInvalidBaudRate
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.fd < 0 {
+		return ErrPortClosed
+	}
+	t, err := getTermios(p.fd)
+	if err != nil {
+		return fmt.Errorf("failed to get serial port %s attributes: %w", p.name, err)
+	}
+	setSpeed(t, baudRate)
+	if err := setTermios(p.fd, t); err != nil {
+		return fmt.Errorf("failed to set serial port %s baud rate: %w", p.name, err)
+	}
+	return nil
+}
+
+// BaudRate returns the port's current output baud rate.
+func (p *Port) BaudRate() (int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.fd < 0 {
+		return 0, ErrPortClosed
+	}
+	t, err := getTermios(p.fd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get serial port %s attributes: %w", p.name, err)
+	}
+	return int(t.Ospeed), nil
+}
+
+// Flush discards any data received but not yet read, and any data written but not yet transmitted.
+func (p *Port) Flush() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.fd < 0 {
+		return ErrPortClosed
+	}
+	if err := ioctl(p.fd, tcflsh, tcioflush); err != nil {
+		return fmt.Errorf("failed to flush serial port %s: %w", p.name, err)
+	}
+	return nil
+}
+
+// Close closes the port. Any Read or Write waiting on the port returns ErrPortClosed.
+func (p *Port) Close() error {
+	if !atomic.CompareAndSwapInt32(&p.closing, 0, 1) {
+		return ErrPortClosed
+	}
+	// Wake any waiting Read or Write so they release the lock. The pipe is never read so it stays readable.
+	syscall.Write(p.wake[1], []byte{0})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ioctl(p.fd, tiocnxcl, 0)
+	if err := p.closeFds(); err != nil {
+		return fmt.Errorf("failed to close serial port %s: %w", p.name, err)
+	}
+	return nil
+}
+
+// closeFds closes the port's file descriptor and wake pipe, returning the error from closing the port's file
+// descriptor.
+func (p *Port) closeFds() error {
+	err := syscall.Close(p.fd)
+	syscall.Close(p.wake[0])
+	syscall.Close(p.wake[1])
+	p.fd = -1
+	return err
+}
+
+// poll waits up to timeout (or indefinitely if negative) for the port's file descriptor to be ready for the given
+// events, and reports whether it is. It returns ErrPortClosed if the port is closed while waiting.
+func (p *Port) poll(events int16, timeout time.Duration) (bool, error) {
+	fds := [2]pollFd{
+		{fd: int32(p.fd), events: events},
+		{fd: int32(p.wake[0]), events: pollin},
+	}
+	var ts *syscall.Timespec
+	if timeout >= 0 {
+		t := syscall.NsecToTimespec(int64(timeout))
+		ts = &t
+	}
+	for {
+		n, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&fds[0])), uintptr(len(fds)),
+			uintptr(unsafe.Pointer(ts)), 0, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return false, errno
+		}
+		if fds[1].revents != 0 {
+			return false, ErrPortClosed
+		}
+		if fds[0].revents&pollnval != 0 {
+			return false, syscall.EBADF
+		}
+		// Errors and hang-ups are reported by the following read or write.
+		return n > 0 && fds[0].revents&(events|pollerr|pollhup) != 0, nil
+	}
+}
+
+// setSpeed sets both the input and output speed of t to the given baud rate using BOTHER.
+func setSpeed(t *termios2, baudRate int) {
+	t.Cflag &^= cbaud | cbaud<<ibshift
+	t.Cflag |= bother | bother<<ibshift
+	t.Ispeed = uint32(baudRate)
+	t.Ospeed = uint32(baudRate)
+}
+
+func getTermios(fd int) (*termios2, error) {
+	t := &termios2{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), tcgets2, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return t, nil
+}
+
+func setTermios(fd int, t *termios2) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), tcsets2, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func ioctl(fd int, req, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
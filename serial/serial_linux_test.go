@@ -0,0 +1,323 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64 || loong64)
+
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/internal/pty"
+	"github.com/haguro/go-dxl/protocol/v2"
+)
+
+// openPTY opens a new pseudo-terminal pair and returns the master side and the name of the slave device, which
+// stands in for the serial adapter's device file.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, name, err := pty.Open()
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+	return master, name
+}
+
+func TestOpen(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		config    Config
+		expectErr error
+		expBaud   int
+	}{
+		{
+			name:    "Default baud rate",
+			expBaud: DefaultBaudRate,
+		},
+		{
+			name:    "Standard baud rate",
+			config:  Config{BaudRate: 1000000},
+			expBaud: 1000000,
+		},
+		{
+			name:    "Non-standard baud rate",
+			config:  Config{BaudRate: 4500000},
+			expBaud: 4500000,
+		},
+		{
+			name:      "Invalid baud rate",
+			config:    Config{BaudRate: -1},
+			expectErr: ErrInvalidBaudRate,
+		},
+		{
+			name:      "Invalid read timeout",
+			config:    Config{ReadTimeout: 30 * time.Second},
+			expectErr: ErrInvalidReadTimeout,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, name := openPTY(t)
+			p, err := Open(name, tc.config)
+			if err != nil {
+				if tc.expectErr == nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expectErr, err)
+				}
+				return
+			}
+			defer p.Close()
+			if tc.expectErr != nil {
+				t.Errorf("Expected error but got none")
+			}
+
+			baud, err := p.BaudRate()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if baud != tc.expBaud {
+				t.Errorf("Expected baud rate %d, got %d", tc.expBaud, baud)
+			}
+			tr, err := getTermios(p.fd)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tr.Lflag&icanon != 0 || tr.Oflag&opost != 0 || tr.Iflag&icrnl != 0 {
+				t.Errorf("Expected port to be in raw mode, got flags %+v", tr)
+			}
+			if tr.Cflag&csize != cs8 || tr.Cflag&(parenb|cstopb) != 0 {
+				t.Errorf("Expected port to be in 8N1 mode, got cflag %#x", tr.Cflag)
+			}
+		})
+	}
+}
+
+func TestOpenNonExistent(t *testing.T) {
+	if _, err := Open("/dev/does-not-exist", Config{}); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestSetBaudRate(t *testing.T) {
+	_, name := openPTY(t)
+	p, err := Open(name, Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	for _, baud := range []int{57600, 115200, 1000000, 2000000, 3000000, 4000000, 4500000, 123456} {
+		if err := p.SetBaudRate(baud); err != nil {
+			t.Fatalf("Unexpected error setting baud rate %d: %v", baud, err)
+		}
+		got, err := p.BaudRate()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != baud {
+			t.Errorf("Expected baud rate %d, got %d", baud, got)
+		}
+	}
+
+	if err := p.SetBaudRate(0); !errors.Is(err, ErrInvalidBaudRate) {
+		t.Errorf("Expected error of %q but got %q", ErrInvalidBaudRate, err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	master, name := openPTY(t)
+	p, err := Open(name, Config{ReadTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	// Bytes that would be altered if the port wasn't in raw mode.
+	data := []byte{0xFF, 0xFF, 0xFD, 0x00, '\r', '\n', 0x03, 0x04, 0x11, 0x13, 0x7F}
+
+	if _, err := master.Write(data); err != nil {
+		t.Fatalf("Unexpected error writing to master: %v", err)
+	}
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(p, got); err != nil {
+		t.Fatalf("Unexpected error reading from port: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Expected to read %v, got %v", data, got)
+	}
+
+	if _, err := p.Write(data); err != nil {
+		t.Fatalf("Unexpected error writing to port: %v", err)
+	}
+	got = make([]byte, len(data))
+	master.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(master, got); err != nil {
+		t.Fatalf("Unexpected error reading from master: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Expected to read %v, got %v", data, got)
+	}
+
+	start := time.Now()
+	if _, err := p.Read(got); err != io.EOF {
+		t.Errorf("Expected %q when no data is available, got %v", io.EOF, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected read to wait for the read timeout, returned after %v", elapsed)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	master, name := openPTY(t)
+	p, err := Open(name, Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	if _, err := master.Write([]byte{0x01, 0x02, 0x03}); err != nil {
+		t.Fatalf("Unexpected error writing to master: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n, err := p.Read(make([]byte, 3)); err != io.EOF {
+		t.Errorf("Expected %q after flush, got %d bytes and error %v", io.EOF, n, err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	_, name := openPTY(t)
+	p, err := Open(name, Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := p.Read(make([]byte, 1)); !errors.Is(err, ErrPortClosed) {
+		t.Errorf("Expected error of %q but got %q", ErrPortClosed, err)
+	}
+	if _, err := p.Write([]byte{0x01}); !errors.Is(err, ErrPortClosed) {
+		t.Errorf("Expected error of %q but got %q", ErrPortClosed, err)
+	}
+	if err := p.Close(); !errors.Is(err, ErrPortClosed) {
+		t.Errorf("Expected error of %q but got %q", ErrPortClosed, err)
+	}
+}
+
+func TestCloseWhileReading(t *testing.T) {
+	_, name := openPTY(t)
+	// With a minimum and no timeout, Read waits for data indefinitely.
+	p, err := Open(name, Config{MinRead: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := p.Read(make([]byte, 1))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() { closed <- p.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked by a pending Read")
+	}
+	if err := <-done; !errors.Is(err, ErrPortClosed) {
+		t.Errorf("Expected error of %q but got %q", ErrPortClosed, err)
+	}
+}
+
+func TestMinRead(t *testing.T) {
+	master, name := openPTY(t)
+	p, err := Open(name, Config{MinRead: 4, ReadTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	go func() {
+		master.Write([]byte{0x01, 0x02})
+		time.Sleep(20 * time.Millisecond)
+		master.Write([]byte{0x03, 0x04, 0x05})
+	}()
+	got := make([]byte, 8)
+	n, err := p.Read(got)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n < 4 {
+		t.Errorf("Expected to read at least 4 bytes, got %v", got[:n])
+	}
+}
+
+func TestHandlerOverPort(t *testing.T) {
+	master, name := openPTY(t)
+	p, err := Open(name, Config{BaudRate: 1000000})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	// Answer a ping instruction to device ID 1 the way an XM430-W210 would.
+	go func() {
+		ping := make([]byte, 10)
+		if _, err := io.ReadFull(master, ping); err != nil {
+			return
+		}
+		master.Write([]byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x07, 0x00, 0x55, 0x00, 0x06, 0x04, 0x26, 0x65, 0x5D})
+	}()
+
+	h := protocol.NewHandler(p, 100*time.Millisecond)
+	r, err := h.Ping(0x01)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.ID != 0x01 || r.Model != 0x0406 || r.Firmware != 0x26 {
+		t.Errorf("Unexpected ping response %+v", r)
+	}
+}
+
+func TestHandlerWritesDontRead(t *testing.T) {
+	_, name := openPTY(t)
+	// With a minimum and no timeout, a Read with nothing to read never returns.
+	p, err := Open(name, Config{BaudRate: 1000000, MinRead: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	h := protocol.NewHandler(p, 100*time.Millisecond)
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 5; i++ {
+			if err := h.SyncWrite(116, 4, 0x01, 0x00, 0x00, 0x00, 0x00); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Sync writes blocked reading from the port")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !(linux && (386 || amd64 || arm || arm64 || riscv64 || loong64))
+
+package serial
+
+// Port is an open serial port. Serial ports are not yet supported on this platform.
+type Port struct{}
+
+// Open returns ErrUnsupportedPlatform as serial ports are not yet supported on this platform.
+func Open(name string, config Config) (*Port, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (p *Port) Name() string                   { return "" }
+func (p *Port) Read(b []byte) (int, error)     { return 0, ErrUnsupportedPlatform }
+func (p *Port) Write(b []byte) (int, error)    { return 0, ErrUnsupportedPlatform }
+func (p *Port) SetBaudRate(baudRate int) error { return ErrUnsupportedPlatform }
+func (p *Port) BaudRate() (int, error)         { return 0, ErrUnsupportedPlatform }
+func (p *Port) Flush() error                   { return ErrUnsupportedPlatform }
+func (p *Port) Close() error                   { return ErrUnsupportedPlatform }
@@ -0,0 +1,66 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64 || loong64)
+
+package serial
+
+// Terminal control constants from the asm-generic termbits and ioctls headers. These are defined here since the
+// syscall package only defines some of them, and only for some architectures.
+const (
+	tcgets2   = 0x802C542A // _IOR('T', 0x2A, struct termios2)
+	tcsets2   = 0x402C542B // _IOW('T', 0x2B, struct termios2)
+	tcflsh    = 0x540B
+	tcioflush = 2
+	tiocexcl  = 0x540C
+	tiocnxcl  = 0x540D
+
+	// c_iflag
+	ignbrk = 0x1
+	brkint = 0x2
+	parmrk = 0x8
+	inpck  = 0x10
+	istrip = 0x20
+	inlcr  = 0x40
+	igncr  = 0x80
+	icrnl  = 0x100
+	ixon   = 0x400
+	ixany  = 0x800
+	ixoff  = 0x1000
+
+	// c_oflag
+	opost = 0x1
+
+	// c_cflag
+	cbaud   = 0x100F
+	csize   = 0x30
+	cs8     = 0x30
+	cstopb  = 0x40
+	cread   = 0x80
+	parenb  = 0x100
+	clocal  = 0x800
+	bother  = 0x1000
+	crtscts = 0x80000000
+	ibshift = 16
+
+	// c_lflag
+	isig   = 0x1
+	icanon = 0x2
+	echo   = 0x8
+	echonl = 0x40
+	iexten = 0x8000
+
+	// c_cc indexes
+	vtime = 5
+	vmin  = 6
+)
+
+// termios2 mirrors the kernel's struct termios2, which unlike struct termios allows setting arbitrary baud rates
+// with BOTHER.
+type termios2 struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Line   uint8
+	Cc     [19]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
@@ -0,0 +1,284 @@
+// Package servo provides a high level API for controlling a single Dynamixel servo, on top of the protocol handlers
+// (or any other bus.Bus) and the device's control table.
+package servo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/haguro/go-dxl/bus"
+	"github.com/haguro/go-dxl/controltable"
+)
+
+var (
+	ErrTorqueEnabled   = errors.New("torque must be disabled first")
+	ErrUnsupportedMode = errors.New("operating mode not supported by the servo model")
+)
+
+// OperatingMode is the control mode of a servo, as set in the Operating Mode register.
+type OperatingMode byte
+
+const (
+	CurrentMode              OperatingMode = 0  // Control the current (torque) only.
+	VelocityMode             OperatingMode = 1  // Control the velocity only.
+	PositionMode             OperatingMode = 3  // Control the position within a single turn (the default).
+	ExtendedPositionMode     OperatingMode = 4  // Control the position over multiple turns.
+	CurrentBasedPositionMode OperatingMode = 5  // Control the position over multiple turns, with a limited current.
+	PWMMode                  OperatingMode = 16 // Control the PWM output directly (voltage control).
+)
+
+func (m OperatingMode) String() string {
+	switch m {
+	case CurrentMode:
+		return "current"
+	case VelocityMode:
+		return "velocity"
+	case PositionMode:
+		return "position"
+	case ExtendedPositionMode:
+		return "extended position"
+	case CurrentBasedPositionMode:
+		return "current-based position"
+	case PWMMode:
+		return "PWM"
+	}
+	return fmt.Sprintf("OperatingMode(%d)", byte(m))
+}
+
+// goalRegisters holds the names of the registers that each operating mode requires one of, in addition to the ones
+// found in every model. The goal current is named "Goal Torque" in the control table of PRO models.
+var goalRegisters = map[OperatingMode][]string{
+	CurrentMode:              {"Goal Current", "Goal Torque"},
+	VelocityMode:             {"Goal Velocity"},
+	PositionMode:             {"Goal Position"},
+	ExtendedPositionMode:     {"Goal Position"},
+	CurrentBasedPositionMode: {"Goal Current"},
+	PWMMode:                  {"Goal PWM"},
+}
+
+// State holds the present state of a servo, in SI units.
+type State struct {
+	Position     float64 //The present position in radians.
+	Velocity     float64 //The present velocity in rad/s.
+	Current      float64 //The present current in amperes. Zero if the model has no current sensing.
+	Load         float64 //The present load in percent of the maximum. Only set if the model has no current sensing.
+	InputVoltage float64 //The present input voltage in volts.
+	Temperature  float64 //The present internal temperature in degrees Celsius.
+	Moving       bool    //Whether the servo is moving.
+}
+
+// Servo controls a single servo with the given ID on a bus. All values are in SI units and converted to and from the
+// raw register values of the servo's model.
+type Servo struct {
+	bus   bus.Bus
+	id    byte
+	model *controltable.Model
+}
+
+// New pings the servo with the given ID on the given bus and returns a Servo for it. It returns an error wrapping
+// controltable.ErrUnknownModel if the servo's model is not known.
+func New(b bus.Bus, id byte) (*Servo, error) {
+	p, err := b.Ping(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ping servo ID %d: %w", id, err)
+	}
+	m, err := controltable.Lookup(p.Model)
+	if err != nil {
+		return nil, fmt.Errorf("servo ID %d: %w", id, err)
+	}
+	return &Servo{bus: b, id: id, model: m}, nil
+}
+
+// ID returns the ID of the servo.
+func (s *Servo) ID() byte {
+	return s.id
+}
+
+// Model returns the model of the servo, as detected when the Servo was created.
+func (s *Servo) Model() *controltable.Model {
+	return s.model
+}
+
+func (s *Servo) read(name string) (int64, error) {
+	r, err := s.model.Register(name)
+	if err != nil {
+		return 0, err
+	}
+	return controltable.ReadRegister(s.bus, s.id, r)
+}
+
+// write writes the given raw value to the named register. Registers in the EEPROM area can only be written to while
+// torque is disabled.
+func (s *Servo) write(name string, v int64) error {
+	r, err := s.model.Register(name)
+	if err != nil {
+		return err
+	}
+	if r.Area == controltable.EEPROM {
+		enabled, err := s.TorqueEnabled()
+		if err != nil {
+			return err
+		}
+		if enabled {
+			return fmt.Errorf("failed to write %s: %w", r.Name, ErrTorqueEnabled)
+		}
+	}
+	return controltable.WriteRegister(s.bus, s.id, r, v)
+}
+
+func (s *Servo) writeSI(name string, v float64) error {
+	r, err := s.model.Register(name)
+	if err != nil {
+		return err
+	}
+	return controltable.WriteRegisterSI(s.bus, s.id, r, v)
+}
+
+// EnableTorque enables the servo's torque, so that it acts on the goal values. Note that the EEPROM area of the control
+// table (e.g. the operating mode) can't be changed while torque is enabled.
+func (s *Servo) EnableTorque() error {
+	return s.write("Torque Enable", 1)
+}
+
+// DisableTorque disables the servo's torque.
+func (s *Servo) DisableTorque() error {
+	return s.write("Torque Enable", 0)
+}
+
+// TorqueEnabled reports whether the servo's torque is enabled.
+func (s *Servo) TorqueEnabled() (bool, error) {
+	v, err := s.read("Torque Enable")
+	if err != nil {
+		return false, err
+	}
+	return v == 1, nil
+}
+
+// OperatingMode returns the servo's operating mode.
+func (s *Servo) OperatingMode() (OperatingMode, error) {
+	v, err := s.read("Operating Mode")
+	if err != nil {
+		return 0, err
+	}
+	return OperatingMode(v), nil
+}
+
+// SetOperatingMode sets the servo's operating mode. Torque must be disabled first, otherwise ErrTorqueEnabled is
+// returned. It returns ErrUnsupportedMode if the servo's model doesn't support the given mode.
+func (s *Servo) SetOperatingMode(m OperatingMode) error {
+	opMode, err := s.model.Register("Operating Mode")
+	if err != nil {
+		return err
+	}
+	if _, ok := s.goalRegister(m); !ok || int64(m) < opMode.Min || int64(m) > opMode.Max {
+		return fmt.Errorf("%s mode on model %s: %w", m, s.model.Name, ErrUnsupportedMode)
+	}
+	return s.write("Operating Mode", int64(m))
+}
+
+// goalRegister returns the name of the register in the servo's control table that holds the goal of the given
+// operating mode. ok is false if the mode is unknown or the model has none of its goal registers.
+func (s *Servo) goalRegister(m OperatingMode) (name string, ok bool) {
+	for _, name := range goalRegisters[m] {
+		if _, err := s.model.Register(name); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// SetGoalPosition sets the servo's goal position, in radians. It is only acted on in the position operating modes.
+func (s *Servo) SetGoalPosition(position float64) error {
+	return s.writeSI("Goal Position", position)
+}
+
+// SetGoalVelocity sets the servo's goal velocity, in rad/s. It is only acted on in velocity mode.
+func (s *Servo) SetGoalVelocity(velocity float64) error {
+	return s.writeSI("Goal Velocity", velocity)
+}
+
+// SetGoalCurrent sets the servo's goal current, in amperes. It is acted on in current mode and as the current limit in
+// current-based position mode. On PRO models, this sets the Goal Torque register.
+func (s *Servo) SetGoalCurrent(current float64) error {
+	if goal, ok := s.goalRegister(CurrentMode); ok {
+		return s.writeSI(goal, current)
+	}
+	return s.writeSI("Goal Current", current)
+}
+
+// SetGoalPWM sets the servo's goal PWM, in percent of the maximum. It is acted on in PWM mode and as the PWM limit in
+// all other modes.
+func (s *Servo) SetGoalPWM(pwm float64) error {
+	return s.writeSI("Goal PWM", pwm)
+}
+
+// SetProfile sets the maximum velocity (in rad/s) and acceleration (in rad/s^2) of the profile the servo follows to
+// reach its goal position or velocity. A value of zero means no limit. This assumes the servo uses the default
+// velocity-based profile (see the Drive Mode register).
+func (s *Servo) SetProfile(velocity, acceleration float64) error {
+	if err := s.writeSI("Profile Velocity", velocity); err != nil {
+		return err
+	}
+	return s.writeSI("Profile Acceleration", acceleration)
+}
+
+// ReadState reads the present state of the servo in a single read instruction.
+func (s *Servo) ReadState() (State, error) {
+	names := []string{"Moving", "Present Position", "Present Velocity", "Present Input Voltage", "Present Temperature"}
+	current := "Present Current"
+	if _, err := s.model.Register(current); err != nil {
+		current = "Present Load"
+	}
+	names = append(names, current)
+
+	regs := make([]controltable.Register, len(names))
+	start, end := ^uint16(0), uint16(0)
+	for i, name := range names {
+		r, err := s.model.Register(name)
+		if err != nil {
+			return State{}, err
+		}
+		regs[i] = r
+		if r.Addr < start {
+			start = r.Addr
+		}
+		if r.Addr+r.Size > end {
+			end = r.Addr + r.Size
+		}
+	}
+
+	data, err := s.bus.Read(s.id, start, end-start)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	values := make([]float64, len(regs))
+	for i, r := range regs {
+		raw, err := r.Decode(data[r.Addr-start : r.Addr-start+r.Size])
+		if err != nil {
+			return State{}, err
+		}
+		if i == 0 {
+			values[i] = float64(raw)
+			continue
+		}
+		values[i], err = r.ToSI(raw)
+		if err != nil {
+			return State{}, err
+		}
+	}
+
+	st := State{
+		Moving:       values[0] == 1,
+		Position:     values[1],
+		Velocity:     values[2],
+		InputVoltage: values[3],
+		Temperature:  values[4],
+	}
+	if current == "Present Current" {
+		st.Current = values[5]
+	} else {
+		st.Load = values[5]
+	}
+	return st, nil
+}
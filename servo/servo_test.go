@@ -0,0 +1,315 @@
+package servo
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/haguro/go-dxl/bus"
+	"github.com/haguro/go-dxl/controltable"
+)
+
+var errMockBus = errors.New("mock bus error")
+
+// mockBus is a bus.Bus holding the control table of a single device in memory.
+type mockBus struct {
+	id    byte
+	table [1024]byte
+	err   error
+}
+
+func newMockBus(id byte, model uint16) *mockBus {
+	b := &mockBus{id: id}
+	binary.LittleEndian.PutUint16(b.table[0:], model)
+	return b
+}
+
+func (b *mockBus) Ping(id byte) (bus.PingResponse, error) {
+	if id != b.id {
+		return bus.PingResponse{}, errMockBus
+	}
+	return bus.PingResponse{ID: id, Model: binary.LittleEndian.Uint16(b.table[0:]), Firmware: b.table[6]}, nil
+}
+
+func (b *mockBus) Read(id byte, addr, length uint16) ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if id != b.id {
+		return nil, errMockBus
+	}
+	return append([]byte{}, b.table[addr:addr+length]...), nil
+}
+
+func (b *mockBus) Write(id byte, addr uint16, data ...byte) error {
+	if b.err != nil {
+		return b.err
+	}
+	if id != b.id {
+		return errMockBus
+	}
+	copy(b.table[addr:], data)
+	return nil
+}
+
+func (b *mockBus) SyncRead(ids []byte, addr, length uint16) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+
+func (b *mockBus) SyncWrite(addr, length uint16, data ...byte) error {
+	return errMockBus
+}
+
+func (b *mockBus) BulkRead(data []bus.BulkReadDescriptor) ([]bus.ReadResult, error) {
+	return nil, errMockBus
+}
+
+func (b *mockBus) BulkWrite(data []bus.BulkWriteDescriptor) error {
+	return errMockBus
+}
+
+func TestNew(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		model    uint16
+		id       byte
+		expErr   error
+		expModel string
+	}{
+		{
+			name:     "Known model",
+			model:    1020,
+			id:       0x01,
+			expModel: "XM430-W350",
+		},
+		{
+			name:   "Unknown model",
+			model:  12,
+			id:     0x01,
+			expErr: controltable.ErrUnknownModel,
+		},
+		{
+			name:   "No response",
+			model:  1020,
+			id:     0x02,
+			expErr: errMockBus,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := New(newMockBus(0x01, tc.model), tc.id)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if s.Model().Name != tc.expModel {
+				t.Errorf("Expected model %s, got %s", tc.expModel, s.Model().Name)
+			}
+			if s.ID() != tc.id {
+				t.Errorf("Expected ID %d, got %d", tc.id, s.ID())
+			}
+		})
+	}
+}
+
+func TestSetOperatingMode(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		model         uint16
+		mode          OperatingMode
+		torqueEnabled bool
+		expErr        error
+	}{
+		{
+			name:  "Position mode",
+			model: 1020,
+			mode:  PositionMode,
+		},
+		{
+			name:  "Current-based position mode",
+			model: 1020,
+			mode:  CurrentBasedPositionMode,
+		},
+		{
+			name:          "Torque enabled",
+			model:         1020,
+			mode:          VelocityMode,
+			torqueEnabled: true,
+			expErr:        ErrTorqueEnabled,
+		},
+		{
+			name:   "Current mode on model without current control",
+			model:  1060,
+			mode:   CurrentMode,
+			expErr: ErrUnsupportedMode,
+		},
+		{
+			name:  "Torque mode on PRO model",
+			model: 54024,
+			mode:  CurrentMode,
+		},
+		{
+			name:   "PWM mode on PRO model",
+			model:  54024,
+			mode:   PWMMode,
+			expErr: ErrUnsupportedMode,
+		},
+		{
+			name:   "Unknown mode",
+			model:  1020,
+			mode:   OperatingMode(2),
+			expErr: ErrUnsupportedMode,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newMockBus(0x01, tc.model)
+			s, err := New(b, 0x01)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tc.torqueEnabled {
+				if err := s.EnableTorque(); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			err = s.SetOperatingMode(tc.mode)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error of %q but got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got, err := s.OperatingMode()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.mode {
+				t.Errorf("Expected %s mode, got %s mode", tc.mode, got)
+			}
+		})
+	}
+}
+
+func TestGoalsAndProfile(t *testing.T) {
+	b := newMockBus(0x01, 1020)
+	s, err := New(b, 0x01)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.EnableTorque(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.SetGoalPosition(math.Pi); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if got := int32(binary.LittleEndian.Uint32(b.table[116:])); got != 2048 {
+		t.Errorf("Expected goal position 2048, got %d", got)
+	}
+	if err := s.SetGoalCurrent(-0.269); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if got := int16(binary.LittleEndian.Uint16(b.table[102:])); got != -100 {
+		t.Errorf("Expected goal current -100, got %d", got)
+	}
+	if err := s.SetGoalPWM(150); !errors.Is(err, controltable.ErrOutOfRange) {
+		t.Errorf("Expected error of %q but got %q", controltable.ErrOutOfRange, err)
+	}
+	if err := s.SetProfile(0.229*2*math.Pi/60*10, 0); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if got := binary.LittleEndian.Uint32(b.table[112:]); got != 10 {
+		t.Errorf("Expected profile velocity 10, got %d", got)
+	}
+}
+
+func TestGoalTorquePRO(t *testing.T) {
+	b := newMockBus(0x01, 54024)
+	s, err := New(b, 0x01)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.SetGoalCurrent(-1.611328); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := int16(binary.LittleEndian.Uint16(b.table[604:])); got != -100 {
+		t.Errorf("Expected goal torque -100, got %d", got)
+	}
+}
+
+func TestReadState(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		model    uint16
+		expState State
+	}{
+		{
+			name:  "Model with current sensing",
+			model: 1020,
+			expState: State{Position: math.Pi / 2, Velocity: -0.229 * 2 * math.Pi / 60 * 5, Current: 0.269,
+				InputVoltage: 12, Temperature: 40, Moving: true},
+		},
+		{
+			name:  "Model without current sensing",
+			model: 1060,
+			expState: State{Position: math.Pi / 2, Velocity: -0.229 * 2 * math.Pi / 60 * 5, Load: 10,
+				InputVoltage: 12, Temperature: 40, Moving: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newMockBus(0x01, tc.model)
+			b.table[122] = 1
+			binary.LittleEndian.PutUint16(b.table[126:], 100)
+			binary.LittleEndian.PutUint32(b.table[128:], uint32(0xFFFFFFFB))
+			binary.LittleEndian.PutUint32(b.table[132:], 1024)
+			binary.LittleEndian.PutUint16(b.table[144:], 120)
+			b.table[146] = 40
+			s, err := New(b, 0x01)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got, err := s.ReadState()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got.Moving != tc.expState.Moving {
+				t.Errorf("Expected moving to be %t, got %t", tc.expState.Moving, got.Moving)
+			}
+			for _, v := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"position", got.Position, tc.expState.Position},
+				{"velocity", got.Velocity, tc.expState.Velocity},
+				{"current", got.Current, tc.expState.Current},
+				{"load", got.Load, tc.expState.Load},
+				{"input voltage", got.InputVoltage, tc.expState.InputVoltage},
+				{"temperature", got.Temperature, tc.expState.Temperature},
+			} {
+				if math.Abs(v.got-v.want) > 1e-9 {
+					t.Errorf("Expected %s to be %v, got %v", v.name, v.want, v.got)
+				}
+			}
+		})
+	}
+
+	b := newMockBus(0x01, 1020)
+	s, err := New(b, 0x01)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b.err = errMockBus
+	if _, err := s.ReadState(); !errors.Is(err, errMockBus) {
+		t.Errorf("Expected error of %q but got %q", errMockBus, err)
+	}
+}
@@ -0,0 +1,310 @@
+// Package sim simulates Dynamixel devices communicating using the Dynamixel Protocol 2.0, so that code using the
+// protocol handler (and the packages built on it) can be run and tested without any hardware.
+// A Bus of simulated servos implements io.ReadWriter and can be passed directly to the Protocol 2.0 handler, which
+// writes instruction packets to it and reads the servos' status packets from it.
+// See https://emanual.robotis.com/docs/en/dxl/protocol2/ for the details of the protocol.
+package sim
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BroadcastID is the device ID used to send instructions to all devices.
+const BroadcastID byte = 0xFE
+
+// maxID is the largest ID a device can have.
+const maxID = 252
+
+var (
+	ErrInvalidID           = errors.New("invalid device ID")
+	ErrUnsupportedPlatform = errors.New("pseudo-terminals are not supported on this platform")
+)
+
+// Bus is a simulated bus of servos. Instruction packets written to the bus are received by every servo, and the
+// status packets they return are read back in the order they were sent, as on a half-duplex serial bus. A read
+// returns io.EOF when there is nothing to read, as a serial port does.
+// More than one client can be connected to the same bus, each through a Port of its own (see NewPort).
+// A Bus is safe for concurrent use by multiple goroutines.
+type Bus struct {
+	mu       sync.Mutex
+	servos   []*Servo
+	port     *Port      // The port used by the bus's own Read and Write methods.
+	statuses []response // The status packets returned for the instruction being processed.
+	command  byte       // The instruction being processed.
+	faults   []*fault
+	last     time.Time
+}
+
+// response is a status packet returned by one or more devices.
+type response struct {
+	ids    []byte //The IDs of the devices the status packet is from.
+	servo  *Servo //The servo the status packet is from, nil if it's from more than one.
+	packet []byte
+}
+
+// NewBus creates a bus with the given servos attached.
+func NewBus(servos ...*Servo) *Bus {
+	b := &Bus{servos: servos}
+	b.port = &Port{bus: b}
+	return b
+}
+
+// Attach attaches the given servos to the bus.
+func (b *Bus) Attach(servos ...*Servo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.servos = append(b.servos, servos...)
+}
+
+// Servo returns the (first) servo on the bus with the given ID, or nil if there is none.
+func (b *Bus) Servo(id byte) *Servo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.servos {
+		if s.ID() == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Step advances the simulation of all the servos on the bus by the given duration (see Servo.Step).
+func (b *Bus) Step(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.servos {
+		s.Step(d)
+	}
+}
+
+// SetRealTime sets whether the simulation of the servos on the bus follows the wall clock. If it does, the servos are
+// stepped by the time elapsed since they were last stepped that way whenever an instruction is written to the bus, so
+// that they appear to move in real time to whoever is talking to them. Otherwise, the servos only move when stepped.
+func (b *Bus) SetRealTime(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.last = time.Time{}
+	if enabled {
+		b.last = time.Now()
+	}
+}
+
+// Read reads the status packets returned by the servos for the instructions written with Write.
+func (b *Bus) Read(p []byte) (int, error) {
+	return b.port.Read(p)
+}
+
+// Write sends the given bytes to the servos. Each complete instruction packet is processed as soon as it is written,
+// and any status packets returned are available to Read right away.
+func (b *Bus) Write(p []byte) (int, error) {
+	return b.port.Write(p)
+}
+
+// targets returns the servos that the given ID refers to.
+func (b *Bus) targets(id byte) []*Servo {
+	var ss []*Servo
+	for _, s := range b.servos {
+		if id == BroadcastID || s.ID() == id {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// respond queues the status packet of the given servo, if its status return level is at least the given level.
+func (b *Bus) respond(s *Servo, id, level, errByte byte, params []byte) {
+	if s.statusLevel() < int64(level) {
+		return
+	}
+	if errByte != 0 {
+		params = nil
+	}
+	b.statuses = append(b.statuses, response{[]byte{id}, s, statusPacket(id, errByte|s.alert(), params)})
+}
+
+// process handles a single instruction packet.
+func (b *Bus) process(packet []byte) {
+	id, command, params, ok, crcOK := parseInstructionPacket(packet)
+	if !ok {
+		return
+	}
+	if !crcOK {
+		if id != BroadcastID {
+			for _, s := range b.targets(id) {
+				b.respond(s, id, 2, errCRC, nil)
+			}
+		}
+		return
+	}
+
+	b.command = command
+	switch command {
+	case ping:
+		// Devices respond to a broadcast ping in order of ID.
+		ss := b.targets(id)
+		sort.SliceStable(ss, func(i, j int) bool { return ss[i].ID() < ss[j].ID() })
+		for _, s := range ss {
+			b.respond(s, s.ID(), 0, 0, s.ping())
+		}
+	case read:
+		if id == BroadcastID {
+			return
+		}
+		for _, s := range b.targets(id) {
+			if len(params) != 4 {
+				b.respond(s, id, 1, errDataLength, nil)
+				continue
+			}
+			data, errByte := s.read(u16(params[0:]), u16(params[2:]))
+			b.respond(s, id, 1, errByte, data)
+		}
+	case write, regWrite:
+		for _, s := range b.targets(id) {
+			if len(params) < 3 {
+				b.unicast(s, id, errDataLength)
+				continue
+			}
+			if command == write {
+				b.unicast(s, id, s.write(u16(params), params[2:]))
+			} else {
+				b.unicast(s, id, s.regWrite(u16(params), params[2:]))
+			}
+		}
+	case action:
+		for _, s := range b.targets(id) {
+			b.unicast(s, id, s.action())
+		}
+	case reset:
+		// A factory reset of everything, ID included, is ignored when sent to all devices, so that they don't all end
+		// up with the same ID.
+		if len(params) == 1 && params[0] == 0xFF && id == BroadcastID {
+			return
+		}
+		for _, s := range b.targets(id) {
+			if len(params) != 1 {
+				b.unicast(s, id, errDataLength)
+				continue
+			}
+			b.unicast(s, id, s.factoryReset(params[0]))
+		}
+	case reboot:
+		// The status is returned before the device reboots.
+		for _, s := range b.targets(id) {
+			b.unicast(s, id, 0)
+			s.reboot()
+		}
+	case clear:
+		for _, s := range b.targets(id) {
+			b.unicast(s, id, s.clear(params))
+		}
+	case backup:
+		for _, s := range b.targets(id) {
+			b.unicast(s, id, s.controlTableBackup(params))
+		}
+	case syncRead, fastSyncRead:
+		if id != BroadcastID || len(params) < 5 {
+			return
+		}
+		addr, length := u16(params), u16(params[2:])
+		var reads []bulkReadParams
+		for _, id := range params[4:] {
+			reads = append(reads, bulkReadParams{id, addr, length})
+		}
+		b.bulkRead(reads, command == fastSyncRead)
+	case bulkRead, fastBulkRead:
+		if id != BroadcastID || len(params) == 0 || len(params)%5 != 0 {
+			return
+		}
+		var reads []bulkReadParams
+		for i := 0; i < len(params); i += 5 {
+			reads = append(reads, bulkReadParams{params[i], u16(params[i+1:]), u16(params[i+3:])})
+		}
+		b.bulkRead(reads, command == fastBulkRead)
+	case syncWrite:
+		if id != BroadcastID || len(params) < 4 {
+			return
+		}
+		addr, length := u16(params), int(u16(params[2:]))
+		for i := 4; i+1+length <= len(params); i += 1 + length {
+			for _, s := range b.targets(params[i]) {
+				s.write(addr, params[i+1:i+1+length])
+			}
+		}
+	case bulkWrite:
+		if id != BroadcastID {
+			return
+		}
+		for i := 0; i+5 <= len(params); {
+			addr, length := u16(params[i+1:]), int(u16(params[i+3:]))
+			if i+5+length > len(params) {
+				break
+			}
+			for _, s := range b.targets(params[i]) {
+				s.write(addr, params[i+5:i+5+length])
+			}
+			i += 5 + length
+		}
+	default:
+		for _, s := range b.targets(id) {
+			b.unicast(s, id, errInstruction)
+		}
+	}
+}
+
+// unicast queues the status packet of an instruction that is only responded to when it isn't sent to all devices.
+func (b *Bus) unicast(s *Servo, id, errByte byte) {
+	if id != BroadcastID {
+		b.respond(s, id, 2, errByte, nil)
+	}
+}
+
+// bulkReadParams holds what to read from a single device as part of a multi-device read instruction.
+type bulkReadParams struct {
+	id     byte
+	addr   uint16
+	length uint16
+}
+
+// bulkRead queues the status packets of the given reads, in the order they were given. If fast is true, the status
+// packets are merged into one, which is only sent if every device was found.
+func (b *Bus) bulkRead(reads []bulkReadParams, fast bool) {
+	var segments []segment
+	var ids []byte
+	for _, r := range reads {
+		var ss []*Servo
+		for _, s := range b.targets(r.id) {
+			if s.statusLevel() >= 1 {
+				ss = append(ss, s)
+			}
+		}
+		if len(ss) == 0 {
+			// The devices that follow wait for this one to respond, and time out.
+			break
+		}
+		if !fast {
+			for _, s := range ss {
+				data, errByte := s.read(r.addr, r.length)
+				b.respond(s, r.id, 1, errByte, data)
+			}
+			continue
+		}
+		s := ss[0]
+		data, errByte := s.read(r.addr, r.length)
+		if data == nil {
+			data = make([]byte, r.length)
+		}
+		segments = append(segments, segment{id: r.id, errByte: errByte | s.alert(), data: data})
+		ids = append(ids, r.id)
+	}
+	if fast && len(segments) == len(reads) && len(segments) > 0 {
+		b.statuses = append(b.statuses, response{ids, nil, fastStatusPacket(segments)})
+	}
+}
+
+// u16 returns the little-endian uint16 at the start of b.
+func u16(b []byte) uint16 {
+	return uint16(b[0]) + uint16(b[1])<<8
+}
@@ -0,0 +1,498 @@
+package sim
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/haguro/go-dxl/bus"
+	"github.com/haguro/go-dxl/controltable"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+	"github.com/haguro/go-dxl/servo"
+)
+
+// newTestBus returns a handler talking to a bus of servos of the given model numbers, with IDs starting at 1.
+func newTestBus(t *testing.T, models ...uint16) (*protocol2.Handler, *Bus) {
+	t.Helper()
+	b := NewBus()
+	for i, m := range models {
+		s, err := NewServo(m, byte(i+1))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		b.Attach(s)
+	}
+	return protocol2.NewHandler(b, 0), b
+}
+
+func TestPing(t *testing.T) {
+	h, _ := newTestBus(t, 1020, 1200)
+
+	var testCases = []struct {
+		name        string
+		id          byte
+		expectModel uint16
+		expectErr   error
+	}{
+		{name: "XM430-W350", id: 1, expectModel: 1020},
+		{name: "XL330-M288", id: 2, expectModel: 1200},
+		{name: "No device", id: 3, expectErr: protocol2.ErrReadTimeout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := h.Ping(tc.id)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error of %q but got %q", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if r.ID != tc.id || r.Model != tc.expectModel || r.Firmware != 52 {
+				t.Errorf("Unexpected ping response %+v", r)
+			}
+		})
+	}
+
+	t.Run("Broadcast", func(t *testing.T) {
+		rs, err := h.BroadcastPing()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expect := []protocol2.PingResponse{{ID: 1, Model: 1020, Firmware: 52}, {ID: 2, Model: 1200, Firmware: 52}}
+		if !reflect.DeepEqual(rs, expect) {
+			t.Errorf("Expected responses %+v but got %+v", expect, rs)
+		}
+	})
+}
+
+func TestReadWrite(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		setup     func(s *Servo)
+		addr      uint16
+		data      []byte
+		expectErr error
+	}{
+		{
+			name: "Goal Position",
+			addr: 116,
+			data: []byte{0x00, 0x08, 0x00, 0x00},
+		},
+		{
+			name: "Several registers at once",
+			addr: 108,
+			data: []byte{100, 0, 0, 0, 200, 0, 0, 0, 0xFF, 0x07, 0, 0},
+		},
+		{
+			name:      "Read-only register",
+			addr:      132,
+			data:      []byte{0, 0, 0, 0},
+			expectErr: protocol2.ErrAccessError,
+		},
+		{
+			name:      "EEPROM register with torque enabled",
+			setup:     func(s *Servo) { s.SetValue("Torque Enable", 1) },
+			addr:      11,
+			data:      []byte{1},
+			expectErr: protocol2.ErrAccessError,
+		},
+		{
+			name: "EEPROM register with torque disabled",
+			addr: 11,
+			data: []byte{1},
+		},
+		{
+			name:      "Part of a register",
+			addr:      116,
+			data:      []byte{0, 0},
+			expectErr: protocol2.ErrDataLengthError,
+		},
+		{
+			name:      "Out of range",
+			addr:      64,
+			data:      []byte{2},
+			expectErr: protocol2.ErrDataRangeError,
+		},
+		{
+			name:      "Beyond the control table",
+			addr:      146,
+			data:      []byte{0, 0, 0, 0},
+			expectErr: protocol2.ErrDataRangeError,
+		},
+		{
+			name:      "Goal Position beyond the position limits",
+			addr:      116,
+			data:      []byte{0x00, 0x10, 0x00, 0x00},
+			expectErr: protocol2.ErrDataLimitError,
+		},
+		{
+			name:  "Goal Position beyond the position limits in extended position mode",
+			setup: func(s *Servo) { s.SetValue("Operating Mode", 4) },
+			addr:  116,
+			data:  []byte{0x00, 0x10, 0x00, 0x00},
+		},
+		{
+			name:      "Goal Velocity beyond the velocity limit",
+			setup:     func(s *Servo) { s.SetValue("Velocity Limit", 100) },
+			addr:      104,
+			data:      []byte{0x38, 0xFF, 0xFF, 0xFF},
+			expectErr: protocol2.ErrDataLimitError,
+		},
+		{
+			name:      "Hardware error",
+			setup:     func(s *Servo) { s.SetValue("Hardware Error Status", 0x04) },
+			addr:      116,
+			data:      []byte{0x00, 0x08, 0x00, 0x00},
+			expectErr: protocol2.ErrDeviceError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, b := newTestBus(t, 1020)
+			before, _ := b.Servo(1).read(tc.addr, uint16(len(tc.data)))
+			if tc.setup != nil {
+				tc.setup(b.Servo(1))
+			}
+
+			err := h.Write(1, tc.addr, tc.data...)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error of %q but got %q", tc.expectErr, err)
+			}
+
+			got, err := h.Read(1, tc.addr, uint16(len(tc.data)))
+			if errors.Is(tc.expectErr, protocol2.ErrDataRangeError) && err != nil {
+				return
+			}
+			if errors.Is(tc.expectErr, protocol2.ErrDeviceError) {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expect := tc.data
+			if tc.expectErr != nil {
+				expect = before
+			}
+			if !reflect.DeepEqual(got, expect) {
+				t.Errorf("Expected %v to be read back but got %v", expect, got)
+			}
+		})
+	}
+}
+
+func TestRegWriteAndAction(t *testing.T) {
+	h, b := newTestBus(t, 1020, 1020)
+
+	for _, id := range []byte{1, 2} {
+		if err := h.RegWrite(id, 116, 0x00, 0x04, 0x00, 0x00); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	for _, id := range []byte{1, 2} {
+		if v, _ := b.Servo(id).Value("Goal Position"); v != 0 {
+			t.Errorf("Expected Goal Position of device ID %d to be unchanged before action but got %d", id, v)
+		}
+		if v, _ := b.Servo(id).Value("Registered Instruction"); v != 1 {
+			t.Errorf("Expected Registered Instruction of device ID %d to be 1 but got %d", id, v)
+		}
+	}
+
+	if err := h.Action(protocol2.BroadcastID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, id := range []byte{1, 2} {
+		if v, _ := b.Servo(id).Value("Goal Position"); v != 1024 {
+			t.Errorf("Expected Goal Position of device ID %d to be 1024 after action but got %d", id, v)
+		}
+		if v, _ := b.Servo(id).Value("Registered Instruction"); v != 0 {
+			t.Errorf("Expected Registered Instruction of device ID %d to be 0 but got %d", id, v)
+		}
+	}
+
+	if err := h.Action(1); !errors.Is(err, protocol2.ErrInstructionError) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrInstructionError, err)
+	}
+	if err := h.RegWrite(1, 132, 0, 0, 0, 0); !errors.Is(err, protocol2.ErrAccessError) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrAccessError, err)
+	}
+}
+
+func TestFactoryReset(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		id             byte
+		option         byte
+		expectID       int64
+		expectBaudRate int64
+	}{
+		{name: "Reset all", id: 5, option: protocol2.ResetAll, expectID: 1, expectBaudRate: 1},
+		{name: "Reset all except ID", id: 5, option: protocol2.ResetAllExceptID, expectID: 5, expectBaudRate: 1},
+		{
+			name:           "Reset all except ID and baud rate",
+			id:             5,
+			option:         protocol2.ResetAllExceptIDAndBaud,
+			expectID:       5,
+			expectBaudRate: 3,
+		},
+		{name: "Reset all by broadcast is ignored", id: protocol2.BroadcastID, option: protocol2.ResetAll,
+			expectID: 5, expectBaudRate: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := NewServo(1020, 5)
+			h := protocol2.NewHandler(NewBus(s), 0)
+			if err := h.Write(5, 8, 3); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := h.Write(5, 64, 1); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if err := h.FactoryReset(tc.id, tc.option); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if v, _ := s.Value("ID"); v != tc.expectID {
+				t.Errorf("Expected ID %d but got %d", tc.expectID, v)
+			}
+			if v, _ := s.Value("Baud Rate"); v != tc.expectBaudRate {
+				t.Errorf("Expected Baud Rate %d but got %d", tc.expectBaudRate, v)
+			}
+		})
+	}
+}
+
+func TestReboot(t *testing.T) {
+	h, b := newTestBus(t, 1020)
+	s := b.Servo(1)
+	s.SetValue("Present Position", 3000)
+	if err := h.Write(1, 11, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := h.Write(1, 64, 1, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := h.Reboot(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, expect := range map[string]int64{
+		"Operating Mode":   1,
+		"Torque Enable":    0,
+		"LED":              0,
+		"Present Position": 3000,
+		"Goal Position":    3000,
+	} {
+		if v, _ := s.Value(name); v != expect {
+			t.Errorf("Expected %s to be %d after reboot but got %d", name, expect, v)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	h, b := newTestBus(t, 1020)
+	s := b.Servo(1)
+	s.SetValue("Present Position", -5000)
+
+	if err := h.Clear(1, protocol2.ClearMultiRotationPos); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v, _ := s.Value("Present Position"); v != 3192 {
+		t.Errorf("Expected Present Position 3192 but got %d", v)
+	}
+
+	s.SetValue("Moving", 1)
+	if err := h.Clear(1, protocol2.ClearMultiRotationPos); !errors.Is(err, protocol2.ErrResultError) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrResultError, err)
+	}
+	if err := h.Clear(1, 0x02); !errors.Is(err, protocol2.ErrDataRangeError) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrDataRangeError, err)
+	}
+}
+
+func TestControlTableBackup(t *testing.T) {
+	h, b := newTestBus(t, 1020)
+	s := b.Servo(1)
+
+	if err := h.ControlTableBackup(1, protocol2.BackupRestore); !errors.Is(err, protocol2.ErrResultError) {
+		t.Fatalf("Expected error of %q but got %q", protocol2.ErrResultError, err)
+	}
+	if err := h.Write(1, 11, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := h.ControlTableBackup(1, protocol2.BackupStore); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v, _ := s.Value("Backup Ready"); v != 1 {
+		t.Errorf("Expected Backup Ready to be 1 but got %d", v)
+	}
+	if err := h.Write(1, 11, 16); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := h.ControlTableBackup(1, protocol2.BackupRestore); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v, _ := s.Value("Operating Mode"); v != 1 {
+		t.Errorf("Expected Operating Mode to be restored to 1 but got %d", v)
+	}
+
+	if err := h.Write(1, 64, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := h.ControlTableBackup(1, protocol2.BackupStore); !errors.Is(err, protocol2.ErrAccessError) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrAccessError, err)
+	}
+}
+
+func TestMultiDeviceInstructions(t *testing.T) {
+	h, b := newTestBus(t, 1020, 1200, 30)
+	// 0x00FDFFFF is sent as FF FF FD 00, which has to be byte stuffed.
+	positions := []int64{0x00FDFFFF, -200, 300}
+	for i, p := range positions {
+		b.Servo(byte(i+1)).SetValue("Present Position", p)
+	}
+	positionData := [][]byte{{0xFF, 0xFF, 0xFD, 0x00}, {0x38, 0xFF, 0xFF, 0xFF}, {0x2C, 0x01, 0x00, 0x00}}
+
+	t.Run("SyncRead", func(t *testing.T) {
+		rs, err := h.SyncRead([]byte{1, 2, 3, 4}, 132, 4)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for i, r := range rs[:3] {
+			if r.Err != nil || !reflect.DeepEqual(r.Data, positionData[i]) {
+				t.Errorf("Unexpected result for device ID %d: %+v", r.ID, r)
+			}
+		}
+		if !errors.Is(rs[3].Err, protocol2.ErrReadTimeout) {
+			t.Errorf("Expected error of %q but got %q", protocol2.ErrReadTimeout, rs[3].Err)
+		}
+	})
+
+	t.Run("FastSyncRead", func(t *testing.T) {
+		rs, err := h.FastSyncRead([]byte{1, 2, 3}, 132, 4)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(rs, positionData) {
+			t.Errorf("Expected %v but got %v", positionData, rs)
+		}
+	})
+
+	t.Run("SyncWrite", func(t *testing.T) {
+		if err := h.SyncWrite(65, 1, 1, 1, 2, 1, 3, 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for id := byte(1); id <= 3; id++ {
+			if v, _ := b.Servo(id).Value("LED"); v != 1 {
+				t.Errorf("Expected LED of device ID %d to be 1 but got %d", id, v)
+			}
+		}
+	})
+
+	bulk := []bus.BulkReadDescriptor{{ID: 3, Addr: 132, Length: 4}, {ID: 1, Addr: 0, Length: 2}, {ID: 2, Addr: 65, Length: 1}}
+	expect := [][]byte{positionData[2], {0xFC, 0x03}, {1}}
+
+	t.Run("BulkRead", func(t *testing.T) {
+		rs, err := h.BulkRead(bulk)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for i, r := range rs {
+			if r.Err != nil || r.ID != bulk[i].ID || !reflect.DeepEqual(r.Data, expect[i]) {
+				t.Errorf("Unexpected result %+v, expected data %v", r, expect[i])
+			}
+		}
+	})
+
+	t.Run("FastBulkRead", func(t *testing.T) {
+		rs, err := h.FastBulkRead(bulk)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(rs, expect) {
+			t.Errorf("Expected %v but got %v", expect, rs)
+		}
+	})
+
+	t.Run("BulkWrite", func(t *testing.T) {
+		err := h.BulkWrite([]bus.BulkWriteDescriptor{
+			{ID: 1, Addr: 116, Data: []byte{0x00, 0x02, 0x00, 0x00}},
+			{ID: 2, Addr: 65, Data: []byte{0}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if v, _ := b.Servo(1).Value("Goal Position"); v != 512 {
+			t.Errorf("Expected Goal Position 512 but got %d", v)
+		}
+		if v, _ := b.Servo(2).Value("LED"); v != 0 {
+			t.Errorf("Expected LED 0 but got %d", v)
+		}
+	})
+}
+
+func TestStatusReturnLevel(t *testing.T) {
+	h, _ := newTestBus(t, 1020)
+	// The status return level applies as soon as it is written.
+	if err := h.Write(1, 68, 1); !errors.Is(err, protocol2.ErrReadTimeout) {
+		t.Fatalf("Expected error of %q but got %q", protocol2.ErrReadTimeout, err)
+	}
+	if err := h.Write(1, 65, 1); !errors.Is(err, protocol2.ErrReadTimeout) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrReadTimeout, err)
+	}
+	if _, err := h.Read(1, 65, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := h.Write(1, 68, 0); !errors.Is(err, protocol2.ErrReadTimeout) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrReadTimeout, err)
+	}
+	if _, err := h.Read(1, 65, 1); !errors.Is(err, protocol2.ErrReadTimeout) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrReadTimeout, err)
+	}
+	if _, err := h.Ping(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCorruptedInstruction(t *testing.T) {
+	b := NewBus()
+	s, _ := NewServo(1020, 1)
+	b.Attach(s)
+
+	// A ping to ID 1 with a bad CRC, split across writes and preceded by noise.
+	b.Write([]byte{0x00, 0xFF, 0xFF, 0xFD})
+	b.Write([]byte{0x00, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00})
+
+	got := make([]byte, 64)
+	n, err := b.Read(got)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expect := statusPacket(1, errCRC, nil)
+	if !reflect.DeepEqual(got[:n], expect) {
+		t.Errorf("Expected status packet %v but got %v", expect, got[:n])
+	}
+}
+
+func TestWithServo(t *testing.T) {
+	h, _ := newTestBus(t, 1020)
+	sv, err := servo.New(h, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := sv.SetOperatingMode(servo.VelocityMode); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := sv.EnableTorque(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := sv.SetOperatingMode(servo.PositionMode); !errors.Is(err, servo.ErrTorqueEnabled) {
+		t.Errorf("Expected error of %q but got %q", servo.ErrTorqueEnabled, err)
+	}
+	r, _ := sv.Model().Register("Goal Velocity")
+	if err := controltable.WriteRegister(h, 1, r, 100); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DeviceConfig describes a simulated servo in a device list (see ReadDevices).
+type DeviceConfig struct {
+	Model  uint16           `json:"model"`            //The model number of the servo.
+	ID     byte             `json:"id"`               //The ID of the servo.
+	Values map[string]int64 `json:"values,omitempty"` //Initial register values, by register name.
+	// Motor overrides fields of DefaultMotor for the servo, by field name. ThermalTimeConstant is given in nanoseconds.
+	Motor json.RawMessage `json:"motor,omitempty"`
+}
+
+// ReadDevices reads a JSON list of devices (see DeviceConfig) from r and creates the servos it describes, e.g.
+//
+//	[
+//		{"model": 1020, "id": 1},
+//		{"model": 1020, "id": 2, "values": {"Operating Mode": 1}, "motor": {"Inertia": 0.0001}}
+//	]
+func ReadDevices(r io.Reader) ([]*Servo, error) {
+	var configs []DeviceConfig
+	if err := json.NewDecoder(r).Decode(&configs); err != nil {
+		return nil, fmt.Errorf("failed to read device list: %w", err)
+	}
+	servos := make([]*Servo, 0, len(configs))
+	for i, c := range configs {
+		s, err := c.Servo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create device %d of the device list: %w", i, err)
+		}
+		servos = append(servos, s)
+	}
+	return servos, nil
+}
+
+// Servo creates the servo described by the config.
+func (c DeviceConfig) Servo() (*Servo, error) {
+	s, err := NewServo(c.Model, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	for name, v := range c.Values {
+		if err := s.SetValue(name, v); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	if len(c.Motor) > 0 {
+		m := DefaultMotor
+		if err := json.Unmarshal(c.Motor, &m); err != nil {
+			return nil, fmt.Errorf("failed to read motor: %w", err)
+		}
+		s.SetMotor(m)
+	}
+	return s, nil
+}
@@ -0,0 +1,56 @@
+package sim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadDevices(t *testing.T) {
+	servos, err := ReadDevices(strings.NewReader(`[
+		{"model": 1020, "id": 1},
+		{"model": 1200, "id": 7, "values": {"Operating Mode": 1}, "motor": {"GearRatio": 100, "ThermalTimeConstant": 1000000000}}
+	]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(servos) != 2 {
+		t.Fatalf("Expected 2 servos but got %d", len(servos))
+	}
+	if servos[0].ID() != 1 || servos[0].Model().Number != 1020 || servos[1].ID() != 7 || servos[1].Model().Number != 1200 {
+		t.Errorf("Unexpected servos: %d (%d), %d (%d)", servos[0].ID(), servos[0].Model().Number, servos[1].ID(),
+			servos[1].Model().Number)
+	}
+	if v, _ := servos[1].Value("Operating Mode"); v != 1 {
+		t.Errorf("Expected Operating Mode of 1 but got %d", v)
+	}
+	m := servos[1].Motor()
+	if m.GearRatio != 100 || m.ThermalTimeConstant != time.Second || m.MaxTorque != DefaultMotor.MaxTorque {
+		t.Errorf("Unexpected motor: %+v", m)
+	}
+}
+
+func TestReadDevicesErrors(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		json   string
+		expect error
+	}{
+		{"Invalid ID", `[{"model": 1020, "id": 253}]`, ErrInvalidID},
+		{"Unknown register", `[{"model": 1020, "id": 1, "values": {"Flux Capacitor": 1}}]`, nil},
+		{"Unknown model", `[{"model": 1, "id": 1}]`, nil},
+		{"Malformed", `[{"model": "XM430"}]`, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadDevices(strings.NewReader(tc.json))
+			if err == nil {
+				t.Fatal("Expected an error but got none")
+			}
+			if tc.expect != nil && !errors.Is(err, tc.expect) {
+				t.Errorf("Expected error of %q but got %q", tc.expect, err)
+			}
+		})
+	}
+}
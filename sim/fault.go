@@ -0,0 +1,247 @@
+package sim
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/haguro/go-dxl/internal/dxl2"
+)
+
+// Instruction identifies the instruction a status packet is returned for, so that faults can be applied to the
+// status packets of some instructions only.
+type Instruction byte
+
+const (
+	AnyInstruction          Instruction = 0
+	PingInstruction         Instruction = Instruction(ping)
+	ReadInstruction         Instruction = Instruction(read)
+	WriteInstruction        Instruction = Instruction(write)
+	RegWriteInstruction     Instruction = Instruction(regWrite)
+	ActionInstruction       Instruction = Instruction(action)
+	ResetInstruction        Instruction = Instruction(reset)
+	RebootInstruction       Instruction = Instruction(reboot)
+	ClearInstruction        Instruction = Instruction(clear)
+	BackupInstruction       Instruction = Instruction(backup)
+	SyncReadInstruction     Instruction = Instruction(syncRead)
+	SyncWriteInstruction    Instruction = Instruction(syncWrite)
+	FastSyncReadInstruction Instruction = Instruction(fastSyncRead)
+	BulkReadInstruction     Instruction = Instruction(bulkRead)
+	BulkWriteInstruction    Instruction = Instruction(bulkWrite)
+	FastBulkReadInstruction Instruction = Instruction(fastBulkRead)
+)
+
+var instructionNames = map[Instruction]string{
+	AnyInstruction:          "any",
+	PingInstruction:         "ping",
+	ReadInstruction:         "read",
+	WriteInstruction:        "write",
+	RegWriteInstruction:     "reg write",
+	ActionInstruction:       "action",
+	ResetInstruction:        "reset",
+	RebootInstruction:       "reboot",
+	ClearInstruction:        "clear",
+	BackupInstruction:       "backup",
+	SyncReadInstruction:     "sync read",
+	SyncWriteInstruction:    "sync write",
+	FastSyncReadInstruction: "fast sync read",
+	BulkReadInstruction:     "bulk read",
+	BulkWriteInstruction:    "bulk write",
+	FastBulkReadInstruction: "fast bulk read",
+}
+
+func (i Instruction) String() string {
+	if name, ok := instructionNames[i]; ok {
+		return name
+	}
+	return fmt.Sprintf("Instruction(%#02x)", byte(i))
+}
+
+// The bits of the Hardware Error Status register, which report the hardware errors detected by a device.
+// See https://emanual.robotis.com/docs/en/dxl/x/xm430-w350/#hardware-error-status for more details.
+const (
+	InputVoltageError    byte = 1 << 0
+	OverheatingError     byte = 1 << 2
+	MotorEncoderError    byte = 1 << 3
+	ElectricalShockError byte = 1 << 4
+	OverloadError        byte = 1 << 5
+)
+
+// Fault describes a fault to apply to the status packets returned by the devices on a bus, to reproduce the failures
+// of a real bus. A fault applies to the status packets of the devices with the given ID that are returned for the
+// given instruction, and the faults that apply to the same status packet are applied in the order they were added.
+// The status packets of `fast sync read` and `fast bulk read` instructions are shared by all the devices read from, so
+// a fault that applies to any of them applies to the whole packet.
+// Note that devices don't return a status packet for every instruction (see the Status Return Level register), and
+// faults only apply to the ones that are returned. Devices sharing an ID don't need a fault to be simulated: they
+// respond at the same time and corrupt each other's status packets, as on a real bus (see collide).
+type Fault struct {
+	ID          byte        //The ID of the devices whose status packets are affected, or BroadcastID for all devices.
+	Instruction Instruction //The instruction whose status packets are affected, or AnyInstruction for all of them.
+	After       int         //The number of status packets the fault applies to that are let through first.
+	Times       int         //The number of status packets the fault applies to (after the first After), 0 for all.
+
+	Drop          bool          //Don't return the status packet at all.
+	HardwareError byte          //Set these bits of the device's Hardware Error Status register (see SetHardwareError).
+	FlipBits      []int         //Invert these bits of the status packet, counted from the first bit of the first byte.
+	Truncate      int           //Cut this many bytes from the end of the status packet.
+	Noise         []byte        //Send these bytes before the status packet.
+	Delay         time.Duration //Hold back the status packet (and any that follow) for this long.
+	DelayFrom     int           //Only hold back the bytes of the status packet from this position onwards.
+}
+
+// fault is a fault added to a bus, along with the number of status packets it applied to so far.
+type fault struct {
+	Fault
+	matched int
+}
+
+// AddFault adds the given fault to the bus. It applies to the status packets returned from then on.
+func (b *Bus) AddFault(f Fault) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.faults = append(b.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes all the faults from the bus.
+func (b *Bus) ClearFaults() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.faults = nil
+}
+
+// applies reports whether the fault applies to a status packet of the devices with the given IDs that is returned
+// for the given instruction, and counts it if it does.
+func (f *fault) applies(ids []byte, command byte) bool {
+	if f.Instruction != AnyInstruction && byte(f.Instruction) != command {
+		return false
+	}
+	found := f.ID == BroadcastID
+	for _, id := range ids {
+		found = found || id == f.ID
+	}
+	if !found {
+		return false
+	}
+	f.matched++
+	return f.matched > f.After && (f.Times == 0 || f.matched-f.After <= f.Times)
+}
+
+// flush sends the status packets returned for the instruction that was just processed to the given port, with the
+// faults of the bus applied. Status packets of different devices with the same ID are sent at the same time, and
+// collide.
+func (b *Bus) flush(p *Port) {
+	at := time.Now()
+	if len(p.tx) > 0 && p.tx[len(p.tx)-1].at.After(at) {
+		// Status packets can't overtake the ones being held back.
+		at = p.tx[len(p.tx)-1].at
+	}
+	for i := 0; i < len(b.statuses); i++ {
+		r := b.statuses[i]
+		packet := r.packet
+		for i+1 < len(b.statuses) && b.statuses[i+1].servo != r.servo && equalIDs(b.statuses[i+1].ids, r.ids) {
+			i++
+			packet = collide(packet, b.statuses[i].packet)
+		}
+
+		var dropped bool
+		var delay time.Duration
+		var delayFrom int
+		for _, f := range b.faults {
+			if !f.applies(r.ids, b.command) {
+				continue
+			}
+			if f.HardwareError != 0 {
+				for _, id := range r.ids {
+					for _, s := range b.targets(id) {
+						s.SetHardwareError(f.HardwareError)
+					}
+				}
+				if len(packet) > 8 {
+					packet[8] |= errAlert
+					crc := dxl2.CRC(packet[:len(packet)-2])
+					packet[len(packet)-2], packet[len(packet)-1] = byte(crc), byte(crc>>8)
+				}
+			}
+			dropped = dropped || f.Drop
+			for _, bit := range f.FlipBits {
+				if bit >= 0 && bit/8 < len(packet) {
+					packet[bit/8] ^= 1 << (bit % 8)
+				}
+			}
+			if f.Truncate > 0 {
+				packet = packet[:len(packet)-min(f.Truncate, len(packet))]
+			}
+			if len(f.Noise) > 0 {
+				packet = append(append([]byte{}, f.Noise...), packet...)
+				delayFrom += len(f.Noise)
+			}
+			if f.Delay > 0 {
+				delay += f.Delay
+				delayFrom = f.DelayFrom
+			}
+		}
+		if dropped {
+			continue
+		}
+		delayFrom = min(delayFrom, len(packet))
+		if delay <= 0 {
+			delayFrom = len(packet)
+		}
+		p.tx = append(p.tx, chunk{at, packet[:delayFrom]})
+		at = at.Add(delay)
+		p.tx = append(p.tx, chunk{at, packet[delayFrom:]})
+	}
+	b.statuses = nil
+}
+
+// collide returns the bytes read when the given status packets are sent at the same time. The bus idles high and
+// either device can pull it low, so the bytes read are those of both packets ANDed together. Note that identical
+// status packets (e.g. the `ping` status of two devices of the same model) collide without being corrupted.
+func collide(a, b []byte) []byte {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	c := append([]byte{}, a...)
+	for i := range b {
+		c[i] &= b[i]
+	}
+	return c
+}
+
+// equalIDs reports whether a and b hold the same IDs.
+func equalIDs(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SetHardwareError sets the given bits of the servo's Hardware Error Status register (e.g. OverheatingError), as the
+// device does when it detects a hardware error. Every status packet the servo returns from then on reports the error,
+// and torque is disabled if any of the bits is also set in the Shutdown register. The error is cleared by rebooting
+// the servo, unless its cause remains.
+func (s *Servo) SetHardwareError(errs byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setHardwareError(errs)
+}
+
+// setHardwareError is like SetHardwareError. The lock must be held.
+func (s *Servo) setHardwareError(errs byte) {
+	s.set("Hardware Error Status", s.get("Hardware Error Status")|int64(errs))
+	if errs&byte(s.get("Shutdown")) != 0 {
+		s.set("Torque Enable", 0)
+	}
+}
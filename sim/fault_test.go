@@ -0,0 +1,199 @@
+package sim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+func TestFaults(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		faults    []Fault
+		expectErr []error // The error of each of a series of reads of device ID 1.
+	}{
+		{
+			name:      "No faults",
+			expectErr: []error{nil, nil},
+		},
+		{
+			name:      "Drop",
+			faults:    []Fault{{ID: 1, Drop: true}},
+			expectErr: []error{protocol2.ErrReadTimeout, protocol2.ErrReadTimeout},
+		},
+		{
+			name:      "Drop another device",
+			faults:    []Fault{{ID: 2, Drop: true}},
+			expectErr: []error{nil, nil},
+		},
+		{
+			name:      "Drop all devices",
+			faults:    []Fault{{ID: BroadcastID, Drop: true}},
+			expectErr: []error{protocol2.ErrReadTimeout},
+		},
+		{
+			name:      "Drop another instruction",
+			faults:    []Fault{{ID: 1, Instruction: PingInstruction, Drop: true}},
+			expectErr: []error{nil},
+		},
+		{
+			name:      "Drop once",
+			faults:    []Fault{{ID: 1, Instruction: ReadInstruction, Times: 1, Drop: true}},
+			expectErr: []error{protocol2.ErrReadTimeout, nil, nil},
+		},
+		{
+			name:      "Drop after",
+			faults:    []Fault{{ID: 1, After: 2, Times: 1, Drop: true}},
+			expectErr: []error{nil, nil, protocol2.ErrReadTimeout, nil},
+		},
+		{
+			name:      "Flip CRC bit",
+			faults:    []Fault{{ID: 1, FlipBits: []int{8 * 14}}},
+			expectErr: []error{protocol2.ErrStatusCRCInvalid},
+		},
+		{
+			name:      "Flip instruction bit",
+			faults:    []Fault{{ID: 1, FlipBits: []int{8*7 + 1}}},
+			expectErr: []error{protocol2.ErrMalformedStatus},
+		},
+		{
+			name:      "Truncate",
+			faults:    []Fault{{ID: 1, Truncate: 2}},
+			expectErr: []error{protocol2.ErrReadTimeout},
+		},
+		{
+			name:      "Noise",
+			faults:    []Fault{{ID: 1, Noise: []byte{0xFF, 0xFF, 0xFD, 0x12, 0x00}}},
+			expectErr: []error{nil},
+		},
+		{
+			name:      "Short delay",
+			faults:    []Fault{{ID: 1, Delay: 5 * time.Millisecond}},
+			expectErr: []error{nil},
+		},
+		{
+			name:      "Long delay",
+			faults:    []Fault{{ID: 1, Delay: 50 * time.Millisecond}},
+			expectErr: []error{protocol2.ErrReadTimeout},
+		},
+		{
+			name:      "Long mid-packet delay",
+			faults:    []Fault{{ID: 1, Delay: 50 * time.Millisecond, DelayFrom: 6}},
+			expectErr: []error{protocol2.ErrReadTimeout},
+		},
+		{
+			name:      "Hardware error",
+			faults:    []Fault{{ID: 1, After: 1, Times: 1, HardwareError: OverloadError}},
+			expectErr: []error{nil, protocol2.ErrDeviceError, protocol2.ErrDeviceError},
+		},
+		{
+			name: "Faults applied in order",
+			faults: []Fault{
+				{ID: 1, Noise: []byte{0x00, 0x01}},
+				{ID: 1, Delay: 50 * time.Millisecond, DelayFrom: 2},
+			},
+			expectErr: []error{protocol2.ErrReadTimeout},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, b := newTestBus(t, 1020, 1020)
+			var delay time.Duration
+			for _, f := range tc.faults {
+				b.AddFault(f)
+				delay += f.Delay
+			}
+			for i, expectErr := range tc.expectErr {
+				_, err := h.Read(1, 132, 4)
+				if !errors.Is(err, expectErr) {
+					t.Fatalf("Read %d: expected error of %q but got %q", i, expectErr, err)
+				}
+				// Let any status that was held back arrive, and be discarded, before the next read.
+				time.Sleep(delay)
+				b.Read(make([]byte, 64))
+			}
+		})
+	}
+}
+
+func TestClearFaults(t *testing.T) {
+	h, b := newTestBus(t, 1020)
+	b.AddFault(Fault{ID: BroadcastID, Drop: true})
+	if _, err := h.Ping(1); !errors.Is(err, protocol2.ErrReadTimeout) {
+		t.Fatalf("Expected error of %q but got %q", protocol2.ErrReadTimeout, err)
+	}
+	b.ClearFaults()
+	if _, err := h.Ping(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestHardwareErrorFault(t *testing.T) {
+	h, b := newTestBus(t, 1020)
+	s := b.Servo(1)
+	s.SetValue("Torque Enable", 1)
+	b.AddFault(Fault{ID: 1, Instruction: WriteInstruction, HardwareError: OverheatingError})
+
+	if err := h.Write(1, 116, 0, 1, 0, 0); !errors.Is(err, protocol2.ErrDeviceError) {
+		t.Fatalf("Expected error of %q but got %q", protocol2.ErrDeviceError, err)
+	}
+	if v, _ := s.Value("Hardware Error Status"); byte(v) != OverheatingError {
+		t.Errorf("Expected Hardware Error Status %d but got %d", OverheatingError, v)
+	}
+	if v, _ := s.Value("Torque Enable"); v != 0 {
+		t.Errorf("Expected torque to be disabled by the shutdown but got %d", v)
+	}
+
+	b.ClearFaults()
+	if err := h.Reboot(1); !errors.Is(err, protocol2.ErrDeviceError) {
+		t.Fatalf("Expected error of %q but got %q", protocol2.ErrDeviceError, err)
+	}
+	if _, err := h.Ping(1); err != nil {
+		t.Errorf("Expected the error to be cleared by rebooting but got %v", err)
+	}
+}
+
+func TestMultiDeviceFaults(t *testing.T) {
+	h, b := newTestBus(t, 1020, 1020, 1020)
+	b.AddFault(Fault{ID: 2, Instruction: SyncReadInstruction, Drop: true})
+	b.AddFault(Fault{ID: 3, Instruction: FastSyncReadInstruction, FlipBits: []int{8 * 12}})
+
+	rs, err := h.SyncRead([]byte{1, 2, 3}, 132, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, expectErr := range []error{nil, protocol2.ErrReadTimeout, nil} {
+		if !errors.Is(rs[i].Err, expectErr) {
+			t.Errorf("Device ID %d: expected error of %q but got %q", rs[i].ID, expectErr, rs[i].Err)
+		}
+	}
+
+	if _, err := h.FastSyncRead([]byte{1, 2, 3}, 132, 4); !errors.Is(err, protocol2.ErrStatusCRCInvalid) {
+		t.Errorf("Expected error of %q but got %q", protocol2.ErrStatusCRCInvalid, err)
+	}
+	if _, err := h.FastSyncRead([]byte{1, 2}, 132, 4); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestIDCollision(t *testing.T) {
+	h, b := newTestBus(t, 1020, 1020, 1200)
+	b.Servo(3).SetValue("ID", 1)
+
+	if _, err := h.Ping(1); err == nil {
+		t.Errorf("Expected the colliding status packets to be corrupted")
+	}
+	rs, err := h.BroadcastPing()
+	if !errors.Is(err, protocol2.ErrDuplicateID) {
+		t.Fatalf("Expected error of %q but got %q", protocol2.ErrDuplicateID, err)
+	}
+	if len(rs) != 1 || rs[0].ID != 2 {
+		t.Errorf("Expected only device ID 2 to respond cleanly but got %+v", rs)
+	}
+	if _, err := h.Ping(2); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
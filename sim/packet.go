@@ -0,0 +1,150 @@
+package sim
+
+import "github.com/haguro/go-dxl/internal/dxl2"
+
+// The packet handling below mirrors that of the protocol/v2 package, seen from the device's side: instruction packets
+// are parsed and status packets are built. Byte stuffing and the CRC are shared with it through the internal dxl2
+// package.
+
+const (
+	header1 byte = 0xFF
+	header2 byte = 0xFF
+	header3 byte = 0xFD
+	headerR byte = 0x00
+)
+
+// Command (instruction) codes
+const (
+	ping         byte = 0x01
+	read         byte = 0x02
+	write        byte = 0x03
+	regWrite     byte = 0x04
+	action       byte = 0x05
+	reset        byte = 0x06
+	reboot       byte = 0x08
+	clear        byte = 0x10
+	backup       byte = 0x20
+	statusCmd    byte = 0x55
+	syncRead     byte = 0x82
+	syncWrite    byte = 0x83
+	fastSyncRead byte = 0x8A
+	bulkRead     byte = 0x92
+	bulkWrite    byte = 0x93
+	fastBulkRead byte = 0x9A
+)
+
+// The values of the error byte of status packets.
+// See https://emanual.robotis.com/docs/en/dxl/protocol2/#error for more details.
+const (
+	errResult      byte = 0x01
+	errInstruction byte = 0x02
+	errCRC         byte = 0x03
+	errDataRange   byte = 0x04
+	errDataLength  byte = 0x05
+	errDataLimit   byte = 0x06
+	errAccess      byte = 0x07
+	errAlert       byte = 0x80 // Set along with any of the above when the device has a hardware error.
+)
+
+// minInstructionLen is the length of an instruction packet with no params: header, ID, length, instruction and CRC.
+const minInstructionLen = 10
+
+// nextPacket looks for a complete instruction packet at the start of buf, skipping any bytes before the header.
+// It returns the packet (nil if there is no complete packet yet) and the bytes left in buf after it. The packet isn't
+// checked beyond its length.
+func nextPacket(buf []byte) (packet, rest []byte) {
+	for i := 0; i+3 < len(buf); i++ {
+		if buf[i] != header1 || buf[i+1] != header2 || buf[i+2] != header3 || buf[i+3] != headerR {
+			continue
+		}
+		buf = buf[i:]
+		if len(buf) < 7 {
+			return nil, buf
+		}
+		length := int(buf[5]) + int(buf[6])<<8
+		if len(buf) < length+7 {
+			return nil, buf
+		}
+		return buf[:length+7], buf[length+7:]
+	}
+	// Keep the last bytes in case they are the start of a header split across writes.
+	if len(buf) > 3 {
+		buf = buf[len(buf)-3:]
+	}
+	return nil, buf
+}
+
+// parseInstructionPacket returns the ID, instruction and (destuffed) params of the given instruction packet. ok is
+// false if the packet is malformed and crcOK is false if its CRC doesn't match.
+func parseInstructionPacket(packet []byte) (id, command byte, params []byte, ok, crcOK bool) {
+	l := len(packet)
+	if l < minInstructionLen {
+		return 0, 0, nil, false, false
+	}
+	id = packet[4]
+	crc := dxl2.CRC(packet[:l-2])
+	if packet[l-2] != byte(crc) || packet[l-1] != byte(crc>>8) {
+		return id, 0, nil, true, false
+	}
+	// The CRC is calculated over the stuffed packet, so stuffing bytes are only removed once it has been verified.
+	body := dxl2.Destuff(packet[7 : l-2])
+	return id, body[0], body[1:], true, true
+}
+
+// statusPacket returns the status packet of the device with the given ID, with the given error byte and params.
+func statusPacket(id, errByte byte, params []byte) []byte {
+	body := dxl2.Stuff(append([]byte{statusCmd, errByte}, params...))
+	length := len(body) + 2
+	packet := make([]byte, 0, length+7)
+	packet = append(packet, header1, header2, header3, headerR, id, byte(length), byte(length>>8))
+	packet = append(packet, body...)
+	crc := dxl2.CRC(packet)
+	return append(packet, byte(crc), byte(crc>>8))
+}
+
+// segment is the part of a device in the merged status packet of a `fast sync read` or `fast bulk read` instruction.
+type segment struct {
+	id      byte
+	errByte byte
+	data    []byte
+}
+
+// fastStatusPacket returns the merged status packet made of the given segments. Each segment but the last is followed
+// by a CRC calculated over the packet (as sent, i.e. stuffed) up to the end of the segment's data, and the last
+// segment's CRC is the packet's CRC.
+func fastStatusPacket(segments []segment) []byte {
+	// The length value is covered by the segment CRCs, but it depends on whether these CRCs need byte stuffing, which
+	// can add up to 2 bytes per CRC. So the packet is built with each length it could have until it comes out with
+	// that length. Should none do (the stuffing of a CRC calculated with one length changing the length to another
+	// and back), the packet is sent with its actual length and the CRC of the segment that can't agree with it.
+	n := len(segments) - 1
+	first := len(buildFastStatusPacket(segments, 0)) - 5
+	var packet []byte
+	for length := first - 2*n; length <= first+2*n; length++ {
+		packet = buildFastStatusPacket(segments, length)
+		if len(packet)-5 == length {
+			break
+		}
+	}
+	length := len(packet) - 5
+	packet[5], packet[6] = byte(length), byte(length>>8)
+	crc := dxl2.CRC(packet)
+	return append(packet, byte(crc), byte(crc>>8))
+}
+
+// buildFastStatusPacket returns the merged status packet made of the given segments without its CRC, with the segment
+// CRCs calculated for the given length value.
+func buildFastStatusPacket(segments []segment, length int) []byte {
+	packet := []byte{header1, header2, header3, headerR, 0xFE, byte(length), byte(length >> 8)}
+	head := len(packet)
+	raw := []byte{statusCmd}
+	for i, s := range segments {
+		raw = append(raw, s.errByte, s.id)
+		raw = append(raw, s.data...)
+		if i < len(segments)-1 {
+			crc := dxl2.CRC(append(packet[:head:head], dxl2.Stuff(raw)...))
+			raw = append(raw, byte(crc), byte(crc>>8))
+		}
+	}
+	return append(packet, dxl2.Stuff(raw)...)
+}
@@ -0,0 +1,75 @@
+package sim
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/haguro/go-dxl/bus"
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+// cannedPort discards the instruction packets written to it and returns the given status packet.
+type cannedPort struct {
+	status []byte
+}
+
+func (p *cannedPort) Read(b []byte) (int, error) {
+	if len(p.status) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, p.status)
+	p.status = p.status[n:]
+	return n, nil
+}
+
+func (p *cannedPort) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func TestFastStatusPacket(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		segments []segment
+	}{
+		{
+			name: "Stuffed segment CRC",
+			// The CRC of the second segment ends in a header pattern, which makes the packet one byte longer.
+			segments: []segment{
+				{id: 1, data: []byte{0xD0, 0xB4, 0xFF, 0xFF}},
+				{id: 2, data: []byte{0x00, 0xB4, 0xFF, 0xFF}},
+				{id: 3, data: []byte{0x01, 0x02, 0x03, 0x04}},
+			},
+		},
+		{
+			name: "Length not settling by rebuilding",
+			// Rebuilding the packet with the length it last came out with doesn't settle within a few rounds here, as
+			// the stuffing of the segment CRCs changes with each length.
+			segments: []segment{
+				{id: 1, data: []byte{0xAB, 0xDD, 0xFF, 0xFF}},
+				{id: 2, data: []byte{0x3F, 0xFF, 0xFF}},
+				{id: 3, data: []byte{0x01}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var descriptors []bus.BulkReadDescriptor
+			var expect [][]byte
+			for _, s := range tc.segments {
+				descriptors = append(descriptors, bus.BulkReadDescriptor{ID: s.id, Addr: 132, Length: uint16(len(s.data))})
+				expect = append(expect, s.data)
+			}
+			h := protocol2.NewHandler(&cannedPort{status: fastStatusPacket(tc.segments)}, 10*time.Millisecond)
+			got, err := h.FastBulkRead(descriptors)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, expect) {
+				t.Errorf("Expected %v but got %v", expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+// Motor describes the motor, gearbox and load of a simulated servo. All values are as seen at the output shaft of the
+// servo, apart from RotorInertia. The motor is modelled as a DC motor with no inductance, so its current follows the
+// applied voltage instantly.
+type Motor struct {
+	GearRatio           float64       //The number of turns of the motor per turn of the output shaft.
+	RotorInertia        float64       //The inertia of the motor's rotor (kg m^2), multiplied by the gear ratio squared.
+	Inertia             float64       //The inertia of the load (kg m^2).
+	Friction            float64       //The viscous friction (N m s/rad).
+	StaticFriction      float64       //The torque needed to start turning (N m).
+	MaxTorque           float64       //The stall torque at NominalVoltage (N m).
+	StallCurrent        float64       //The current drawn at stall at NominalVoltage (A).
+	NominalVoltage      float64       //The voltage MaxTorque and StallCurrent are given at (V).
+	ThermalResistance   float64       //The rise in temperature per watt of power lost in the motor (degC/W).
+	ThermalTimeConstant time.Duration //The time the temperature takes to get 63% of the way to its final value.
+	AmbientTemperature  float64       //The temperature of the air around the servo (degC).
+}
+
+// DefaultMotor is the motor simulated servos are created with, roughly that of an XM430-W350 with no load attached.
+var DefaultMotor = Motor{
+	GearRatio:           353.5,
+	RotorInertia:        1e-7,
+	Friction:            0.24,
+	StaticFriction:      0.05,
+	MaxTorque:           4.1,
+	StallCurrent:        2.3,
+	NominalVoltage:      12,
+	ThermalResistance:   3,
+	ThermalTimeConstant: 5 * time.Minute,
+	AmbientTemperature:  25,
+}
+
+// torqueConstant returns the torque per amp of current (N m/A), which is also the back EMF per rad/s (V s/rad).
+func (m Motor) torqueConstant() float64 {
+	return m.MaxTorque / m.StallCurrent
+}
+
+// resistance returns the resistance of the motor's winding (ohm).
+func (m Motor) resistance() float64 {
+	return m.NominalVoltage / m.StallCurrent
+}
+
+// inertia returns the total inertia at the output shaft (kg m^2).
+func (m Motor) inertia() float64 {
+	return m.Inertia + m.RotorInertia*m.GearRatio*m.GearRatio
+}
+
+const (
+	controlPeriod  = time.Millisecond // The period of the servo's control loop, and of its Realtime Tick register.
+	physicsSteps   = 10               // The number of steps the motion is integrated in per control period.
+	maxPWM         = 885              // The raw value of Goal PWM and Present PWM that is 100% of the input voltage.
+	positionScale  = 2 * math.Pi / 4096
+	velocityScale  = 0.229 * 2 * math.Pi / 60
+	inPositionBand = 20 * positionScale // How close to the goal position the servo has to be to be in position.
+)
+
+// The operating modes, as set in the Operating Mode register.
+const (
+	currentMode              = 0
+	velocityMode             = 1
+	extendedPositionMode     = 4
+	currentBasedPositionMode = 5
+	pwmMode                  = 16
+)
+
+// The bits of the Moving Status register.
+const (
+	inPosition      byte = 1 << 0
+	profileOngoing  byte = 1 << 1
+	trapezoidalType byte = 3 << 4
+)
+
+// physics holds the state of a servo's motion and of its controller between control cycles.
+type physics struct {
+	motor       Motor
+	position    float64 //The position of the output shaft (rad), not counting the homing offset.
+	velocity    float64 //rad/s
+	current     float64 //A
+	temperature float64 //degC
+	pwm         float64 //The fraction of the input voltage applied to the motor, from -1 to 1.
+	profile     profile
+	integral    float64
+	prevErr     float64
+	mode        int64
+	torque      bool
+	elapsed     time.Duration //The time stepped that doesn't make up a whole control period yet.
+	tick        int64
+}
+
+// SetMotor sets the motor of the servo.
+func (s *Servo) SetMotor(m Motor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phys.motor = m
+}
+
+// Motor returns the motor of the servo.
+func (s *Servo) Motor() Motor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.phys.motor
+}
+
+// Step advances the simulation of the servo by the given duration, updating its present values (position, velocity,
+// current, temperature, etc.) as it moves towards its goal. The servo's control loop runs once per millisecond of
+// simulated time, as on the real device, so durations shorter than that are accumulated until they add up to one.
+func (s *Servo) Step(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phys.elapsed += d
+	for s.phys.elapsed >= controlPeriod {
+		s.phys.elapsed -= controlPeriod
+		s.control(controlPeriod.Seconds())
+	}
+}
+
+// has reports whether the servo's model has the register with the given name.
+func (s *Servo) has(name string) bool {
+	_, err := s.register(name)
+	return err == nil
+}
+
+// getSI returns the value of the register with the given name in its SI unit, or 0 if the model doesn't have it. The
+// lock must be held.
+func (s *Servo) getSI(name string) float64 {
+	r, err := s.register(name)
+	if err != nil {
+		return 0
+	}
+	v, _ := r.Decode(s.table[r.Addr : r.Addr+r.Size])
+	si, _ := r.ToSI(v)
+	return si
+}
+
+// setSI sets the register with the given name to the given value in its SI unit, clamped to the register's range, if
+// the model has it. The lock must be held.
+func (s *Servo) setSI(name string, v float64) {
+	r, err := s.register(name)
+	if err != nil {
+		return
+	}
+	one, err := r.ToSI(1)
+	if err != nil {
+		return
+	}
+	raw := math.Round(v / one)
+	raw = math.Max(float64(r.Min), math.Min(float64(r.Max), raw))
+	s.put(s.table, r, int64(raw))
+}
+
+// sync updates the state of the simulation from the present values of the control table that have been changed from
+// outside of it, e.g. with SetValue or by a `clear` instruction. The lock must be held.
+func (s *Servo) sync() {
+	p := &s.phys
+	offset := s.getSI("Homing Offset")
+	if r, err := s.register("Present Position"); err == nil {
+		v, _ := r.Decode(s.table[r.Addr : r.Addr+r.Size])
+		one, _ := r.ToSI(1)
+		if v != int64(math.Round((p.position+offset)/one)) {
+			p.position = float64(v)*one - offset
+		}
+	}
+	if t := float64(s.get("Present Temperature")); t != math.Round(p.temperature) {
+		p.temperature = t
+	}
+}
+
+// control runs one cycle of the servo's control loop, lasting dt seconds.
+func (s *Servo) control(dt float64) {
+	p := &s.phys
+	m := p.motor
+	s.sync()
+
+	mode := s.get("Operating Mode")
+	torque := s.get("Torque Enable") != 0
+	if mode != p.mode || torque != p.torque {
+		// The trajectory starts off from where the servo is, and the controller from scratch.
+		p.profile = profile{position: p.position, velocity: p.velocity}
+		p.integral, p.prevErr = 0, 0
+		p.mode, p.torque = mode, torque
+	}
+
+	offset := s.getSI("Homing Offset")
+	voltage := m.NominalVoltage
+	if s.has("Present Input Voltage") {
+		voltage = s.getSI("Present Input Voltage")
+	}
+	pwmLimit := 1.0
+	if s.has("PWM Limit") {
+		pwmLimit = float64(s.get("PWM Limit")) / maxPWM
+	}
+	currentLimit := s.getSI("Current Limit")
+	if currentLimit == 0 {
+		currentLimit = s.getSI("Torque Limit")
+	}
+	velocityLimit := s.getSI("Velocity Limit")
+	profileVelocity, profileAcceleration := s.getSI("Profile Velocity"), s.getSI("Profile Acceleration")
+	if !s.has("Profile Velocity") {
+		profileVelocity, profileAcceleration = s.getSI("Goal Velocity"), s.getSI("Goal Acceleration")
+	}
+	if velocityLimit > 0 && (profileVelocity == 0 || profileVelocity > velocityLimit) {
+		profileVelocity = velocityLimit
+	}
+
+	// The controller's gains are those of the X series, which are applied to errors in its raw units and give a PWM
+	// output in raw units too.
+	pwm := 0.0
+	if torque {
+		switch mode {
+		case pwmMode:
+			pwm = s.getSI("Goal PWM") / 100
+		case currentMode:
+			pwm = (s.goalCurrent()*m.resistance() + m.torqueConstant()*p.velocity) / voltage
+		case velocityMode:
+			p.profile.stepVelocity(s.getSI("Goal Velocity"), profileAcceleration, dt)
+			err := (p.profile.velocity - p.velocity) / velocityScale
+			p.integral += err
+			pwm = (float64(s.get("Velocity P Gain"))/128*err + float64(s.get("Velocity I Gain"))/65536*p.integral) / maxPWM
+		default:
+			p.profile.stepPosition(s.getSI("Goal Position")-offset, profileVelocity, profileAcceleration, dt)
+			err := (p.profile.position - p.position) / positionScale
+			p.integral += err
+			pwm = (float64(s.get("Position P Gain"))/128*err +
+				float64(s.get("Position I Gain"))/65536*p.integral +
+				float64(s.get("Position D Gain"))/16*(err-p.prevErr) +
+				float64(s.get("Feedforward 1st Gain"))/4*p.profile.velocity/velocityScale) / maxPWM
+			p.prevErr = err
+			if mode == currentBasedPositionMode {
+				currentLimit = math.Min(currentLimit, math.Abs(s.goalCurrent()))
+			}
+		}
+	}
+	// Keep the integral from winding up while the output is saturated.
+	if math.Abs(pwm) > pwmLimit && p.integral != 0 {
+		p.integral *= 0.99
+	}
+	p.pwm = math.Max(-pwmLimit, math.Min(pwmLimit, pwm))
+
+	s.move(dt, voltage, currentLimit, torque)
+	s.update(dt, mode, offset, torque)
+}
+
+// goalCurrent returns the goal current of the servo (A). The lock must be held.
+func (s *Servo) goalCurrent() float64 {
+	if s.has("Goal Current") {
+		return s.getSI("Goal Current")
+	}
+	return s.getSI("Goal Torque")
+}
+
+// move integrates the motion of the output shaft and the temperature of the motor over dt seconds, with the PWM
+// output of the controller applied to the motor.
+func (s *Servo) move(dt, voltage, currentLimit float64, torque bool) {
+	p := &s.phys
+	m := p.motor
+	k, r, j := m.torqueConstant(), m.resistance(), m.inertia()
+	h := dt / physicsSteps
+	for i := 0; i < physicsSteps; i++ {
+		p.current = 0
+		if torque {
+			p.current = (p.pwm*voltage - k*p.velocity) / r
+			if currentLimit > 0 {
+				p.current = math.Max(-currentLimit, math.Min(currentLimit, p.current))
+			}
+		}
+		t := k*p.current - m.Friction*p.velocity
+		switch {
+		case p.velocity != 0:
+			t -= math.Copysign(m.StaticFriction, p.velocity)
+		case math.Abs(t) <= m.StaticFriction:
+			t = 0
+		default:
+			t -= math.Copysign(m.StaticFriction, t)
+		}
+		v := p.velocity + t/j*h
+		if p.velocity != 0 && v*p.velocity < 0 && math.Abs(k*p.current) <= m.StaticFriction {
+			v = 0 // Friction stops the shaft rather than turning it the other way.
+		}
+		p.velocity = v
+		p.position += p.velocity * h
+	}
+
+	// The temperature heads towards the ambient temperature plus the rise caused by the power lost in the winding.
+	if m.ThermalTimeConstant > 0 {
+		target := m.AmbientTemperature + p.current*p.current*r*m.ThermalResistance
+		p.temperature += (target - p.temperature) * dt / m.ThermalTimeConstant.Seconds()
+	}
+}
+
+// update sets the present values of the control table from the state of the simulation, and checks for hardware
+// errors.
+func (s *Servo) update(dt float64, mode int64, offset float64, torque bool) {
+	p := &s.phys
+	m := p.motor
+
+	s.setSI("Present Position", p.position+offset)
+	s.setSI("Present Velocity", p.velocity)
+	s.setSI("Present PWM", p.pwm*100)
+	s.setSI("Present Current", p.current)
+	s.setSI("Present Load", m.torqueConstant()*p.current/m.MaxTorque*100)
+	s.setSI("Present Temperature", p.temperature)
+	s.setSI("Position Trajectory", p.profile.position+offset)
+	s.setSI("Velocity Trajectory", p.profile.velocity)
+	p.tick = (p.tick + int64(dt*1000)) % 32768
+	s.set("Realtime Tick", p.tick)
+
+	moving := int64(0)
+	if math.Abs(p.velocity) > s.getSI("Moving Threshold") {
+		moving = 1
+	}
+	s.set("Moving", moving)
+	var status byte
+	if torque && mode != velocityMode && mode != currentMode && mode != pwmMode {
+		goal := s.getSI("Goal Position") - offset
+		if !p.profile.done(goal) {
+			status |= profileOngoing | trapezoidalType
+		} else if math.Abs(goal-p.position) <= inPositionBand {
+			status |= inPosition
+		}
+	}
+	s.set("Moving Status", int64(status))
+
+	var errs byte
+	if p.temperature > float64(s.get("Temperature Limit")) {
+		errs |= OverheatingError
+	}
+	v := s.get("Present Input Voltage")
+	if s.has("Present Input Voltage") && (v < s.get("Min Voltage Limit") || v > s.get("Max Voltage Limit")) {
+		errs |= InputVoltageError
+	}
+	if errs != 0 {
+		s.setHardwareError(errs)
+	}
+}
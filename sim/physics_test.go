@@ -0,0 +1,234 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// newMovingServo returns an XM430-W350 in the given operating mode with torque enabled.
+func newMovingServo(t *testing.T, mode int64) *Servo {
+	t.Helper()
+	s, err := NewServo(1020, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s.SetValue("Operating Mode", mode)
+	s.SetValue("Torque Enable", 1)
+	return s
+}
+
+func value(s *Servo, name string) int64 {
+	v, _ := s.Value(name)
+	return v
+}
+
+func TestPWMMode(t *testing.T) {
+	s := newMovingServo(t, pwmMode)
+	s.SetValue("Goal PWM", maxPWM)
+	s.Step(2 * time.Second)
+
+	// At full PWM, the servo should settle at about its no-load speed of 46 rpm at 12V, and the input voltage is 11V.
+	rpm := float64(value(s, "Present Velocity")) * 0.229
+	if rpm < 38 || rpm > 46 {
+		t.Errorf("Expected a velocity of about 42 rpm but got %g rpm", rpm)
+	}
+	if v := value(s, "Present PWM"); v != maxPWM {
+		t.Errorf("Expected Present PWM of %d but got %d", maxPWM, v)
+	}
+	if v := value(s, "Moving"); v != 1 {
+		t.Errorf("Expected Moving to be 1 but got %d", v)
+	}
+	// Well short of the stall current of 2.3A, in units of 2.69mA.
+	if v := value(s, "Present Current"); v <= 0 || v > 300 {
+		t.Errorf("Expected a small positive no-load current but got %d", v)
+	}
+}
+
+func TestVelocityMode(t *testing.T) {
+	s := newMovingServo(t, velocityMode)
+	s.SetValue("Profile Acceleration", 50)
+	s.SetValue("Goal Velocity", 100)
+
+	s.Step(100 * time.Millisecond)
+	if v := value(s, "Velocity Trajectory"); v <= 0 || v >= 100 {
+		t.Errorf("Expected the velocity trajectory to still be accelerating but got %d", v)
+	}
+	s.Step(2 * time.Second)
+	if v := value(s, "Present Velocity"); v < 97 || v > 103 {
+		t.Errorf("Expected a velocity of about 100 but got %d", v)
+	}
+	if v := value(s, "Velocity Trajectory"); v != 100 {
+		t.Errorf("Expected a velocity trajectory of 100 but got %d", v)
+	}
+}
+
+func TestPositionMode(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		mode         int64
+		velocity     int64
+		acceleration int64
+		goal         int64
+		duration     time.Duration
+	}{
+		{name: "Trapezoidal profile", mode: 3, velocity: 100, acceleration: 20, goal: 2048, duration: 3 * time.Second},
+		{name: "No profile", mode: 3, goal: 1000, duration: time.Second},
+		{name: "Extended position", mode: extendedPositionMode, velocity: 150, acceleration: 50, goal: -10000,
+			duration: 5 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newMovingServo(t, tc.mode)
+			s.SetValue("Profile Velocity", tc.velocity)
+			s.SetValue("Profile Acceleration", tc.acceleration)
+			s.SetValue("Goal Position", tc.goal)
+
+			var last int64
+			for elapsed := time.Duration(0); elapsed < tc.duration; elapsed += 10 * time.Millisecond {
+				s.Step(10 * time.Millisecond)
+				p := value(s, "Present Position")
+				if (tc.goal > 0 && p < last-5) || (tc.goal < 0 && p > last+5) {
+					t.Fatalf("Expected the servo to move steadily towards %d but went from %d to %d", tc.goal, last, p)
+				}
+				if tc.velocity > 0 {
+					if v := value(s, "Velocity Trajectory"); v > tc.velocity || v < -tc.velocity {
+						t.Fatalf("Expected the velocity trajectory to stay within the profile velocity but got %d", v)
+					}
+				}
+				last = p
+			}
+
+			if p := value(s, "Present Position"); math.Abs(float64(p-tc.goal)) > 10 {
+				t.Errorf("Expected a position of about %d but got %d", tc.goal, p)
+			}
+			if v := value(s, "Moving"); v != 0 {
+				t.Errorf("Expected Moving to be 0 but got %d", v)
+			}
+			if v := value(s, "Moving Status"); byte(v) != inPosition {
+				t.Errorf("Expected Moving Status to be %d but got %d", inPosition, v)
+			}
+		})
+	}
+}
+
+func TestProfileUnderway(t *testing.T) {
+	s := newMovingServo(t, 3)
+	s.SetValue("Profile Velocity", 50)
+	s.SetValue("Profile Acceleration", 10)
+	s.SetValue("Goal Position", 4000)
+	s.Step(500 * time.Millisecond)
+
+	if v := value(s, "Moving"); v != 1 {
+		t.Errorf("Expected Moving to be 1 but got %d", v)
+	}
+	if v := byte(value(s, "Moving Status")); v&profileOngoing == 0 {
+		t.Errorf("Expected the profile to be ongoing but got Moving Status %d", v)
+	}
+	if p, tr := value(s, "Present Position"), value(s, "Position Trajectory"); p <= 0 || tr <= 0 || tr >= 4000 {
+		t.Errorf("Expected position and trajectory to be under way but got %d and %d", p, tr)
+	}
+	if v := value(s, "Realtime Tick"); v != 500 {
+		t.Errorf("Expected Realtime Tick to be 500 but got %d", v)
+	}
+}
+
+func TestTorqueDisabled(t *testing.T) {
+	s := newMovingServo(t, 3)
+	s.SetValue("Torque Enable", 0)
+	s.SetValue("Goal Position", 2048)
+	s.Step(time.Second)
+	if v := value(s, "Present Position"); v != 0 {
+		t.Errorf("Expected the servo not to move but got position %d", v)
+	}
+	if v := value(s, "Present Current"); v != 0 {
+		t.Errorf("Expected no current but got %d", v)
+	}
+}
+
+func TestCurrentMode(t *testing.T) {
+	s := newMovingServo(t, currentMode)
+	m := s.Motor()
+	m.StaticFriction = 100 // The shaft is held in place.
+	s.SetMotor(m)
+	s.SetValue("Goal Current", 300)
+	s.Step(100 * time.Millisecond)
+	if v := value(s, "Present Current"); v != 300 {
+		t.Errorf("Expected a current of 300 but got %d", v)
+	}
+	if v := value(s, "Present Position"); v != 0 {
+		t.Errorf("Expected the held servo not to move but got position %d", v)
+	}
+}
+
+func TestOverheating(t *testing.T) {
+	s := newMovingServo(t, pwmMode)
+	m := s.Motor()
+	m.StaticFriction = 100 // The shaft is stalled.
+	m.ThermalTimeConstant = 10 * time.Second
+	s.SetMotor(m)
+	s.SetValue("Goal PWM", maxPWM)
+
+	s.Step(2 * time.Second)
+	t1 := value(s, "Present Temperature")
+	if t1 <= 25 {
+		t.Fatalf("Expected the temperature to rise above 25 degC but got %d", t1)
+	}
+	if v := value(s, "Hardware Error Status"); v != 0 {
+		t.Fatalf("Expected no hardware error yet but got %d", v)
+	}
+
+	s.Step(time.Minute)
+	if v := byte(value(s, "Hardware Error Status")); v&OverheatingError == 0 {
+		t.Errorf("Expected an overheating error but got Hardware Error Status %d", v)
+	}
+	if v := value(s, "Torque Enable"); v != 0 {
+		t.Errorf("Expected torque to be disabled by the shutdown but got %d", v)
+	}
+	s.Step(2 * time.Minute)
+	if v := value(s, "Present Temperature"); v > 30 {
+		t.Errorf("Expected the servo to cool down once shut down but got %d degC", v)
+	}
+}
+
+func TestStepThroughHandler(t *testing.T) {
+	h, b := newTestBus(t, 1020, 1020)
+	for _, id := range []byte{1, 2} {
+		if err := h.Write(id, 64, 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := h.SyncWrite(116, 4, 1, 0x00, 0x04, 0x00, 0x00, 2, 0x00, 0x08, 0x00, 0x00); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b.Step(2 * time.Second)
+
+	rs, err := h.SyncRead([]byte{1, 2}, 132, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, expect := range []int32{1024, 2048} {
+		p := int32(rs[i].Data[0]) | int32(rs[i].Data[1])<<8 | int32(rs[i].Data[2])<<16 | int32(rs[i].Data[3])<<24
+		if rs[i].Err != nil || math.Abs(float64(p-expect)) > 10 {
+			t.Errorf("Expected device ID %d at about %d but got %d (error: %v)", rs[i].ID, expect, p, rs[i].Err)
+		}
+	}
+}
+
+func TestRealTime(t *testing.T) {
+	h, b := newTestBus(t, 1020)
+	b.SetRealTime(true)
+	time.Sleep(20 * time.Millisecond)
+	data, err := h.Read(1, 120, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tick := int(data[0]) | int(data[1])<<8; tick < 20 {
+		t.Errorf("Expected Realtime Tick to be at least 20 but got %d", tick)
+	}
+	b.SetRealTime(false)
+	if _, err := h.Ping(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
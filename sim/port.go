@@ -0,0 +1,170 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// pollInterval is how often ServeConn checks for status packets to write to the connection.
+const pollInterval = 200 * time.Microsecond
+
+// Port is a connection to a bus, through which instruction packets are sent to the servos on the bus and the status
+// packets they return are received. Each port only receives the status packets returned for the instructions sent
+// through it. A Port implements io.ReadWriter and can be passed directly to the Protocol 2.0 handler.
+type Port struct {
+	bus *Bus
+	rx  []byte  // Written bytes that don't form a complete instruction packet yet.
+	tx  []chunk // Status packet bytes waiting to be read.
+}
+
+// chunk is a part of the bytes to be read from a port, which can only be read once its time has come.
+type chunk struct {
+	at   time.Time
+	data []byte
+}
+
+// NewPort returns a new port connected to the bus.
+func (b *Bus) NewPort() *Port {
+	return &Port{bus: b}
+}
+
+// Read reads the status packets returned by the servos for the instructions written to the port. It returns io.EOF
+// if there is nothing to read.
+func (p *Port) Read(b []byte) (int, error) {
+	p.bus.mu.Lock()
+	defer p.bus.mu.Unlock()
+	now := time.Now()
+	var n int
+	for len(p.tx) > 0 && n < len(b) && !p.tx[0].at.After(now) {
+		c := copy(b[n:], p.tx[0].data)
+		n += c
+		p.tx[0].data = p.tx[0].data[c:]
+		if len(p.tx[0].data) == 0 {
+			p.tx = p.tx[1:]
+		}
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// Write sends the given bytes to the servos on the bus. Each complete instruction packet is processed as soon as it
+// is written, and any status packets returned are available to Read right away.
+func (p *Port) Write(b []byte) (int, error) {
+	bus := p.bus
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if !bus.last.IsZero() {
+		now := time.Now()
+		for _, s := range bus.servos {
+			s.Step(now.Sub(bus.last))
+		}
+		bus.last = now
+	}
+	p.rx = append(p.rx, b...)
+	for {
+		packet, rest := nextPacket(p.rx)
+		p.rx = rest
+		if packet == nil {
+			break
+		}
+		bus.process(packet)
+		bus.flush(p)
+	}
+	return len(b), nil
+}
+
+// Serve accepts connections on the given listener (e.g. a TCP listener) and serves each of them on a port of its own
+// (see ServeConn), until accepting a connection fails, e.g. because the listener was closed. Clients should connect
+// with Dial, or wrap their connections with NewConn.
+func (b *Bus) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			b.ServeConn(conn)
+			conn.Close()
+		}()
+	}
+}
+
+// Conn is a client connection to a bus served over the network (see Serve). Reading from a Conn returns io.EOF when
+// there is nothing to read, as reading from a serial port does, so that it can be passed directly to the Protocol 2.0
+// handler. A plain net.Conn would block the handler's reads until the next bytes arrive instead.
+type Conn struct {
+	net.Conn
+}
+
+// NewConn returns a client connection to a bus, using the given network connection.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{Conn: conn}
+}
+
+// Dial connects to a bus served over TCP (see Serve) at the given address.
+func Dial(addr string) (*Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bus: %w", err)
+	}
+	return NewConn(conn), nil
+}
+
+// Read reads the bytes available on the connection, waiting for them no longer than pollInterval. It returns io.EOF
+// if there is nothing to read.
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(pollInterval)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// ServeConn serves the given connection (e.g. a TCP connection or a PTY) on a new port of the bus: the instruction
+// packets read from the connection are sent to the servos on the bus, and the status packets they return are written
+// back to the connection. It returns the error that stopped it, once reading from or writing to the connection fails
+// (io.EOF if the connection was closed by the other end). The connection should be closed by the caller, as reading
+// from it may still be in progress.
+func (b *Bus) ServeConn(conn io.ReadWriter) error {
+	p := b.NewPort()
+	errs := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				p.Write(buf[:n])
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 1024)
+	for {
+		select {
+		case err := <-errs:
+			return err
+		default:
+		}
+		n, _ := p.Read(buf)
+		if n == 0 {
+			time.Sleep(pollInterval)
+			continue
+		}
+		if _, err := conn.Write(buf[:n]); err != nil {
+			return err
+		}
+	}
+}
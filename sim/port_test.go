@@ -0,0 +1,78 @@
+package sim
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+)
+
+func TestPorts(t *testing.T) {
+	_, bus := newTestBus(t, 1020, 1020)
+
+	var wg sync.WaitGroup
+	for _, id := range []byte{1, 2} {
+		id := id
+		h := protocol2.NewHandler(bus.NewPort(), 0)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := h.Write(id, 104, byte(i), 0, 0, 0); err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				data, err := h.Read(id, 104, 4)
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if data[0] != byte(i) {
+					t.Errorf("Expected device ID %d to have Goal Velocity %d but got %d", id, i, data[0])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestServe(t *testing.T) {
+	_, b := newTestBus(t, 1020, 1200)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer l.Close()
+	go b.Serve(l)
+
+	var handlers []*protocol2.Handler
+	for i := 0; i < 2; i++ {
+		conn, err := Dial(l.Addr().String())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer conn.Close()
+		handlers = append(handlers, protocol2.NewHandler(conn, 50*time.Millisecond))
+	}
+
+	for i, h := range handlers {
+		id := byte(i + 1)
+		if err := h.Write(id, 65, 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rs, err := h.BroadcastPing()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(rs) != 2 || rs[0].ID != 1 || rs[1].ID != 2 {
+			t.Errorf("Expected responses from device IDs 1 and 2 but got %v", rs)
+		}
+	}
+	for _, id := range []byte{1, 2} {
+		if v, _ := b.Servo(id).Value("LED"); v != 1 {
+			t.Errorf("Expected device ID %d to have its LED on but got %d", id, v)
+		}
+	}
+}
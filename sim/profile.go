@@ -0,0 +1,65 @@
+package sim
+
+import "math"
+
+// profile is the trajectory generator of a servo, which turns changes of the goal into a smooth motion limited by the
+// profile velocity and acceleration. It is run once per control cycle, so the goal can be changed at any time, even
+// while the previous motion is still under way.
+type profile struct {
+	position float64 //The position of the trajectory (rad).
+	velocity float64 //The velocity of the trajectory (rad/s).
+}
+
+// stepPosition moves the trajectory towards the given goal position by dt seconds, accelerating and decelerating at
+// the given acceleration up to the given velocity (trapezoidal profile). A velocity or acceleration of 0 is
+// unlimited.
+func (p *profile) stepPosition(goal, velocity, acceleration, dt float64) {
+	if velocity <= 0 && acceleration <= 0 {
+		p.position, p.velocity = goal, 0
+		return
+	}
+	d := goal - p.position
+	if d == 0 && p.velocity == 0 {
+		return
+	}
+	dir := 1.0
+	if d < 0 {
+		dir = -1
+	}
+
+	// The fastest the trajectory can move while still being able to stop at the goal.
+	target := math.Inf(1)
+	if acceleration > 0 {
+		target = math.Sqrt(2 * acceleration * math.Abs(d))
+	}
+	if velocity > 0 {
+		target = math.Min(target, velocity)
+	}
+	dv := dir*target - p.velocity
+	if acceleration > 0 {
+		dv = math.Max(-acceleration*dt, math.Min(acceleration*dt, dv))
+	}
+	p.velocity += dv
+	p.position += p.velocity * dt
+
+	// Stop at the goal rather than overshoot it, which the discrete steps would otherwise do.
+	if (goal-p.position)*dir <= 0 || math.Abs(goal-p.position) < math.Abs(p.velocity)*dt/2 {
+		p.position, p.velocity = goal, 0
+	}
+}
+
+// stepVelocity moves the velocity of the trajectory towards the given goal velocity by dt seconds, at the given
+// acceleration. An acceleration of 0 is unlimited.
+func (p *profile) stepVelocity(goal, acceleration, dt float64) {
+	dv := goal - p.velocity
+	if acceleration > 0 {
+		dv = math.Max(-acceleration*dt, math.Min(acceleration*dt, dv))
+	}
+	p.velocity += dv
+	p.position += p.velocity * dt
+}
+
+// done reports whether the trajectory reached the given goal position and stopped.
+func (p *profile) done(goal float64) bool {
+	return p.position == goal && p.velocity == 0
+}
@@ -0,0 +1,83 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64 || loong64)
+
+package sim
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/haguro/go-dxl/internal/pty"
+)
+
+// PTY is a pseudo-terminal pair. Its slave device (e.g. /dev/pts/3) can be opened as a serial port by any program on
+// the same machine, while the bus is served on its master side (see ServeConn).
+type PTY struct {
+	master *os.File
+	slave  *os.File // Kept open so that reading from the master doesn't fail while no program has the slave open.
+	name   string
+}
+
+// OpenPTY opens a new pseudo-terminal pair, with the slave device in raw mode.
+func OpenPTY() (*PTY, error) {
+	master, name, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to open pseudo-terminal %s: %w", name, err)
+	}
+	// Without raw mode, the status packets written to the master would be echoed back to it, among other things.
+	var t syscall.Termios
+	if err := ioctl(slave.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, fmt.Errorf("failed to get pseudo-terminal %s attributes: %w", name, err)
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR |
+		syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	if err := ioctl(slave.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, fmt.Errorf("failed to set pseudo-terminal %s attributes: %w", name, err)
+	}
+
+	return &PTY{master: master, slave: slave, name: name}, nil
+}
+
+// Name returns the name of the slave device, to be opened by the programs talking to the bus.
+func (p *PTY) Name() string {
+	return p.name
+}
+
+// Read reads the bytes written to the slave device.
+func (p *PTY) Read(b []byte) (int, error) {
+	return p.master.Read(b)
+}
+
+// Write writes the given bytes to be read from the slave device.
+func (p *PTY) Write(b []byte) (int, error) {
+	return p.master.Write(b)
+}
+
+// Close closes the pseudo-terminal pair.
+func (p *PTY) Close() error {
+	p.slave.Close()
+	return p.master.Close()
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64 || loong64)
+
+package sim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	protocol2 "github.com/haguro/go-dxl/protocol/v2"
+	"github.com/haguro/go-dxl/serial"
+)
+
+func TestPTY(t *testing.T) {
+	_, b := newTestBus(t, 1020)
+	pty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer pty.Close()
+	go b.ServeConn(pty)
+
+	port, err := serial.Open(pty.Name(), serial.Config{})
+	if errors.Is(err, serial.ErrUnsupportedPlatform) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer port.Close()
+	h := protocol2.NewHandler(port, 50*time.Millisecond)
+
+	r, err := h.Ping(1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.Model != 1020 {
+		t.Errorf("Expected model number 1020 but got %d", r.Model)
+	}
+	if err := h.Write(1, 116, 0x00, 0x04, 0x00, 0x00); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v, _ := b.Servo(1).Value("Goal Position"); v != 1024 {
+		t.Errorf("Expected Goal Position of 1024 but got %d", v)
+	}
+}
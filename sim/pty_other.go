@@ -0,0 +1,16 @@
+//go:build !linux || !(386 || amd64 || arm || arm64 || riscv64 || loong64)
+
+package sim
+
+// PTY is a pseudo-terminal pair. Pseudo-terminals are not yet supported on this platform.
+type PTY struct{}
+
+// OpenPTY returns ErrUnsupportedPlatform as pseudo-terminals are not yet supported on this platform.
+func OpenPTY() (*PTY, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (p *PTY) Name() string                { return "" }
+func (p *PTY) Read(b []byte) (int, error)  { return 0, ErrUnsupportedPlatform }
+func (p *PTY) Write(b []byte) (int, error) { return 0, ErrUnsupportedPlatform }
+func (p *PTY) Close() error                { return ErrUnsupportedPlatform }
@@ -0,0 +1,444 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/haguro/go-dxl/controltable"
+)
+
+// factoryID is the ID of a device after a factory reset.
+const factoryID = 1
+
+// defaults holds the factory default value of the registers whose default isn't zero, keyed by register name. The
+// default of the limit registers is taken from their range instead.
+var defaults = map[string]int64{
+	"Firmware Version":    52,
+	"Baud Rate":           1,
+	"Return Delay Time":   250,
+	"Operating Mode":      3,
+	"Protocol Type":       2,
+	"Moving Threshold":    10,
+	"Temperature Limit":   80,
+	"Shutdown":            52,
+	"Status Return Level": 2,
+	"Velocity I Gain":     1920,
+	"Velocity P Gain":     100,
+	"Position P Gain":     800,
+	"Present Temperature": 25,
+}
+
+// goalLimits maps the goal registers to the limit register they must not exceed (in either direction).
+var goalLimits = map[string]string{
+	"Goal PWM":      "PWM Limit",
+	"Goal Current":  "Current Limit",
+	"Goal Torque":   "Torque Limit",
+	"Goal Velocity": "Velocity Limit",
+}
+
+// positionMode is the value of the Operating Mode register in which Goal Position must be within the position limits.
+const positionMode = 3
+
+// Servo is a simulated device holding the full control table of its model. Instructions are received through the Bus
+// the servo is attached to, while the control table can also be inspected and changed directly, e.g. to set the value
+// of read-only registers that a real device would measure.
+// The servo moves in response to its goals as the simulation is stepped (see Step), following its profile and driving
+// a simulated motor (see Motor).
+// A Servo is safe for concurrent use by multiple goroutines.
+type Servo struct {
+	mu        sync.Mutex
+	model     *controltable.Model
+	registers map[string]controltable.Register // The model's registers, keyed by name.
+	table     []byte
+	defaults  []byte
+	backup    []byte
+	pending   []byte // The registered instruction's address (2 bytes) and data, nil if there is none.
+	phys      physics
+}
+
+// NewServo creates a servo of the given model number with the given ID. Its control table holds the model's factory
+// default values.
+func NewServo(modelNumber uint16, id byte) (*Servo, error) {
+	if id > maxID {
+		return nil, fmt.Errorf("failed to create servo: %w", ErrInvalidID)
+	}
+	m, err := controltable.Lookup(modelNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create servo: %w", err)
+	}
+
+	var size uint16
+	for _, r := range m.Registers {
+		if end := r.Addr + r.Size; end > size {
+			size = end
+		}
+	}
+	s := &Servo{
+		model:     m,
+		defaults:  make([]byte, size),
+		phys:      physics{motor: DefaultMotor},
+		registers: make(map[string]controltable.Register, len(m.Registers)),
+	}
+	for _, r := range m.Registers {
+		s.registers[r.Name] = r
+		s.put(s.defaults, r, defaultValue(m, r))
+	}
+	if r, err := m.Register("Present Input Voltage"); err == nil {
+		// Halfway between the voltage limits, as it would be on a suitable power supply.
+		min, _ := m.Register("Min Voltage Limit")
+		max, _ := m.Register("Max Voltage Limit")
+		s.put(s.defaults, r, (min.Min+max.Max)/2)
+	}
+	s.table = make([]byte, size)
+	copy(s.table, s.defaults)
+	s.set("ID", int64(id))
+	return s, nil
+}
+
+// defaultValue returns the factory default value of the given register of the given model.
+func defaultValue(m *controltable.Model, r controltable.Register) int64 {
+	switch {
+	case r.Name == "Model Number":
+		return int64(m.Number)
+	case r.Name == "ID":
+		return factoryID
+	case strings.HasPrefix(r.Name, "Min ") && strings.HasSuffix(r.Name, " Limit"):
+		return r.Min
+	case strings.HasSuffix(r.Name, " Limit") && r.Name != "Temperature Limit":
+		return r.Max
+	}
+	v := defaults[r.Name]
+	if v < r.Min {
+		return r.Min
+	}
+	if v > r.Max {
+		return r.Max
+	}
+	return v
+}
+
+// ID returns the servo's current ID, as held in its control table.
+func (s *Servo) ID() byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return byte(s.get("ID"))
+}
+
+// Model returns the servo's model.
+func (s *Servo) Model() *controltable.Model {
+	return s.model
+}
+
+// Value returns the value of the register with the given name.
+func (s *Servo) Value(name string) (int64, error) {
+	r, err := s.register(name)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return r.Decode(s.table[r.Addr : r.Addr+r.Size])
+}
+
+// SetValue sets the value of the register with the given name. Unlike writing to the register through the bus, any
+// register can be set regardless of its access rights and of whether torque is enabled, as long as the value is
+// within the register's range.
+func (s *Servo) SetValue(name string, v int64) error {
+	r, err := s.register(name)
+	if err != nil {
+		return err
+	}
+	data, err := r.Encode(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copy(s.table[r.Addr:], data)
+	return nil
+}
+
+// register returns the register with the given name from the servo's model.
+func (s *Servo) register(name string) (controltable.Register, error) {
+	if r, ok := s.registers[name]; ok {
+		return r, nil
+	}
+	return s.model.Register(name)
+}
+
+// get returns the value of the register with the given name, or 0 if the model doesn't have it. The lock must be held.
+func (s *Servo) get(name string) int64 {
+	r, err := s.register(name)
+	if err != nil {
+		return 0
+	}
+	v, _ := r.Decode(s.table[r.Addr : r.Addr+r.Size])
+	return v
+}
+
+// set sets the value of the register with the given name, if the model has it. The lock must be held.
+func (s *Servo) set(name string, v int64) {
+	if r, err := s.register(name); err == nil {
+		s.put(s.table, r, v)
+	}
+}
+
+// put encodes the given value of the given register into table.
+func (s *Servo) put(table []byte, r controltable.Register, v int64) {
+	for i := uint16(0); i < r.Size; i++ {
+		table[r.Addr+i] = byte(uint64(v) >> (8 * i))
+	}
+}
+
+// statusLevel returns the value of the servo's Status Return Level register, which decides which instructions are
+// responded to: 0 for `ping` only, 1 for `ping` and the read instructions and 2 for all instructions.
+func (s *Servo) statusLevel() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.register("Status Return Level"); err != nil {
+		return 2
+	}
+	return s.get("Status Return Level")
+}
+
+// alert returns errAlert if the servo has a hardware error, or 0.
+func (s *Servo) alert() byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.get("Hardware Error Status") != 0 {
+		return errAlert
+	}
+	return 0
+}
+
+// ping returns the params of the servo's `ping` status: its model number and firmware version.
+func (s *Servo) ping() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []byte{byte(s.model.Number), byte(s.model.Number >> 8), byte(s.get("Firmware Version"))}
+}
+
+// read returns the given length of data from the given address of the control table, or the status error byte.
+func (s *Servo) read(addr, length uint16) ([]byte, byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if int(addr)+int(length) > len(s.table) {
+		return nil, errDataRange
+	}
+	data := make([]byte, length)
+	copy(data, s.table[addr:])
+	return data, 0
+}
+
+// write writes the given data to the given address of the control table and returns the status error byte. Nothing is
+// written if any part of the data can't be.
+func (s *Servo) write(addr uint16, data []byte) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errByte := s.check(addr, data); errByte != 0 {
+		return errByte
+	}
+	copy(s.table[addr:], data)
+	return 0
+}
+
+// check returns the status error byte of writing the given data to the given address, or 0 if it can be written. Only
+// whole registers can be written, and only if they are writable, torque is disabled for EEPROM registers and the
+// values are within the registers' ranges and limits. The lock must be held.
+func (s *Servo) check(addr uint16, data []byte) byte {
+	start, end := int(addr), int(addr)+len(data)
+	if len(data) == 0 {
+		return errDataLength
+	}
+	if end > len(s.table) {
+		return errDataRange
+	}
+	torque := s.get("Torque Enable") != 0
+	for _, r := range s.model.Registers {
+		rStart, rEnd := int(r.Addr), int(r.Addr)+int(r.Size)
+		if rEnd <= start || rStart >= end {
+			continue
+		}
+		if rStart < start || rEnd > end {
+			return errDataLength
+		}
+		if r.Access != controltable.ReadWrite || (r.Area == controltable.EEPROM && torque) {
+			return errAccess
+		}
+		v, _ := r.Decode(data[rStart-start : rEnd-start])
+		if v < r.Min || v > r.Max {
+			return errDataRange
+		}
+	}
+
+	// The goal limits are checked against the control table as it would be after the write, as the write may change
+	// the limits or the operating mode too.
+	table := make([]byte, len(s.table))
+	copy(table, s.table)
+	copy(table[addr:], data)
+	value := func(name string) (int64, bool) {
+		r, err := s.register(name)
+		if err != nil {
+			return 0, false
+		}
+		v, _ := r.Decode(table[r.Addr : r.Addr+r.Size])
+		return v, true
+	}
+	for goal, limit := range goalLimits {
+		r, err := s.register(goal)
+		if err != nil || int(r.Addr)+int(r.Size) <= start || int(r.Addr) >= end {
+			continue
+		}
+		v, _ := value(goal)
+		if l, ok := value(limit); ok && (v > l || v < -l) {
+			return errDataLimit
+		}
+	}
+	if r, err := s.register("Goal Position"); err == nil && int(r.Addr) >= start && int(r.Addr) < end {
+		mode, _ := value("Operating Mode")
+		v, _ := value("Goal Position")
+		min, minOK := value("Min Position Limit")
+		max, maxOK := value("Max Position Limit")
+		if mode == positionMode && minOK && maxOK && (v < min || v > max) {
+			return errDataLimit
+		}
+	}
+	return 0
+}
+
+// regWrite registers writing the given data to the given address of the control table on the next `action`
+// instruction, and returns the status error byte. The data is checked when it is registered.
+func (s *Servo) regWrite(addr uint16, data []byte) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errByte := s.check(addr, data); errByte != 0 {
+		return errByte
+	}
+	s.pending = append([]byte{byte(addr), byte(addr >> 8)}, data...)
+	s.set("Registered Instruction", 1)
+	return 0
+}
+
+// action writes the data registered by the last `reg write` instruction and returns the status error byte.
+func (s *Servo) action() byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return errInstruction
+	}
+	addr := uint16(s.pending[0]) + uint16(s.pending[1])<<8
+	data := s.pending[2:]
+	s.pending = nil
+	s.set("Registered Instruction", 0)
+	// The control table may have changed since the data was registered (e.g. torque was enabled).
+	if errByte := s.check(addr, data); errByte != 0 {
+		return errByte
+	}
+	copy(s.table[addr:], data)
+	return 0
+}
+
+// factoryReset resets the control table to its factory default values, keeping the ID and baud rate if the given
+// option says so, and returns the status error byte.
+func (s *Servo) factoryReset(option byte) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, baudRate := s.get("ID"), s.get("Baud Rate")
+	switch option {
+	case 0xFF, 0x01, 0x02:
+	default:
+		return errDataRange
+	}
+	s.resetRAM()
+	for _, r := range s.model.Registers {
+		if r.Area == controltable.EEPROM {
+			copy(s.table[r.Addr:r.Addr+r.Size], s.defaults[r.Addr:])
+		}
+	}
+	if option != 0xFF {
+		s.set("ID", id)
+	}
+	if option == 0x02 {
+		s.set("Baud Rate", baudRate)
+	}
+	return 0
+}
+
+// reboot resets the RAM area of the control table, as happens when the device is powered off and on again.
+func (s *Servo) reboot() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resetRAM()
+}
+
+// resetRAM resets the registers of the RAM area to their default values. The lock must be held.
+func (s *Servo) resetRAM() {
+	position := s.get("Present Position")
+	for _, r := range s.model.Registers {
+		if r.Area == controltable.RAM {
+			copy(s.table[r.Addr:r.Addr+r.Size], s.defaults[r.Addr:])
+		}
+	}
+	s.pending = nil
+	// The device is where it was left, and starts off holding it.
+	s.set("Present Position", position)
+	s.set("Goal Position", position)
+	if s.backup != nil {
+		s.set("Backup Ready", 1)
+	}
+}
+
+// clear handles a `clear` instruction with the given params and returns the status error byte. Only clearing the
+// multi-turn position is supported, which brings Present Position back within one turn. It can only be done while
+// the device isn't moving.
+func (s *Servo) clear(params []byte) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(params) != 5 {
+		return errDataLength
+	}
+	if params[0] != 0x01 || params[1] != 0x44 || params[2] != 0x58 || params[3] != 0x4C || params[4] != 0x22 {
+		return errDataRange
+	}
+	if s.get("Moving") != 0 {
+		return errResult
+	}
+	const turn = 4096
+	s.set("Present Position", (s.get("Present Position")%turn+turn)%turn)
+	return 0
+}
+
+// controlTableBackup handles a `backup` instruction with the given params and returns the status error byte. The
+// control table can be stored in or restored from the backup area, only while torque is disabled. Restoring the
+// control table reboots the device.
+func (s *Servo) controlTableBackup(params []byte) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(params) != 5 {
+		return errDataLength
+	}
+	if params[1] != 0x43 || params[2] != 0x54 || params[3] != 0x52 || params[4] != 0x4C {
+		return errDataRange
+	}
+	if s.get("Torque Enable") != 0 {
+		return errAccess
+	}
+	switch params[0] {
+	case 0x01:
+		s.backup = make([]byte, len(s.table))
+		copy(s.backup, s.table)
+		s.set("Backup Ready", 1)
+	case 0x02:
+		if s.backup == nil {
+			return errResult
+		}
+		position := s.get("Present Position")
+		copy(s.table, s.backup)
+		s.set("Present Position", position)
+		s.resetRAM()
+	default:
+		return errDataRange
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package sim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/haguro/go-dxl/controltable"
+)
+
+func TestNewServo(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		model     uint16
+		id        byte
+		expectErr error
+	}{
+		{name: "X series", model: 1020, id: 1},
+		{name: "PRO series", model: 54024, id: 252},
+		{name: "Unknown model", model: 1, id: 1, expectErr: controltable.ErrUnknownModel},
+		{name: "Invalid ID", model: 1020, id: 253, expectErr: ErrInvalidID},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewServo(tc.model, tc.id)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error of %q but got %q", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if s.ID() != tc.id {
+				t.Errorf("Expected ID %d but got %d", tc.id, s.ID())
+			}
+			if s.Model().Number != tc.model {
+				t.Errorf("Expected model number %d but got %d", tc.model, s.Model().Number)
+			}
+			if v, _ := s.Value("Model Number"); v != int64(tc.model) {
+				t.Errorf("Expected Model Number register of %d but got %d", tc.model, v)
+			}
+		})
+	}
+}
+
+func TestServoDefaults(t *testing.T) {
+	s, err := NewServo(1020, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, expect := range map[string]int64{
+		"Baud Rate":             1,
+		"Operating Mode":        3,
+		"Status Return Level":   2,
+		"Temperature Limit":     80,
+		"Max Voltage Limit":     160,
+		"Min Voltage Limit":     60,
+		"Velocity Limit":        1023,
+		"Max Position Limit":    4095,
+		"Min Position Limit":    0,
+		"Present Input Voltage": 110,
+		"Torque Enable":         0,
+	} {
+		if v, _ := s.Value(name); v != expect {
+			t.Errorf("Expected %s to default to %d but got %d", name, expect, v)
+		}
+	}
+}
+
+func TestServoValue(t *testing.T) {
+	s, _ := NewServo(1020, 1)
+
+	if err := s.SetValue("Present Position", -1234); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v, err := s.Value("Present Position"); err != nil || v != -1234 {
+		t.Errorf("Expected -1234 but got %d (error: %v)", v, err)
+	}
+	if err := s.SetValue("Torque Enable", 2); !errors.Is(err, controltable.ErrOutOfRange) {
+		t.Errorf("Expected error of %q but got %q", controltable.ErrOutOfRange, err)
+	}
+	if _, err := s.Value("Goal Torque"); !errors.Is(err, controltable.ErrUnknownRegister) {
+		t.Errorf("Expected error of %q but got %q", controltable.ErrUnknownRegister, err)
+	}
+	if err := s.SetValue("ID", 9); err != nil || s.ID() != 9 {
+		t.Errorf("Expected ID 9 but got %d (error: %v)", s.ID(), err)
+	}
+}